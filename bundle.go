@@ -0,0 +1,376 @@
+package httplive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/bingoohuang/httplive/internal/process"
+	"github.com/bingoohuang/httplive/pkg/util"
+)
+
+// bundleManifest is the name of the bundle JSON in the zip bundle.
+const bundleManifest = "bundle.json"
+
+// Bundle is the portable bundle of the endpoints in a workspace.
+type Bundle struct {
+	Version    int              `json:"version"`
+	ExportTime string           `json:"exportTime"`
+	Workspace  string           `json:"workspace,omitempty"`
+	Endpoints  []BundleEndpoint `json:"endpoints"`
+}
+
+// BundleEndpoint is an endpoint in the bundle, identified by its method and path.
+type BundleEndpoint struct {
+	Endpoint    string `json:"endpoint"`
+	Method      string `json:"method"`
+	MimeType    string `json:"mimeType,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+	FileContent []byte `json:"fileContent,omitempty"`
+	// File is the path of the file content in the zip bundle.
+	File       string `json:"file,omitempty"`
+	Body       string `json:"body"`
+	Author     string `json:"author,omitempty"`
+	CreateTime string `json:"createTime,omitempty"`
+	UpdateTime string `json:"updateTime,omitempty"`
+}
+
+// ExportBundle exports all the endpoints in the workspace as a bundle.
+func ExportBundle(workspace string) (*Bundle, error) {
+	var endpoints []process.Endpoint
+	if err := WorkspaceDo(workspace, func(dao *Dao) error {
+		endpoints = dao.ListEndpoints()
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{Version: 1, ExportTime: util.TimeFmt(time.Now()), Workspace: workspace}
+	for _, ep := range endpoints {
+		b.Endpoints = append(b.Endpoints, BundleEndpoint{
+			Endpoint: ep.Endpoint, Method: ep.Methods, MimeType: ep.MimeType,
+			Filename: ep.Filename, FileContent: ep.FileContent, Body: ep.Body,
+			Author: ep.Author, CreateTime: ep.CreateTime, UpdateTime: ep.UpdateTime,
+		})
+	}
+	return b, nil
+}
+
+// WriteZip writes the bundle as a zip of the bundle JSON, with the file contents as separate files.
+func (b Bundle) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	endpoints := make([]BundleEndpoint, len(b.Endpoints))
+	for i, ep := range b.Endpoints {
+		if len(ep.FileContent) > 0 {
+			ep.File = fmt.Sprintf("files/%d/%s", i+1, path.Base("/"+ep.Filename))
+			fw, err := zw.Create(ep.File)
+			if err != nil {
+				return err
+			}
+			if _, err := fw.Write(ep.FileContent); err != nil {
+				return err
+			}
+			ep.FileContent = nil
+		}
+		endpoints[i] = ep
+	}
+	b.Endpoints = endpoints
+
+	fw, err := zw.Create(bundleManifest)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(fw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(b); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// ParseBundle parses the bundle in JSON, or in zip.
+func ParseBundle(data []byte) (*Bundle, error) {
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		var b Bundle
+		if err := json.Unmarshal(data, &b); err != nil {
+			return nil, fmt.Errorf("parse bundle: %w", err)
+		}
+		return &b, nil
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("open zip bundle: %w", err)
+	}
+
+	readFile := func(name string) ([]byte, error) {
+		f, err := zr.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("zip bundle: %w", err)
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+
+	manifest, err := readFile(bundleManifest)
+	if err != nil {
+		return nil, err
+	}
+	var b Bundle
+	if err := json.Unmarshal(manifest, &b); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", bundleManifest, err)
+	}
+
+	for i, ep := range b.Endpoints {
+		if ep.File != "" {
+			if b.Endpoints[i].FileContent, err = readFile(ep.File); err != nil {
+				return nil, err
+			}
+			b.Endpoints[i].File = ""
+		}
+	}
+	return &b, nil
+}
+
+// ImportMode is how the bundle is imported when an endpoint exists already.
+type ImportMode string
+
+const (
+	// ImportMerge adds the new endpoints, and updates the existing ones.
+	ImportMerge ImportMode = "merge"
+	// ImportOverwrite replaces all the endpoints by the ones in the bundle.
+	ImportOverwrite ImportMode = "overwrite"
+	// ImportSkip adds the new endpoints only, the existing ones are kept.
+	ImportSkip ImportMode = "skip"
+)
+
+// ImportReport is the report of importing a bundle, the endpoints are listed as "METHOD path".
+// It is applied only when it is not a dry run, and no conflict or error is found.
+type ImportReport struct {
+	Mode      ImportMode `json:"mode"`
+	DryRun    bool       `json:"dryRun"`
+	Applied   bool       `json:"applied"`
+	Added     []string   `json:"added"`
+	Updated   []string   `json:"updated"`
+	Skipped   []string   `json:"skipped"`
+	Deleted   []string   `json:"deleted"`
+	Conflicts []string   `json:"conflicts,omitempty"`
+	Errors    []string   `json:"errors,omitempty"`
+}
+
+// importPlan is the endpoint to save in the import.
+type importPlan struct {
+	model process.APIDataModel
+	name  string
+	added bool
+}
+
+// ImportBundle imports the bundle into the workspace by the mode and the author.
+// The endpoints are checked the same as they are saved one by one before anything is written,
+// nothing is imported when any conflict or error is found, and nothing is written in a dry run.
+// The deletes and the saves are applied as a whole, or rolled back when any one fails.
+func ImportBundle(workspace string, b Bundle, mode ImportMode, dryRun bool, author string) (*ImportReport, error) {
+	switch mode {
+	case "":
+		mode = ImportMerge
+	case ImportMerge, ImportOverwrite, ImportSkip:
+	default:
+		return nil, fmt.Errorf("unknown import mode %q, merge, overwrite or skip expected", mode)
+	}
+
+	report, events, err := importRouted(workspace, b, mode, dryRun, author)
+	for _, e := range events {
+		notifyEndpointChanged(e)
+	}
+	return report, err
+}
+
+// importRouted plans and applies the import, and puts the routes of the events in one hold of the saving lock
+// of the routes, or the concurrent saves may pass the route tests conflicting with the imported endpoints.
+func importRouted(workspace string, b Bundle, mode ImportMode, dryRun bool, author string) (*ImportReport, []EndpointEvent, error) {
+	table := routesOf(workspace)
+	table.saving.Lock()
+	defer table.saving.Unlock()
+
+	var existing []process.Endpoint
+	if err := WorkspaceDo(workspace, func(dao *Dao) error {
+		existing = dao.ListEndpoints()
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	report := &ImportReport{Mode: mode, DryRun: dryRun,
+		Added: []string{}, Updated: []string{}, Skipped: []string{}, Deleted: []string{}}
+	ids := make(map[string]uint64, len(existing))
+	for _, ep := range existing {
+		ids[strings.ToUpper(ep.Methods)+" "+ep.Endpoint] = ep.ID
+	}
+
+	routes := table.Snapshot()
+	bundled := map[string]bool{}
+	var plans []importPlan
+	for i, ep := range b.Endpoints {
+		model := process.APIDataModel{
+			Endpoint: ep.Endpoint, Method: strings.ToUpper(ep.Method), MimeType: ep.MimeType,
+			Filename: ep.Filename, FileContent: ep.FileContent, Body: process.RawMessage(ep.Body),
+			Workspace: workspace,
+		}
+		name := routeIdentity(model)
+		if model.Endpoint == "" || model.Method == "" {
+			report.Errors = append(report.Errors, fmt.Sprintf("endpoint #%d: endpoint and method could not be empty", i+1))
+			continue
+		}
+		if bundled[name] {
+			report.Errors = append(report.Errors, name+": duplicate in the bundle")
+			continue
+		}
+		bundled[name] = true
+		if err := checkEndpoint(model); err != nil {
+			report.Errors = append(report.Errors, name+": "+err.Error())
+			continue
+		}
+
+		id, ok := ids[name]
+		if ok && mode == ImportSkip {
+			report.Skipped = append(report.Skipped, name)
+			continue
+		}
+		if ok {
+			model.ID = process.ID(fmt.Sprintf("%d", id))
+		}
+		plans = append(plans, importPlan{model: model, name: name, added: !ok})
+	}
+
+	var deleted []process.Endpoint
+	if mode == ImportOverwrite {
+		for _, ep := range existing {
+			if name := strings.ToUpper(ep.Methods) + " " + ep.Endpoint; !bundled[name] {
+				deleted = append(deleted, ep)
+				report.Deleted = append(report.Deleted, name)
+				routes.Release(ep.ID)
+			}
+		}
+	}
+
+	for i, p := range plans {
+		id := p.model.ID.Int()
+		if p.added {
+			id = ^uint64(i) // a temporary ID to claim the routes in the snapshot
+		}
+		test := p.model
+		test.ID = process.ID(fmt.Sprintf("%d", id))
+		if err := routes.Test(test); err != nil {
+			report.Conflicts = append(report.Conflicts, p.name+": "+err.Error())
+			continue
+		}
+		routes.Claim(id, p.model)
+
+		if p.added {
+			report.Added = append(report.Added, p.name)
+		} else {
+			report.Updated = append(report.Updated, p.name)
+		}
+	}
+
+	if dryRun || len(report.Conflicts) > 0 || len(report.Errors) > 0 {
+		return report, nil, nil
+	}
+
+	var events []EndpointEvent
+	if err := WorkspaceDo(workspace, func(dao *Dao) (err error) {
+		events, err = applyImport(dao, workspace, deleted, plans, author)
+		return err
+	}); err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report, nil, nil
+	}
+
+	report.Applied = true
+	for i := range events {
+		table.apply(events[i])
+		events[i].routed = true
+	}
+	return report, events, nil
+}
+
+// applyImport deletes and saves the endpoints of the import in one hold of the DB lock,
+// and returns the events to notify. When any write fails, the endpoints and the revisions
+// written before are rolled back, so the workspace is left as it was.
+func applyImport(dao *Dao, workspace string, deleted []process.Endpoint, plans []importPlan,
+	author string,
+) (events []EndpointEvent, err error) {
+	var undo []func() error
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(undo) - 1; i >= 0; i-- {
+			if e := undo[i](); e != nil {
+				log.Printf("W! rollback import into workspace %q: %v", workspace, e)
+			}
+		}
+		events = nil
+	}()
+
+	addRevision := func(old process.Endpoint, t string) error {
+		rev := process.CreateEndpointRevision(old, t)
+		if err := dao.db.Save(&rev); err != nil {
+			return err
+		}
+		undo = append(undo, func() error { return dao.db.DeleteStruct(&rev) })
+		return nil
+	}
+	// restore restores the endpoint as a whole, the update keeps the fields which are zero in the bundle.
+	restore := func(old process.Endpoint) func() error {
+		return func() error { return dao.endpoints.AddEndpoint(&old) }
+	}
+
+	now := util.TimeFmt(time.Now())
+	for _, ep := range deleted {
+		name := strings.ToUpper(ep.Methods) + " " + ep.Endpoint
+		if err := addRevision(ep, now); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if err := dao.endpoints.DeleteEndpoint(ep); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		undo = append(undo, restore(ep))
+
+		gone := process.Endpoint{ID: ep.ID, Endpoint: ep.Endpoint, Methods: ep.Methods, DeletedAt: now}
+		events = append(events, EndpointEvent{Endpoint: gone, Workspace: workspace, Deleted: true})
+	}
+
+	for _, p := range plans {
+		old := dao.FindEndpoint(p.model.ID.Int())
+		bean := CreateEndpoint(p.model, old)
+		bean.Author = author
+
+		if old == nil {
+			if err := dao.endpoints.AddEndpoint(&bean); err != nil {
+				return nil, fmt.Errorf("%s: %w", p.name, err)
+			}
+			added := bean
+			undo = append(undo, func() error { return dao.endpoints.DeleteEndpoint(added) })
+		} else {
+			if err := addRevision(*old, bean.UpdateTime); err != nil {
+				return nil, fmt.Errorf("%s: %w", p.name, err)
+			}
+			if err := dao.endpoints.UpdateEndpoint(bean); err != nil {
+				return nil, fmt.Errorf("%s: %w", p.name, err)
+			}
+			undo = append(undo, restore(*old))
+		}
+
+		events = append(events, EndpointEvent{Endpoint: bean, Workspace: workspace})
+	}
+
+	return events, nil
+}
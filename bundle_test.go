@@ -0,0 +1,193 @@
+package httplive
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bingoohuang/httplive/internal/process"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func bundleOf(endpoints ...BundleEndpoint) Bundle {
+	return Bundle{Version: 1, Endpoints: endpoints}
+}
+
+func TestExportImportBundle(t *testing.T) {
+	prepareDB(t)
+
+	_, err := SaveEndpoint(process.APIDataModel{Endpoint: "/logo", Method: "GET", MimeType: "image/png",
+		Filename: "logo.png", FileContent: []byte("png"), Body: process.RawMessage(`{}`)})
+	assert.Nil(t, err)
+
+	b, err := ExportBundle("")
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	assert.Nil(t, b.WriteZip(&buf))
+	parsed, err := ParseBundle(buf.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, b.Endpoints, parsed.Endpoints)
+
+	_, err = SaveWorkspace(process.Workspace{Name: "copy", Prefix: "/copy"})
+	assert.Nil(t, err)
+	report, err := ImportBundle("copy", *parsed, ImportMerge, false, "")
+	assert.Nil(t, err)
+	assert.True(t, report.Applied)
+	assert.Len(t, report.Added, len(b.Endpoints))
+
+	m, err := GetByEndpoint("copy", "/logo", "GET")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("png"), m.FileContent)
+	assert.Equal(t, "logo.png", m.Filename)
+}
+
+func TestImportBundleModes(t *testing.T) {
+	prepareDB(t)
+	b := bundleOf(
+		BundleEndpoint{Endpoint: "/health", Method: "get", Body: `{"Status": "imported"}`},
+		BundleEndpoint{Endpoint: "/new", Method: "GET", Body: `{"new": true}`},
+	)
+
+	report, err := ImportBundle("", b, ImportSkip, false, "")
+	assert.Nil(t, err)
+	assert.True(t, report.Applied)
+	assert.Equal(t, []string{"GET /new"}, report.Added)
+	assert.Equal(t, []string{"GET /health"}, report.Skipped)
+	assert.JSONEq(t, `{"Status": "OK"}`, bodyOf(t, "/health"))
+
+	report, err = ImportBundle("", b, ImportMerge, false, "importer")
+	assert.Nil(t, err)
+	assert.True(t, report.Applied)
+	assert.Equal(t, []string{"GET /health", "GET /new"}, report.Updated)
+	assert.JSONEq(t, `{"Status": "imported"}`, bodyOf(t, "/health"))
+
+	report, err = ImportBundle("", b, ImportOverwrite, false, "")
+	assert.Nil(t, err)
+	assert.True(t, report.Applied)
+	assert.Contains(t, report.Deleted, "GET /status")
+	assert.Equal(t, []string{"GET /health", "GET /new"}, names(EndpointList("", true)))
+
+	w := httptest.NewRecorder()
+	serveAPI(w, httptest.NewRequest("GET", "/new", nil))
+	assert.JSONEq(t, `{"new": true}`, w.Body.String())
+	w = httptest.NewRecorder()
+	assert.False(t, serveAPI(w, httptest.NewRequest("GET", "/status", nil)).RouterServed)
+
+	_, err = ImportBundle("", b, "replace", false, "")
+	assert.NotNil(t, err)
+}
+
+func TestImportBundleDryRun(t *testing.T) {
+	prepareDB(t)
+	before := EndpointList("", true)
+
+	b := bundleOf(BundleEndpoint{Endpoint: "/new", Method: "GET", Body: `{}`})
+	report, err := ImportBundle("", b, ImportOverwrite, true, "")
+	assert.Nil(t, err)
+	assert.False(t, report.Applied)
+	assert.Equal(t, []string{"GET /new"}, report.Added)
+	assert.Len(t, report.Deleted, len(before))
+	assert.Equal(t, before, EndpointList("", true))
+}
+
+func TestImportBundleChecks(t *testing.T) {
+	prepareDB(t)
+	before := EndpointList("", true)
+
+	for _, ep := range []BundleEndpoint{
+		{Endpoint: "/new/1", Method: "GET", Body: `{}`},
+		{Endpoint: "/a/../b", Method: "GET", Body: `{}`},
+		{Endpoint: "/v", Method: "POST", Body: `{"_validate": {"body": {"type": 1}}}`},
+		{Endpoint: "", Method: "GET", Body: `{}`},
+		{Endpoint: "/new/:id", Method: "get", Body: `{}`},
+	} {
+		b := bundleOf(BundleEndpoint{Endpoint: "/new/:id", Method: "GET", Body: `{}`}, ep)
+		for _, dryRun := range []bool{true, false} {
+			report, err := ImportBundle("", b, ImportOverwrite, dryRun, "")
+			assert.Nil(t, err)
+			assert.False(t, report.Applied, ep.Endpoint)
+			assert.Len(t, append(report.Conflicts, report.Errors...), 1, ep.Endpoint)
+			assert.Equal(t, before, EndpointList("", true), "nothing is written")
+		}
+	}
+}
+
+func TestImportConflictStatus(t *testing.T) {
+	prepareDB(t)
+
+	body := `{"version": 1, "endpoints": [{"endpoint": "/echo/1", "method": "GET", "body": "{}"}]}`
+	for dryRun, status := range map[string]int{"true": http.StatusOK, "false": http.StatusConflict} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/api/import?dryRun="+dryRun, strings.NewReader(body))
+
+		code, report := WebCliController{}.Import(c, importT{})
+		assert.Equal(t, status, int(code), dryRun)
+		assert.Len(t, report.(*ImportReport).Conflicts, 1)
+	}
+}
+
+// failingStore fails to write the endpoint of the path.
+type failingStore struct {
+	EndpointStore
+	endpoint string
+}
+
+func (s failingStore) AddEndpoint(ep *process.Endpoint) error {
+	if ep.Endpoint == s.endpoint {
+		return errors.New("disk full")
+	}
+	return s.EndpointStore.AddEndpoint(ep)
+}
+
+func TestApplyImportRollback(t *testing.T) {
+	prepareDB(t)
+	_, err := SaveEndpoint(process.APIDataModel{Endpoint: "/logo", Method: "GET", Filename: "logo.png",
+		FileContent: []byte("png"), Body: process.RawMessage(`{}`)})
+	assert.Nil(t, err)
+	before := EndpointList("", true)
+	revisions := len(allRevisions(t))
+
+	health, _ := GetByEndpoint("", "/health", "GET")
+	logo, _ := GetByEndpoint("", "/logo", "GET")
+	status, _ := GetByEndpoint("", "/status", "GET")
+	plans := []importPlan{
+		{model: process.APIDataModel{ID: health.ID, Endpoint: "/health", Method: "GET", Body: process.RawMessage(`{"Status": "imported"}`)}, name: "GET /health"},
+		{model: process.APIDataModel{ID: logo.ID, Endpoint: "/logo", Method: "GET", Body: process.RawMessage(`{"logo": 1}`)}, name: "GET /logo"},
+		{model: process.APIDataModel{Endpoint: "/a", Method: "GET", Body: process.RawMessage(`{}`)}, name: "GET /a", added: true},
+		{model: process.APIDataModel{Endpoint: "/b", Method: "GET", Body: process.RawMessage(`{}`)}, name: "GET /b", added: true},
+	}
+
+	err = DBDo(func(dao *Dao) error {
+		dao = dao.Workspace("")
+		deleted := []process.Endpoint{*dao.FindEndpoint(status.ID.Int())}
+		dao.endpoints = failingStore{EndpointStore: dao.endpoints, endpoint: "/b"}
+		events, err := applyImport(dao, "", deleted, plans, "")
+		assert.Nil(t, events)
+		return err
+	})
+	assert.ErrorContains(t, err, "GET /b: disk full")
+
+	assert.Equal(t, before, EndpointList("", true), "the endpoints are rolled back")
+	assert.Len(t, allRevisions(t), revisions, "the revisions are rolled back")
+}
+
+func names(models []process.APIDataModel) (result []string) {
+	for _, m := range models {
+		result = append(result, routeIdentity(m))
+	}
+	return result
+}
+
+func allRevisions(t *testing.T) (result []process.EndpointRevision) {
+	t.Helper()
+	assert.Nil(t, DBDo(func(dao *Dao) error {
+		result = dao.AllRevisions()
+		return nil
+	}))
+	return result
+}
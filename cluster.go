@@ -0,0 +1,538 @@
+package httplive
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/bingoohuang/httplive/internal/process"
+	"github.com/bingoohuang/httplive/pkg/util"
+)
+
+const (
+	// clusterSyncInterval is the interval to pull the changes missed from the peers.
+	clusterSyncInterval = 10 * time.Second
+	// clusterOutboxSize is the max changes queued to push to a peer, the ones over it are left to the pull.
+	clusterOutboxSize = 1000
+)
+
+// ErrClusterOff is the error when httplive is not started with peers.
+var ErrClusterOff = errors.New("cluster mode is off, see --peers")
+
+// cluster is the node of this httplive in the cluster, nil when the cluster mode is off.
+var cluster *clusterNode
+
+// clusterNode replicates the endpoint changes with the peers.
+// The local changes are pushed to the peers at once, and the changes missed, like the ones when a peer is down,
+// are pulled from the peers periodically by comparing the version vectors of the endpoints.
+type clusterNode struct {
+	lock   sync.Mutex
+	id     string
+	vector process.VersionVector
+	peers  []*clusterPeer
+	client *http.Client
+}
+
+// clusterPeer is a peer of the cluster.
+type clusterPeer struct {
+	url    string
+	outbox chan process.ClusterChange
+	lock   sync.Mutex
+	status PeerStatus
+}
+
+// PeerStatus is the replication status of a peer.
+type PeerStatus struct {
+	URL  string `json:"url"`
+	Node string `json:"node,omitempty"`
+	// Lag is the number of the endpoint changes of this node which the peer has not seen at the last sync.
+	Lag int `json:"lag"`
+	// Pending is the number of the changes queued to push to the peer.
+	Pending   int    `json:"pending"`
+	LastPush  string `json:"lastPush,omitempty"`
+	LastSync  string `json:"lastSync,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// ClusterStatus is the replication status of the cluster.
+type ClusterStatus struct {
+	Node   string                `json:"node"`
+	Vector process.VersionVector `json:"vector"`
+	Peers  []PeerStatus          `json:"peers"`
+}
+
+// ClusterDigest is the versions of all the endpoints in a node, to find the changes missed.
+type ClusterDigest struct {
+	Node    string                 `json:"node"`
+	Vector  process.VersionVector  `json:"vector"`
+	Entries []process.ClusterEntry `json:"entries"`
+}
+
+// StartCluster starts replicating the endpoint changes with the peers, like http://127.0.0.1:5004,
+// with the context path of the peer if any, and the user:pass in the URL for its basic auth.
+func StartCluster(ctx context.Context, peers []string) error {
+	node := &clusterNode{client: &http.Client{Timeout: 10 * time.Second}}
+	if err := DBDo(func(dao *Dao) error {
+		return node.load(dao)
+	}); err != nil {
+		return fmt.Errorf("load cluster node: %w", err)
+	}
+
+	for _, p := range peers {
+		if p = strings.TrimSuffix(strings.TrimSpace(p), "/"); p != "" {
+			node.peers = append(node.peers, &clusterPeer{
+				url: p, outbox: make(chan process.ClusterChange, clusterOutboxSize), status: PeerStatus{URL: p},
+			})
+		}
+	}
+
+	cluster = node
+	OnEndpointChanged(node.onEndpointChanged)
+	if err := node.reconcile(); err != nil {
+		return fmt.Errorf("reconcile cluster entries: %w", err)
+	}
+
+	for _, p := range node.peers {
+		go node.push(ctx, p)
+		go node.syncLoop(ctx, p)
+	}
+
+	log.Printf("cluster node %s started with peers %v", node.id, peers)
+	return nil
+}
+
+// load loads the ID and the version vector of the node, the ID is generated at the first time.
+func (n *clusterNode) load(dao *Dao) error {
+	if err := dao.store.Get(metaBucket, "clusterNode", &n.id); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return err
+	}
+	if n.id == "" {
+		b := make([]byte, 4)
+		_, _ = rand.Read(b)
+		hostname, _ := os.Hostname()
+		n.id = util.Or(hostname, "httplive") + "-" + hex.EncodeToString(b)
+		if err := dao.store.Set(metaBucket, "clusterNode", n.id); err != nil {
+			return err
+		}
+	}
+
+	n.vector = process.VersionVector{}
+	if err := dao.store.Get(metaBucket, "clusterVector", &n.vector); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// onEndpointChanged records the local change of the endpoint, and queues it to push to the peers.
+// The old path of a renamed endpoint is recorded as deleted, or the peers would keep serving it.
+func (n *clusterNode) onEndpointChanged(e EndpointEvent) {
+	if e.Replicated || e.Endpoint.Endpoint == "" {
+		return
+	}
+
+	events := []EndpointEvent{e}
+	if e.Old != nil && !e.Deleted {
+		old := *e.Old
+		old.DeletedAt = e.Endpoint.UpdateTime
+		events = append(events, EndpointEvent{Endpoint: old, Workspace: e.Workspace, Deleted: true})
+	}
+
+	for _, e := range events {
+		n.publish(e)
+	}
+}
+
+// publish records the local change of the endpoint, and queues it to push to the peers.
+func (n *clusterNode) publish(e EndpointEvent) {
+	n.lock.Lock()
+	change, err := n.record(e)
+	n.lock.Unlock()
+	if err != nil {
+		log.Printf("cluster: record change of %s %s: %v", e.Endpoint.Methods, e.Endpoint.Endpoint, err)
+		return
+	}
+
+	for _, p := range n.peers {
+		select {
+		case p.outbox <- change:
+		default: // left to the pull
+		}
+	}
+}
+
+// reconcile records the endpoints changed while the cluster mode is off, like the ones existing before
+// the peers are added, which have no entries yet, and the ones updated or deleted since their entries are written.
+func (n *clusterNode) reconcile() error {
+	var events []EndpointEvent
+	if err := DBDo(func(dao *Dao) error {
+		entries, err := dao.ListClusterEntries()
+		if err != nil {
+			return err
+		}
+		live := make(map[string]process.ClusterEntry, len(entries))
+		for _, entry := range entries {
+			if !entry.Deleted {
+				live[entry.Key] = entry
+			}
+		}
+
+		now := util.TimeFmt(time.Now())
+		workspaces := []string{""}
+		for _, w := range dao.ListWorkspaces() {
+			workspaces = append(workspaces, w.Name)
+		}
+		for _, workspace := range workspaces {
+			for _, ep := range dao.Workspace(workspace).ListEndpoints() {
+				key := process.ClusterKey(workspace, strings.ToUpper(ep.Methods), ep.Endpoint)
+				if entry, ok := live[key]; !ok || entry.Local != ep.UpdateTime {
+					events = append(events, EndpointEvent{Endpoint: ep, Workspace: workspace})
+				}
+				delete(live, key)
+			}
+		}
+
+		for _, entry := range live {
+			ep := process.Endpoint{Endpoint: entry.Endpoint, Methods: entry.Method, DeletedAt: now}
+			events = append(events, EndpointEvent{Endpoint: ep, Workspace: entry.Workspace, Deleted: true})
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		n.publish(e)
+	}
+	return nil
+}
+
+// record bumps the version of the endpoint by the local change.
+func (n *clusterNode) record(e EndpointEvent) (change process.ClusterChange, err error) {
+	ep := e.Endpoint
+	method := strings.ToUpper(ep.Methods)
+	key := process.ClusterKey(e.Workspace, method, ep.Endpoint)
+
+	err = DBDo(func(dao *Dao) error {
+		entry, err := dao.FindClusterEntry(key)
+		if err != nil {
+			return err
+		}
+
+		vector := process.VersionVector{}
+		if entry != nil {
+			vector = entry.Vector.Copy()
+		}
+		n.vector = n.vector.Copy()
+		n.vector[n.id]++
+		vector[n.id] = n.vector[n.id]
+
+		change = process.ClusterChange{
+			ClusterEntry: process.ClusterEntry{
+				Key: key, Workspace: e.Workspace, Method: method, Endpoint: ep.Endpoint,
+				Vector: vector, Origin: n.id, UpdateTime: util.Or(ep.DeletedAt, ep.UpdateTime), Deleted: e.Deleted,
+				Local: util.Or(ep.DeletedAt, ep.UpdateTime),
+			},
+		}
+		if !e.Deleted {
+			change.MimeType, change.Filename, change.FileContent = ep.MimeType, ep.Filename, ep.FileContent
+			change.Body, change.Author = ep.Body, ep.Author
+		}
+
+		if err := dao.SaveClusterEntry(change.ClusterEntry); err != nil {
+			return err
+		}
+		return dao.store.Set(metaBucket, "clusterVector", n.vector)
+	})
+	return change, err
+}
+
+// apply applies the change replicated from a peer, unless it has been seen.
+// The concurrent changes of the same endpoint are resolved by the later update time, and then by the origin node.
+func (n *clusterNode) apply(c process.ClusterChange) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	var seen *process.ClusterEntry
+	if err := DBDo(func(dao *Dao) (err error) {
+		seen, err = dao.FindClusterEntry(c.Key)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	entry := c.ClusterEntry
+	if seen != nil {
+		if seen.Vector.Descends(c.Vector) {
+			return nil
+		}
+		if !c.Vector.Descends(seen.Vector) {
+			entry.Vector = seen.Vector.Merge(c.Vector)
+			if !c.Wins(*seen) {
+				seen.Vector = entry.Vector
+				return n.saveEntry(*seen)
+			}
+		}
+	}
+
+	local, err := applyClusterChange(c)
+	if err != nil {
+		return err
+	}
+	entry.Local = local
+	return n.saveEntry(entry)
+}
+
+func (n *clusterNode) saveEntry(entry process.ClusterEntry) error {
+	return DBDo(func(dao *Dao) error {
+		if err := dao.SaveClusterEntry(entry); err != nil {
+			return err
+		}
+		n.vector = n.vector.Merge(entry.Vector)
+		return dao.store.Set(metaBucket, "clusterVector", n.vector)
+	})
+}
+
+// applyClusterChange saves or deletes the endpoint by the change, and returns the update time of the local endpoint.
+func applyClusterChange(c process.ClusterChange) (local string, err error) {
+	if !c.Deleted {
+		ep, err := saveEndpoint(process.APIDataModel{
+			Endpoint: c.Endpoint, Method: c.Method, MimeType: c.MimeType, Filename: c.Filename,
+			FileContent: c.FileContent, Body: process.RawMessage(c.Body), Workspace: c.Workspace,
+		}, c.Author, true)
+		if err != nil {
+			return "", err
+		}
+		return ep.UpdateTime, nil
+	}
+
+	existing, err := GetByEndpoint(c.Workspace, c.Endpoint, c.Method)
+	if err != nil || existing == nil {
+		return "", err
+	}
+	return "", deleteEndpoint(c.Workspace, string(existing.ID), true)
+}
+
+// Digest returns the versions of all the endpoints in the node.
+func (n *clusterNode) Digest() (*ClusterDigest, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	d := &ClusterDigest{Node: n.id, Vector: n.vector.Copy()}
+	err := DBDo(func(dao *Dao) (err error) {
+		d.Entries, err = dao.ListClusterEntries()
+		return err
+	})
+	return d, err
+}
+
+// Changes returns the current changes of the endpoints of the keys.
+func (n *clusterNode) Changes(keys []string) ([]process.ClusterChange, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	changes := make([]process.ClusterChange, 0, len(keys))
+	for _, key := range keys {
+		var entry *process.ClusterEntry
+		if err := DBDo(func(dao *Dao) (err error) {
+			entry, err = dao.FindClusterEntry(key)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+
+		c := process.ClusterChange{ClusterEntry: *entry}
+		if !entry.Deleted {
+			var ep *process.Endpoint
+			if err := WorkspaceDo(entry.Workspace, func(dao *Dao) error {
+				ep = dao.FindByEndpoint(entry.Endpoint, entry.Method)
+				return nil
+			}); err != nil || ep == nil {
+				continue
+			}
+			c.MimeType, c.Filename, c.FileContent = ep.MimeType, ep.Filename, ep.FileContent
+			c.Body, c.Author = ep.Body, ep.Author
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// Apply applies the changes replicated from a peer, and returns the errors of the ones failed.
+func (n *clusterNode) Apply(changes []process.ClusterChange) []string {
+	errs := make([]string, 0)
+	for _, c := range changes {
+		if err := n.apply(c); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", c.Key, err))
+		}
+	}
+	return errs
+}
+
+// Status returns the replication status of the node.
+func (n *clusterNode) Status() ClusterStatus {
+	n.lock.Lock()
+	s := ClusterStatus{Node: n.id, Vector: n.vector.Copy(), Peers: make([]PeerStatus, 0, len(n.peers))}
+	n.lock.Unlock()
+
+	for _, p := range n.peers {
+		p.lock.Lock()
+		ps := p.status
+		p.lock.Unlock()
+		ps.Pending = len(p.outbox)
+		s.Peers = append(s.Peers, ps)
+	}
+	return s
+}
+
+// push pushes the queued changes to the peer in batches.
+func (n *clusterNode) push(ctx context.Context, p *clusterPeer) {
+	for {
+		var batch []process.ClusterChange
+		select {
+		case <-ctx.Done():
+			return
+		case c := <-p.outbox:
+			batch = append(batch, c)
+		}
+		for more := true; more && len(batch) < 100; {
+			select {
+			case c := <-p.outbox:
+				batch = append(batch, c)
+			default:
+				more = false
+			}
+		}
+
+		var rsp struct{ Errors []string }
+		err := n.call(ctx, p, http.MethodPost, "/changes", batch, &rsp)
+		if err == nil && len(rsp.Errors) > 0 {
+			err = errors.New(strings.Join(rsp.Errors, "; "))
+		}
+		p.update(func(s *PeerStatus) {
+			s.LastPush = util.TimeFmt(time.Now())
+			s.LastError = errString(err)
+		})
+	}
+}
+
+// syncLoop syncs with the peer at once, and then periodically.
+func (n *clusterNode) syncLoop(ctx context.Context, p *clusterPeer) {
+	ticker := time.NewTicker(clusterSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		err := n.sync(ctx, p)
+		if err != nil {
+			p.update(func(s *PeerStatus) { s.LastError = err.Error() })
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync pulls the changes missed from the peer, and counts the changes the peer has not seen.
+func (n *clusterNode) sync(ctx context.Context, p *clusterPeer) error {
+	var digest ClusterDigest
+	if err := n.call(ctx, p, http.MethodGet, "/digest", nil, &digest); err != nil {
+		return err
+	}
+
+	local, err := n.Digest()
+	if err != nil {
+		return err
+	}
+	localEntries := make(map[string]process.ClusterEntry, len(local.Entries))
+	for _, e := range local.Entries {
+		localEntries[e.Key] = e
+	}
+
+	var missed []string
+	remoteEntries := make(map[string]process.ClusterEntry, len(digest.Entries))
+	for _, e := range digest.Entries {
+		remoteEntries[e.Key] = e
+		if l, ok := localEntries[e.Key]; !ok || !l.Vector.Descends(e.Vector) {
+			missed = append(missed, e.Key)
+		}
+	}
+
+	lag := 0
+	for key, l := range localEntries {
+		if r, ok := remoteEntries[key]; !ok || !r.Vector.Descends(l.Vector) {
+			lag++
+		}
+	}
+
+	var errs []string
+	if len(missed) > 0 {
+		var rsp struct{ Changes []process.ClusterChange }
+		if err := n.call(ctx, p, http.MethodPost, "/entries", missed, &rsp); err != nil {
+			return err
+		}
+		errs = n.Apply(rsp.Changes)
+	}
+
+	p.update(func(s *PeerStatus) {
+		s.Node, s.Lag, s.LastSync = digest.Node, lag, util.TimeFmt(time.Now())
+		s.LastError = strings.Join(errs, "; ")
+	})
+	return nil
+}
+
+// call calls the cluster API of the peer with the JSON request and response.
+func (n *clusterNode) call(ctx context.Context, p *clusterPeer, method, api string, req, rsp interface{}) error {
+	var body bytes.Buffer
+	if req != nil {
+		if err := json.NewEncoder(&body).Encode(req); err != nil {
+			return err
+		}
+	}
+
+	r, err := http.NewRequestWithContext(ctx, method, p.url+"/httplive/webcli/api/cluster"+api, &body)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: status %d", method, api, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(rsp)
+}
+
+func (p *clusterPeer) update(f func(s *PeerStatus)) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	f(&p.status)
+}
+
+func errString(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
@@ -0,0 +1,203 @@
+package httplive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bingoohuang/httplive/internal/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func prepareClusterNode(t *testing.T, id string) *clusterNode {
+	t.Helper()
+	prepareDB(t)
+
+	n := &clusterNode{id: id, client: &http.Client{}}
+	assert.Nil(t, DBDo(n.load))
+	return n
+}
+
+func clusterEntryOf(t *testing.T, method, endpoint string) *process.ClusterEntry {
+	t.Helper()
+
+	var entry *process.ClusterEntry
+	assert.Nil(t, DBDo(func(dao *Dao) (err error) {
+		entry, err = dao.FindClusterEntry(process.ClusterKey("", method, endpoint))
+		return err
+	}))
+	return entry
+}
+
+func bodyOf(t *testing.T, endpoint string) string {
+	t.Helper()
+
+	m, err := GetByEndpoint("", endpoint, "GET")
+	assert.Nil(t, err)
+	if m == nil {
+		return ""
+	}
+	return string(m.Body)
+}
+
+func remoteChange(origin, updateTime, endpoint, body string, vector process.VersionVector) process.ClusterChange {
+	return process.ClusterChange{
+		ClusterEntry: process.ClusterEntry{
+			Key: process.ClusterKey("", "GET", endpoint), Method: "GET", Endpoint: endpoint,
+			Vector: vector, Origin: origin, UpdateTime: updateTime, Deleted: body == "",
+		},
+		Body: body,
+	}
+}
+
+func TestClusterApplyConcurrent(t *testing.T) {
+	n := prepareClusterNode(t, "a")
+
+	ep, err := SaveEndpoint(process.APIDataModel{Endpoint: "/c", Method: "GET", Body: process.RawMessage(`{"v":"a"}`)})
+	assert.Nil(t, err)
+	_, err = n.record(EndpointEvent{Endpoint: *ep})
+	assert.Nil(t, err)
+	assert.Equal(t, process.VersionVector{"a": 1}, clusterEntryOf(t, "GET", "/c").Vector)
+
+	// the later concurrent change wins.
+	assert.Nil(t, n.apply(remoteChange("b", "9999-01-01 00:00:00.0000", "/c", `{"v":"b"}`, process.VersionVector{"b": 1})))
+	assert.Equal(t, `{"v":"b"}`, bodyOf(t, "/c"))
+	assert.Equal(t, process.VersionVector{"a": 1, "b": 1}, clusterEntryOf(t, "GET", "/c").Vector)
+
+	// the earlier concurrent change loses, but its version is merged.
+	assert.Nil(t, n.apply(remoteChange("c", "2000-01-01 00:00:00.0000", "/c", `{"v":"c"}`, process.VersionVector{"c": 1})))
+	assert.Equal(t, `{"v":"b"}`, bodyOf(t, "/c"))
+	assert.Equal(t, process.VersionVector{"a": 1, "b": 1, "c": 1}, clusterEntryOf(t, "GET", "/c").Vector)
+
+	// the change seen is ignored.
+	assert.Nil(t, n.apply(remoteChange("b", "9999-01-01 00:00:00.0000", "/c", `{"v":"stale"}`, process.VersionVector{"b": 1})))
+	assert.Equal(t, `{"v":"b"}`, bodyOf(t, "/c"))
+
+	// the change descending the local one is applied, even an earlier delete.
+	assert.Nil(t, n.apply(remoteChange("b", "2000-01-01 00:00:00.0000", "/c", "", process.VersionVector{"a": 1, "b": 2, "c": 1})))
+	assert.Equal(t, "", bodyOf(t, "/c"))
+	assert.True(t, clusterEntryOf(t, "GET", "/c").Deleted)
+	assert.Equal(t, process.VersionVector{"a": 1, "b": 2, "c": 1}, n.vector)
+}
+
+func TestClusterRenameTombstone(t *testing.T) {
+	n := prepareClusterNode(t, "a")
+	defer func(listeners []func(EndpointEvent)) { endpointListeners = listeners }(endpointListeners)
+	OnEndpointChanged(n.onEndpointChanged)
+
+	ep, err := SaveEndpoint(process.APIDataModel{Endpoint: "/old", Method: "GET", Body: process.RawMessage(`{}`)})
+	assert.Nil(t, err)
+	_, err = SaveEndpoint(process.APIDataModel{ID: process.ID(fmt.Sprintf("%d", ep.ID)), Endpoint: "/new", Method: "POST", Body: process.RawMessage(`{}`)})
+	assert.Nil(t, err)
+
+	old := clusterEntryOf(t, "GET", "/old")
+	assert.NotNil(t, old)
+	assert.True(t, old.Deleted)
+	assert.Equal(t, process.VersionVector{"a": 3}, old.Vector)
+
+	renamed := clusterEntryOf(t, "POST", "/new")
+	assert.NotNil(t, renamed)
+	assert.False(t, renamed.Deleted)
+}
+
+func TestClusterSync(t *testing.T) {
+	n := prepareClusterNode(t, "a")
+
+	ep, err := SaveEndpoint(process.APIDataModel{Endpoint: "/local", Method: "GET", Body: process.RawMessage(`{}`)})
+	assert.Nil(t, err)
+	_, err = n.record(EndpointEvent{Endpoint: *ep})
+	assert.Nil(t, err)
+
+	remote := remoteChange("b", "2026-10-18 00:00:00.0000", "/remote", `{"from":"b"}`, process.VersionVector{"b": 1})
+	var requested []string
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/httplive/webcli/api/cluster/digest":
+			_ = json.NewEncoder(w).Encode(ClusterDigest{
+				Node: "b", Vector: remote.Vector, Entries: []process.ClusterEntry{remote.ClusterEntry},
+			})
+		case "/httplive/webcli/api/cluster/entries":
+			_ = json.NewDecoder(r.Body).Decode(&requested)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"changes": []process.ClusterChange{remote}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer peer.Close()
+
+	p := &clusterPeer{url: peer.URL, status: PeerStatus{URL: peer.URL}}
+	assert.Nil(t, n.sync(context.Background(), p))
+
+	assert.Equal(t, []string{remote.Key}, requested)
+	assert.Equal(t, `{"from":"b"}`, bodyOf(t, "/remote"))
+	assert.Equal(t, process.VersionVector{"a": 1, "b": 1}, n.vector)
+	assert.Equal(t, "b", p.status.Node)
+	assert.Equal(t, 1, p.status.Lag, "the peer has not seen /local")
+	assert.Equal(t, "", p.status.LastError)
+}
+
+func TestClusterReconcile(t *testing.T) {
+	a := prepareClusterNode(t, "a")
+
+	// the endpoints saved before the cluster mode is on.
+	_, err := SaveEndpoint(process.APIDataModel{Endpoint: "/existing", Method: "GET", Body: process.RawMessage(`{"v":1}`)})
+	assert.Nil(t, err)
+	assert.Nil(t, a.reconcile())
+	existing := EndpointList("", true)
+	assert.Equal(t, process.VersionVector{"a": uint64(len(existing))}, a.vector)
+	entry := clusterEntryOf(t, "GET", "/existing")
+	assert.NotNil(t, entry)
+
+	assert.Nil(t, a.reconcile())
+	assert.Equal(t, process.VersionVector{"a": uint64(len(existing))}, a.vector, "nothing changed")
+
+	// the endpoints updated and deleted while the cluster mode is off.
+	_, err = SaveEndpoint(process.APIDataModel{Endpoint: "/existing", Method: "GET", Body: process.RawMessage(`{"v":2}`)})
+	assert.Nil(t, err)
+	health, _ := GetByEndpoint("", "/health", "GET")
+	assert.Nil(t, DeleteEndpoint("", string(health.ID)))
+	assert.Nil(t, a.reconcile())
+	assert.Equal(t, process.VersionVector{"a": uint64(len(existing)) + 2}, a.vector)
+	assert.Equal(t, process.VersionVector{"a": uint64(len(existing)) + 1}, clusterEntryOf(t, "GET", "/existing").Vector)
+	assert.True(t, clusterEntryOf(t, "GET", "/health").Deleted)
+
+	digest, err := a.Digest()
+	assert.Nil(t, err)
+	var keys []string
+	for _, e := range digest.Entries {
+		keys = append(keys, e.Key)
+	}
+	changes, err := a.Changes(keys)
+	assert.Nil(t, err)
+	endpoints := names(EndpointList("", true))
+
+	// a new peer with no endpoints syncs from a.
+	b := prepareClusterNode(t, "b")
+	for _, m := range EndpointList("", true) {
+		assert.Nil(t, DeleteEndpoint("", string(m.ID)))
+	}
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/httplive/webcli/api/cluster/digest":
+			_ = json.NewEncoder(w).Encode(digest)
+		case "/httplive/webcli/api/cluster/entries":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"changes": changes})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer peer.Close()
+
+	p := &clusterPeer{url: peer.URL, status: PeerStatus{URL: peer.URL}}
+	assert.Nil(t, b.sync(context.Background(), p))
+	assert.Equal(t, "", p.status.LastError)
+	assert.ElementsMatch(t, endpoints, names(EndpointList("", true)))
+	assert.Equal(t, `{"v":2}`, bodyOf(t, "/existing"))
+	assert.Equal(t, digest.Vector, b.vector)
+
+	assert.Nil(t, b.reconcile())
+	assert.Equal(t, digest.Vector, b.vector, "the replicated endpoints are not changed locally")
+}
@@ -3,15 +3,15 @@ package httplive
 
 import (
 	"encoding/json"
-	"fmt"
 	"io"
 	"log"
 	"mime"
 	"net/http"
-	"os"
 	"path"
 	"strings"
+	"time"
 
+	"github.com/bingoohuang/gg/pkg/ss"
 	"github.com/bingoohuang/gg/pkg/v"
 	"github.com/bingoohuang/gor/giu"
 	"github.com/bingoohuang/httplive/internal/process"
@@ -33,9 +33,9 @@ type treeT struct {
 	giu.T `url:"GET /api/tree"`
 }
 
-// Tree return the api tree.
-func (ctrl WebCliController) Tree(_ treeT) gin.H {
-	apis := EndpointList(true)
+// Tree return the api tree of the workspace.
+func (ctrl WebCliController) Tree(c *gin.Context, _ treeT) gin.H {
+	apis := EndpointList(workspaceOf(c), true)
 	trees := make([]process.JsTreeDataModel, len(apis))
 
 	for i, api := range apis {
@@ -49,8 +49,15 @@ type backupT struct {
 	giu.T `url:"GET /api/backup"`
 }
 
-// Backup ...
+// Backup backups the whole bolt db file, or the endpoints of the workspace only when it is specified.
 func (ctrl WebCliController) Backup(c *gin.Context, _ backupT) {
+	if workspace := workspaceOf(c); workspace != "" {
+		if err := BackupWorkspace(c.Writer, workspace); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
 	_ = DBDo(func(dao *Dao) error {
 		dao.Backup(c.Writer, path.Base(Envs.DBFile))
 		return nil
@@ -63,7 +70,7 @@ type downloadFileT struct {
 
 // DownloadFile ...
 func (ctrl WebCliController) DownloadFile(c *gin.Context, _ downloadFileT) error {
-	model, err := GetEndpoint(process.ID(c.Query("id")))
+	model, err := GetEndpoint(workspaceOf(c), process.ID(c.Query("id")))
 	if err != nil {
 		return err
 	}
@@ -88,9 +95,9 @@ func (ctrl WebCliController) Endpoint(c *gin.Context, _ endpointT) (giu.HTTPStat
 	var err error
 
 	if s := c.Query("id"); s != "" {
-		model, err = GetEndpoint(process.ID(s))
+		model, err = GetEndpoint(workspaceOf(c), process.ID(s))
 	} else if s = c.Query("endpoint"); s != "" {
-		model, err = GetByEndpoint(s)
+		model, err = GetByEndpoint(workspaceOf(c), s, c.Query("method"))
 	}
 
 	if err != nil {
@@ -143,63 +150,6 @@ type saveT struct {
 	giu.T `url:"POST /api/save"`
 }
 
-type ReqFile struct {
-	ID       string `json:"id"`
-	Endpoint string `json:"endpoint"`
-	Path     string `json:"path"`
-	Method   string `json:"method"`
-	// Body @body.json 或者 直接内嵌 JSON
-	Body json.RawMessage `json:"body"`
-}
-
-func ProcessReqFile(reqFile string) {
-	if err := ProcessReq(reqFile); err != nil {
-		os.WriteFile(reqFile+".result", []byte(err.Error()), os.ModePerm)
-	} else {
-		os.WriteFile(reqFile+".ok", nil, os.ModePerm)
-	}
-}
-
-func ProcessReq(reqFile string) error {
-	req := &ReqFile{}
-	data, err := os.ReadFile(reqFile)
-	if err != nil {
-		return fmt.Errorf("read file %s: %w", reqFile, err)
-	}
-	if err := json.Unmarshal(data, req); err != nil {
-		return fmt.Errorf("unmarshal json from file %s: %w", reqFile, err)
-	}
-
-	if req.Endpoint == "" {
-		req.Endpoint = req.Path
-	}
-	if req.Method == "" {
-		req.Method = "ANY"
-	}
-
-	strBody := string(req.Body)
-	if strings.HasPrefix(strBody, `"@`) {
-		bodyFile := strBody[2 : len(strBody)-1]
-		bodyData, err := os.ReadFile(bodyFile)
-		if err != nil {
-			return fmt.Errorf("read file %s: %w", bodyFile, err)
-		}
-		req.Body = bodyData
-	}
-
-	model := process.APIDataModel{
-		Endpoint: req.Endpoint,
-		Method:   req.Method,
-		ID:       process.ID(req.ID),
-		Body:     process.RawMessage(req.Body),
-	}
-	if _, err := SaveEndpoint(model); err != nil {
-		return fmt.Errorf("save endpoint: %v", err)
-	}
-
-	return nil
-}
-
 // Save 保存body.
 func (ctrl WebCliController) Save(c *gin.Context, _ saveT) (giu.HTTPStatus, interface{}) {
 	id := c.Query("id")
@@ -224,8 +174,9 @@ func (ctrl WebCliController) Save(c *gin.Context, _ saveT) (giu.HTTPStatus, inte
 		model.Body = process.RawMessage(body)
 		model.ID = process.ID(id)
 	}
+	model.Workspace = ss.Or(model.Workspace, workspaceOf(c))
 
-	dp, err := SaveEndpoint(model)
+	dp, err := SaveEndpointBy(model, requestAuthor(c))
 	if err != nil {
 		return giu.HTTPStatus(http.StatusBadRequest), gin.H{"error": err.Error()}
 	}
@@ -245,8 +196,9 @@ func (ctrl WebCliController) SaveEndpoint(model process.APIDataModel, c *gin.Con
 		model.Filename = filename
 		model.FileContent = fileContent
 	}
+	model.Workspace = ss.Or(model.Workspace, workspaceOf(c))
 
-	if dp, err := SaveEndpoint(model); err != nil {
+	if dp, err := SaveEndpointBy(model, requestAuthor(c)); err != nil {
 		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 	} else {
 		c.IndentedJSON(http.StatusOK, gin.H{"data": dp})
@@ -273,7 +225,434 @@ type deleteEndpointT struct {
 
 // DeleteEndpoint ...
 func (ctrl WebCliController) DeleteEndpoint(c *gin.Context, _ deleteEndpointT) {
-	_ = DeleteEndpoint(c.Query("id"))
+	_ = DeleteEndpoint(workspaceOf(c), c.Query("id"))
 
 	c.IndentedJSON(http.StatusOK, gin.H{"success": "ok"})
 }
+
+type scenariosT struct {
+	giu.T `url:"GET /api/scenarios"`
+}
+
+// Scenarios lists the states of the scenarios in the workspace, or the state of the scenario specified by name.
+func (ctrl WebCliController) Scenarios(c *gin.Context, _ scenariosT) gin.H {
+	workspace := workspaceOf(c)
+	if name := c.Query("name"); name != "" {
+		return gin.H{"name": name, "state": process.Scenarios.Get(workspace, name)}
+	}
+
+	return gin.H{"scenarios": process.Scenarios.All(workspace)}
+}
+
+type resetScenariosT struct {
+	giu.T `url:"POST /api/scenarios/reset"`
+}
+
+// ResetScenarios resets the scenario in the workspace specified by name to its initial state,
+// or all the scenarios of the workspace when name is empty.
+func (ctrl WebCliController) ResetScenarios(c *gin.Context, _ resetScenariosT) gin.H {
+	workspace := workspaceOf(c)
+	process.Scenarios.Reset(workspace, c.Query("name"))
+
+	return gin.H{"scenarios": process.Scenarios.All(workspace)}
+}
+
+type sequencesT struct {
+	giu.T `url:"GET /api/sequences"`
+}
+
+// Sequences lists the counts of the `_sequence` responses, keyed by the sequence of the endpoint and the scope value.
+func (ctrl WebCliController) Sequences(_ sequencesT) gin.H {
+	return gin.H{"sequences": process.SequenceCounts()}
+}
+
+type resetSequencesT struct {
+	giu.T `url:"POST /api/sequences/reset"`
+}
+
+// ResetSequences resets the sequence of the endpoint (and method) in the workspace, or all the sequences when endpoint is empty.
+func (ctrl WebCliController) ResetSequences(c *gin.Context, _ resetSequencesT) gin.H {
+	n := process.ResetSequences(workspaceOf(c), strings.ToUpper(c.Query("method")), c.Query("endpoint"))
+
+	return gin.H{"reset": n, "sequences": process.SequenceCounts()}
+}
+
+type resourcesT struct {
+	giu.T `url:"GET /api/resources"`
+}
+
+// Resources lists the numbers of the items of the `resource` collections in memory, keyed by the workspace and the endpoint.
+func (ctrl WebCliController) Resources(_ resourcesT) gin.H {
+	return gin.H{"resources": process.ResourceCounts()}
+}
+
+type resetResourcesT struct {
+	giu.T `url:"POST /api/resources/reset"`
+}
+
+// ResetResources reseeds the `resource` collection of the endpoint in the workspace, or all the collections when endpoint is empty.
+func (ctrl WebCliController) ResetResources(c *gin.Context, _ resetResourcesT) gin.H {
+	n := process.ResetResources(workspaceOf(c), c.Query("endpoint"))
+
+	return gin.H{"reset": n, "resources": process.ResourceCounts()}
+}
+
+type importOpenAPIT struct {
+	giu.T `url:"POST /api/openapi"`
+}
+
+// ImportOpenAPI imports the OpenAPI 3 / Swagger 2 document in the request body as endpoints.
+func (ctrl WebCliController) ImportOpenAPI(c *gin.Context, _ importOpenAPIT) (giu.HTTPStatus, interface{}) {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return giu.HTTPStatus(http.StatusBadRequest), gin.H{"error": err.Error()}
+	}
+
+	result, err := ImportOpenAPI(workspaceOf(c), data)
+	if err != nil {
+		return giu.HTTPStatus(http.StatusBadRequest), gin.H{"error": err.Error()}
+	}
+
+	return giu.HTTPStatus(http.StatusOK), result
+}
+
+type exportOpenAPIT struct {
+	giu.T `url:"GET /api/openapi"`
+}
+
+// ExportOpenAPI exports the OpenAPI 3 document of the endpoints, in YAML when format=yaml, or in JSON.
+func (ctrl WebCliController) ExportOpenAPI(c *gin.Context, _ exportOpenAPIT) {
+	doc := ExportOpenAPI(workspaceOf(c))
+	if c.Query("format") == "yaml" {
+		c.YAML(http.StatusOK, doc)
+	} else {
+		c.IndentedJSON(http.StatusOK, doc)
+	}
+}
+
+type requestsT struct {
+	giu.T `url:"GET /api/requests"`
+}
+
+// Requests lists the journal of the served requests, the latest first,
+// filtered by endpoint, method, status, since, until, body, workspace and limit (default 100).
+func (ctrl WebCliController) Requests(c *gin.Context, _ requestsT) (giu.HTTPStatus, interface{}) {
+	filter := process.JournalFilter{
+		Endpoint:  c.Query("endpoint"),
+		Method:    c.Query("method"),
+		Body:      c.Query("body"),
+		Status:    ss.ParseInt(c.Query("status")),
+		Workspace: workspaceOf(c),
+		Limit:     100,
+	}
+	if s := c.Query("limit"); s != "" {
+		filter.Limit = ss.ParseInt(s)
+	}
+
+	var err error
+	if filter.Since, err = parseJournalTime(c.Query("since")); err != nil {
+		return giu.HTTPStatus(http.StatusBadRequest), gin.H{"error": "bad since: " + err.Error()}
+	}
+	if filter.Until, err = parseJournalTime(c.Query("until")); err != nil {
+		return giu.HTTPStatus(http.StatusBadRequest), gin.H{"error": "bad until: " + err.Error()}
+	}
+
+	flushJournal()
+	var entries []process.JournalEntry
+	if err := DBDo(func(dao *Dao) (err error) {
+		entries, err = dao.ListJournal(filter)
+		return err
+	}); err != nil {
+		return giu.HTTPStatus(http.StatusInternalServerError), gin.H{"error": err.Error()}
+	}
+
+	return giu.HTTPStatus(http.StatusOK), gin.H{"requests": entries}
+}
+
+// parseJournalTime parses the time in RFC3339, in the format of the websocket messages,
+// or the duration before now like 5m.
+func parseJournalTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05.0000", s, time.Local); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+type clearRequestsT struct {
+	giu.T `url:"POST /api/requests/clear"`
+}
+
+// ClearRequests clears the journal of the served requests.
+func (ctrl WebCliController) ClearRequests(_ clearRequestsT) (giu.HTTPStatus, interface{}) {
+	flushJournal()
+	if err := DBDo(func(dao *Dao) error { return dao.ClearJournal() }); err != nil {
+		return giu.HTTPStatus(http.StatusInternalServerError), gin.H{"error": err.Error()}
+	}
+
+	return giu.HTTPStatus(http.StatusOK), gin.H{"success": "ok"}
+}
+
+type verifyT struct {
+	giu.T `url:"POST /api/verify"`
+}
+
+// Verify verifies how many times the requests in the journal, of the workspace when specified, matched the criteria.
+func (ctrl WebCliController) Verify(c *gin.Context, _ verifyT) (giu.HTTPStatus, interface{}) {
+	var verification process.Verification
+	if err := decodeJSON(c.Request.Body, &verification); err != nil {
+		return giu.HTTPStatus(http.StatusBadRequest), gin.H{"error": err.Error()}
+	}
+	if err := verification.Compile(); err != nil {
+		return giu.HTTPStatus(http.StatusBadRequest), gin.H{"error": err.Error()}
+	}
+
+	flushJournal()
+	var entries []process.JournalEntry
+	if err := DBDo(func(dao *Dao) (err error) {
+		entries, err = dao.ListJournal(process.JournalFilter{Workspace: workspaceOf(c)})
+		return err
+	}); err != nil {
+		return giu.HTTPStatus(http.StatusInternalServerError), gin.H{"error": err.Error()}
+	}
+
+	return giu.HTTPStatus(http.StatusOK), verification.Verify(entries)
+}
+
+// requestAuthor returns the user name of the basic auth of the request, or empty.
+func requestAuthor(c *gin.Context) string {
+	user, _, _ := c.Request.BasicAuth()
+	return user
+}
+
+type revisionsT struct {
+	giu.T `url:"GET /api/revisions"`
+}
+
+// Revisions lists the revisions of the endpoint specified by id, the latest first.
+func (ctrl WebCliController) Revisions(c *gin.Context, _ revisionsT) (giu.HTTPStatus, interface{}) {
+	revisions, err := ListRevisions(workspaceOf(c), process.ID(c.Query("id")).Int())
+	if err != nil {
+		return giu.HTTPStatus(http.StatusInternalServerError), gin.H{"error": err.Error()}
+	}
+
+	return giu.HTTPStatus(http.StatusOK), gin.H{"revisions": revisions}
+}
+
+type diffRevisionsT struct {
+	giu.T `url:"GET /api/revisions/diff"`
+}
+
+// DiffRevisions diffs the revision from to the revision to, or to the current endpoint when to is absent.
+func (ctrl WebCliController) DiffRevisions(c *gin.Context, _ diffRevisionsT) {
+	diff, err := DiffRevisions(workspaceOf(c), process.ID(c.Query("from")).Int(), process.ID(c.Query("to")).Int())
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, "text/x-diff; charset=utf-8", []byte(diff))
+}
+
+type restoreRevisionT struct {
+	giu.T `url:"POST /api/revisions/restore"`
+}
+
+// RestoreRevision restores the endpoint to the revision specified by id.
+func (ctrl WebCliController) RestoreRevision(c *gin.Context, _ restoreRevisionT) (giu.HTTPStatus, interface{}) {
+	ep, err := RestoreRevision(workspaceOf(c), process.ID(c.Query("id")).Int(), requestAuthor(c))
+	if err != nil {
+		return giu.HTTPStatus(http.StatusBadRequest), gin.H{"error": err.Error()}
+	}
+
+	return giu.HTTPStatus(http.StatusOK), gin.H{"data": ep}
+}
+
+// workspaceOf returns the workspace the admin request is scoped to, empty for the default workspace.
+func workspaceOf(c *gin.Context) string {
+	return c.Query("workspace")
+}
+
+type workspacesT struct {
+	giu.T `url:"GET /api/workspaces"`
+}
+
+// Workspaces lists the named workspaces.
+func (ctrl WebCliController) Workspaces(_ workspacesT) gin.H {
+	return gin.H{"workspaces": ListWorkspaces()}
+}
+
+type saveWorkspaceT struct {
+	giu.T `url:"POST /api/workspaces"`
+}
+
+// SaveWorkspace adds or updates the workspace with its name, ports, hosts and prefix.
+func (ctrl WebCliController) SaveWorkspace(c *gin.Context, _ saveWorkspaceT) (giu.HTTPStatus, interface{}) {
+	var w process.Workspace
+	if err := decodeJSON(c.Request.Body, &w); err != nil {
+		return giu.HTTPStatus(http.StatusBadRequest), gin.H{"error": err.Error()}
+	}
+
+	saved, err := SaveWorkspace(w)
+	if err != nil {
+		return giu.HTTPStatus(http.StatusBadRequest), gin.H{"error": err.Error()}
+	}
+
+	return giu.HTTPStatus(http.StatusOK), gin.H{"data": saved}
+}
+
+type deleteWorkspaceT struct {
+	giu.T `url:"POST /api/workspaces/delete"`
+}
+
+// DeleteWorkspace deletes the workspace specified by name with all its endpoints.
+func (ctrl WebCliController) DeleteWorkspace(c *gin.Context, _ deleteWorkspaceT) (giu.HTTPStatus, interface{}) {
+	if err := DeleteWorkspace(c.Query("name")); err != nil {
+		return giu.HTTPStatus(http.StatusBadRequest), gin.H{"error": err.Error()}
+	}
+
+	return giu.HTTPStatus(http.StatusOK), gin.H{"success": "ok"}
+}
+
+type exportT struct {
+	giu.T `url:"GET /api/export"`
+}
+
+// Export exports all the endpoints of the workspace as a JSON bundle, or a zip bundle when format=zip.
+func (ctrl WebCliController) Export(c *gin.Context, _ exportT) {
+	bundle, err := ExportBundle(workspaceOf(c))
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := "httplive-" + ss.Or(workspaceOf(c), "endpoints")
+	if c.Query("format") == "zip" {
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + ".zip"}))
+		if err := bundle.WriteZip(c.Writer); err != nil {
+			log.Printf("write zip bundle failed: %v", err)
+		}
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + ".json"}))
+	c.IndentedJSON(http.StatusOK, bundle)
+}
+
+type importT struct {
+	giu.T `url:"POST /api/import"`
+}
+
+// Import imports the JSON or zip bundle in the request body, or in the uploaded file,
+// with mode=merge (default), overwrite or skip, and dryRun=true to report only.
+func (ctrl WebCliController) Import(c *gin.Context, _ importT) (giu.HTTPStatus, interface{}) {
+	_, _, data := parseFileContent(c)
+	if data == nil {
+		var err error
+		if data, err = io.ReadAll(c.Request.Body); err != nil {
+			return giu.HTTPStatus(http.StatusBadRequest), gin.H{"error": err.Error()}
+		}
+	}
+
+	bundle, err := ParseBundle(data)
+	if err != nil {
+		return giu.HTTPStatus(http.StatusBadRequest), gin.H{"error": err.Error()}
+	}
+
+	dryRun := c.Query("dryRun") == "true"
+	report, err := ImportBundle(workspaceOf(c), *bundle, ImportMode(c.Query("mode")), dryRun, requestAuthor(c))
+	if err != nil {
+		return giu.HTTPStatus(http.StatusBadRequest), gin.H{"error": err.Error()}
+	}
+
+	if !dryRun && !report.Applied {
+		return giu.HTTPStatus(http.StatusConflict), report
+	}
+	return giu.HTTPStatus(http.StatusOK), report
+}
+
+type provisionT struct {
+	giu.T `url:"GET /api/provision"`
+}
+
+// Provision returns the report of the last reconciliation of the provisioning directory.
+func (ctrl WebCliController) Provision(_ *gin.Context, _ provisionT) (giu.HTTPStatus, interface{}) {
+	report := LastProvisionReport()
+	if report == nil {
+		return giu.HTTPStatus(http.StatusNotFound), gin.H{"error": "no provisioning directory, see --provision"}
+	}
+	return giu.HTTPStatus(http.StatusOK), report
+}
+
+type clusterT struct {
+	giu.T `url:"GET /api/cluster"`
+}
+
+// Cluster returns the replication status of the node, with how far each peer lags.
+func (ctrl WebCliController) Cluster(_ *gin.Context, _ clusterT) (giu.HTTPStatus, interface{}) {
+	if cluster == nil {
+		return giu.HTTPStatus(http.StatusNotFound), gin.H{"error": ErrClusterOff.Error()}
+	}
+	return giu.HTTPStatus(http.StatusOK), cluster.Status()
+}
+
+type clusterDigestT struct {
+	giu.T `url:"GET /api/cluster/digest"`
+}
+
+// ClusterDigest returns the versions of all the endpoints for the peers to find the changes missed.
+func (ctrl WebCliController) ClusterDigest(_ *gin.Context, _ clusterDigestT) (giu.HTTPStatus, interface{}) {
+	if cluster == nil {
+		return giu.HTTPStatus(http.StatusNotFound), gin.H{"error": ErrClusterOff.Error()}
+	}
+	digest, err := cluster.Digest()
+	if err != nil {
+		return giu.HTTPStatus(http.StatusInternalServerError), gin.H{"error": err.Error()}
+	}
+	return giu.HTTPStatus(http.StatusOK), digest
+}
+
+type clusterEntriesT struct {
+	giu.T `url:"POST /api/cluster/entries"`
+}
+
+// ClusterEntries returns the changes of the endpoints of the keys in the request body.
+func (ctrl WebCliController) ClusterEntries(c *gin.Context, _ clusterEntriesT) (giu.HTTPStatus, interface{}) {
+	if cluster == nil {
+		return giu.HTTPStatus(http.StatusNotFound), gin.H{"error": ErrClusterOff.Error()}
+	}
+	var keys []string
+	if err := c.ShouldBindJSON(&keys); err != nil {
+		return giu.HTTPStatus(http.StatusBadRequest), gin.H{"error": err.Error()}
+	}
+	changes, err := cluster.Changes(keys)
+	if err != nil {
+		return giu.HTTPStatus(http.StatusInternalServerError), gin.H{"error": err.Error()}
+	}
+	return giu.HTTPStatus(http.StatusOK), gin.H{"changes": changes}
+}
+
+type clusterChangesT struct {
+	giu.T `url:"POST /api/cluster/changes"`
+}
+
+// ClusterChanges applies the changes pushed by a peer, and returns the errors of the ones failed.
+func (ctrl WebCliController) ClusterChanges(c *gin.Context, _ clusterChangesT) (giu.HTTPStatus, interface{}) {
+	if cluster == nil {
+		return giu.HTTPStatus(http.StatusNotFound), gin.H{"error": ErrClusterOff.Error()}
+	}
+	var changes []process.ClusterChange
+	if err := c.ShouldBindJSON(&changes); err != nil {
+		return giu.HTTPStatus(http.StatusBadRequest), gin.H{"error": err.Error()}
+	}
+	return giu.HTTPStatus(http.StatusOK), gin.H{"errors": cluster.Apply(changes)}
+}
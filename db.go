@@ -1,6 +1,7 @@
 package httplive
 
 import (
+	"bytes"
 	"context"
 	"embed"
 	"encoding/json"
@@ -20,7 +21,6 @@ import (
 	"time"
 
 	"github.com/asdine/storm/v3"
-	"github.com/bingoohuang/gg/pkg/iox"
 	"github.com/bingoohuang/gg/pkg/ss"
 	"github.com/bingoohuang/gg/pkg/v"
 	"github.com/bingoohuang/golog/pkg/hlog"
@@ -31,64 +31,148 @@ import (
 	"github.com/bingoohuang/httplive/pkg/util"
 	"github.com/bingoohuang/jj"
 	"github.com/gin-gonic/gin"
-	"github.com/julienschmidt/httprouter"
 	"github.com/mssola/user_agent"
 	"go.etcd.io/bbolt"
 )
 
 // Dao defines the api to access the database.
 type Dao struct {
-	db *storm.DB
+	db        storm.Node
+	store     *storm.DB
+	endpoints EndpointStore
 }
 
-// HasEndpoints tests if any endpoint exits already.
-func (d *Dao) HasEndpoints() (has bool) {
-	var result []process.Endpoint
-	if err := d.db.All(&result, storm.Limit(1)); err != nil {
-		log.Printf("ForEach error: %v", err)
+// workspacesBucket is the bucket holding the endpoints of the named workspaces, one nested bucket per workspace.
+const workspacesBucket = "workspaces"
+
+// Workspace returns the dao of the endpoints and their revisions in the workspace,
+// the default workspace when name is empty.
+func (d *Dao) Workspace(name string) *Dao {
+	db := storm.Node(d.store)
+	if name != "" {
+		db = d.store.From(workspacesBucket, name)
 	}
 
-	return len(result) > 0
+	return &Dao{db: db, store: d.store, endpoints: endpointStoreOf(name, db)}
 }
 
-// ListEndpoints lists endpoints.
-func (d *Dao) ListEndpoints() (result []process.Endpoint) {
-	if err := d.db.All(&result); err != nil {
+// endpointStoreOf returns the store of the endpoints in the workspace, the directory one with the --dir flag.
+func endpointStoreOf(workspace string, node storm.Node) EndpointStore {
+	if Envs.EndpointDir != "" {
+		return dirStoreOf(workspaceDir(Envs.EndpointDir, workspace))
+	}
+
+	return boltStore{node: node}
+}
+
+// ListWorkspaces lists the named workspaces.
+func (d *Dao) ListWorkspaces() (result []process.Workspace) {
+	if Envs.EndpointDir != "" {
+		result, err := listWorkspaceFiles(Envs.EndpointDir)
+		if err != nil {
+			log.Printf("list workspaces error: %v", err)
+		}
+		return result
+	}
+
+	if err := d.store.All(&result); err != nil {
 		log.Printf("ForEach error: %v", err)
 	}
 	return
 }
 
-// FindEndpoint finds endpoint with specified ID.
-func (d *Dao) FindEndpoint(id uint64) *process.Endpoint {
-	result := &process.Endpoint{}
-	err := d.db.One("ID", id, result)
+// FindWorkspace finds the workspace by its name.
+func (d *Dao) FindWorkspace(name string) *process.Workspace {
+	if Envs.EndpointDir != "" {
+		for _, w := range d.ListWorkspaces() {
+			if w.Name == name {
+				return &w
+			}
+		}
+		return nil
+	}
+
+	result := &process.Workspace{}
+	err := d.store.One("Name", name, result)
 	if errors.Is(err, storm.ErrNotFound) {
 		return nil
 	}
 	if err != nil {
 		log.Printf("find error: %v", err)
+		return nil
 	}
+
 	return result
 }
 
-// FindByEndpoint finds endpoint by its value.
-func (d *Dao) FindByEndpoint(endpoint string) *process.Endpoint {
-	result := &process.Endpoint{}
-	err := d.db.One("Endpoint", endpoint, result)
-	if errors.Is(err, storm.ErrNotFound) {
-		return nil
+// SaveWorkspace adds or updates the workspace.
+func (d *Dao) SaveWorkspace(w process.Workspace) error {
+	if Envs.EndpointDir != "" {
+		return writeWorkspaceFile(workspaceDir(Envs.EndpointDir, w.Name), w)
+	}
+
+	return d.store.Save(&w)
+}
+
+// DeleteWorkspace deletes the workspace with all its endpoints and their revisions.
+func (d *Dao) DeleteWorkspace(w process.Workspace) error {
+	if Envs.EndpointDir != "" {
+		dir := workspaceDir(Envs.EndpointDir, w.Name)
+		dropDirStore(dir)
+		if err := removeAllOwn(dir); err != nil {
+			return err
+		}
 	}
+
+	if err := d.store.From(workspacesBucket).Drop(w.Name); err != nil && !errors.Is(err, bbolt.ErrBucketNotFound) {
+		return err
+	}
+
+	return d.store.DeleteStruct(&w)
+}
+
+// HasEndpoints tests if any endpoint exits already.
+func (d *Dao) HasEndpoints() (has bool) {
+	return len(d.ListEndpoints()) > 0
+}
+
+// ListEndpoints lists endpoints.
+func (d *Dao) ListEndpoints() []process.Endpoint {
+	result, err := d.endpoints.ListEndpoints()
 	if err != nil {
-		log.Printf("find error: %v", err)
+		log.Printf("ForEach error: %v", err)
 	}
+	return result
+}
 
+// FindEndpoint finds endpoint with specified ID.
+func (d *Dao) FindEndpoint(id uint64) *process.Endpoint {
+	result, err := d.endpoints.FindEndpoint(id)
+	if err != nil {
+		log.Printf("find error: %v", err)
+	}
 	return result
 }
 
+// FindByEndpoint finds endpoint by its path and method, the first one of the path when method is empty.
+func (d *Dao) FindByEndpoint(endpoint, method string) *process.Endpoint {
+	result, err := d.endpoints.FindByEndpoint(endpoint)
+	if err != nil {
+		log.Printf("find error: %v", err)
+	}
+
+	for i, ep := range result {
+		if method == "" || strings.EqualFold(ep.Methods, method) {
+			return &result[i]
+		}
+	}
+
+	return nil
+}
+
 // AddEndpoint adds a endpoint.
 func (d *Dao) AddEndpoint(ep process.Endpoint) uint64 {
-	if err := d.db.Save(&ep); err != nil {
+	if err := d.endpoints.AddEndpoint(&ep); err != nil {
 		log.Printf("insert error: %v", err)
 	}
 
@@ -97,21 +181,222 @@ func (d *Dao) AddEndpoint(ep process.Endpoint) uint64 {
 
 // UpdateEndpoint updates a endpoint.
 func (d *Dao) UpdateEndpoint(ep process.Endpoint) {
-	if err := d.db.Update(&ep); err != nil {
+	if err := d.endpoints.UpdateEndpoint(ep); err != nil {
 		log.Printf("Update error: %v", err)
 	}
 }
 
 // DeleteEndpoint delete a endpoint.
 func (d *Dao) DeleteEndpoint(ep process.Endpoint) {
-	if err := d.db.DeleteStruct(&ep); err != nil {
+	if err := d.endpoints.DeleteEndpoint(ep); err != nil {
 		log.Printf("Delete error: %v", err)
 	}
 }
 
+// AddRevision adds a revision of the endpoint.
+func (d *Dao) AddRevision(rev process.EndpointRevision) error {
+	return d.db.Save(&rev)
+}
+
+// ListRevisions lists the revisions of the endpoint, the latest first.
+func (d *Dao) ListRevisions(endpointID uint64) ([]process.EndpointRevision, error) {
+	result := make([]process.EndpointRevision, 0)
+	err := d.db.Find("EndpointID", endpointID, &result, storm.Reverse())
+	if errors.Is(err, storm.ErrNotFound) {
+		err = nil
+	}
+
+	return result, err
+}
+
+// AllRevisions lists all the revisions.
+func (d *Dao) AllRevisions() (result []process.EndpointRevision) {
+	if err := d.db.All(&result); err != nil {
+		log.Printf("ForEach error: %v", err)
+	}
+	return
+}
+
+// FindRevision finds the revision by its ID.
+func (d *Dao) FindRevision(id uint64) *process.EndpointRevision {
+	result := &process.EndpointRevision{}
+	err := d.db.One("ID", id, result)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("find error: %v", err)
+		return nil
+	}
+
+	return result
+}
+
+// SaveProxyCapture saves a proxy capture, the capture with the same key is replaced.
+func (d *Dao) SaveProxyCapture(capture process.ProxyCapture) error {
+	return d.db.Save(&capture)
+}
+
+// FindProxyCapture finds proxy capture by its key.
+func (d *Dao) FindProxyCapture(key string) *process.ProxyCapture {
+	result := &process.ProxyCapture{}
+	err := d.db.One("Key", key, result)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("find error: %v", err)
+		return nil
+	}
+
+	return result
+}
+
+// AppendJournal appends the entries to the request journal in one transaction, and drops the oldest entries beyond max.
+func (d *Dao) AppendJournal(entries []process.JournalEntry, max int) error {
+	tx, err := d.db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := range entries {
+		if err := tx.Save(&entries[i]); err != nil {
+			return err
+		}
+	}
+
+	last := entries[len(entries)-1].ID
+	for {
+		var oldest []process.JournalEntry
+		if err := tx.All(&oldest, storm.Limit(len(entries))); err != nil {
+			return err
+		}
+
+		deleted := 0
+		for i := range oldest {
+			if oldest[i].ID+uint64(max) > last {
+				break
+			}
+			if err := tx.DeleteStruct(&oldest[i]); err != nil {
+				return err
+			}
+			deleted++
+		}
+		if deleted < len(entries) {
+			return tx.Commit()
+		}
+	}
+}
+
+// ListJournal lists the journal entries matching the filter, the latest first.
+func (d *Dao) ListJournal(filter process.JournalFilter) ([]process.JournalEntry, error) {
+	result := make([]process.JournalEntry, 0)
+	err := d.db.Select().Reverse().Each(new(process.JournalEntry), func(record interface{}) error {
+		if entry := record.(*process.JournalEntry); filter.Match(*entry) {
+			result = append(result, *entry)
+		}
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			return errStopEach
+		}
+		return nil
+	})
+	if errors.Is(err, errStopEach) {
+		err = nil
+	}
+
+	return result, err
+}
+
+var errStopEach = errors.New("stop each")
+
+// ClearJournal removes all the entries of the request journal.
+func (d *Dao) ClearJournal() error {
+	if err := d.db.Drop(new(process.JournalEntry)); err != nil && !errors.Is(err, bbolt.ErrBucketNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// ListProvisionRecords lists the records of the provisioned files.
+func (d *Dao) ListProvisionRecords() (result []process.ProvisionRecord, err error) {
+	err = d.store.All(&result)
+	return result, err
+}
+
+// SaveProvisionRecord saves the record of the provisioned file.
+func (d *Dao) SaveProvisionRecord(record process.ProvisionRecord) error {
+	return d.store.Save(&record)
+}
+
+// DeleteProvisionRecord deletes the record of the provisioned file.
+func (d *Dao) DeleteProvisionRecord(record process.ProvisionRecord) error {
+	return d.store.DeleteStruct(&record)
+}
+
+// ListClusterEntries lists the replicated versions of the endpoints.
+func (d *Dao) ListClusterEntries() (result []process.ClusterEntry, err error) {
+	err = d.store.From(clusterBucket).All(&result)
+	return result, err
+}
+
+// FindClusterEntry finds the replicated version of the endpoint by its key, nil when not found.
+func (d *Dao) FindClusterEntry(key string) (*process.ClusterEntry, error) {
+	entry := &process.ClusterEntry{}
+	if err := d.store.From(clusterBucket).One("Key", key, entry); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			err = nil
+		}
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// SaveClusterEntry saves the replicated version of the endpoint.
+func (d *Dao) SaveClusterEntry(entry process.ClusterEntry) error {
+	return d.store.From(clusterBucket).Save(&entry)
+}
+
+// FindResourceState finds the persisted collection of the resource endpoint by its key, nil when not found.
+func (d *Dao) FindResourceState(key string) (*process.ResourceState, error) {
+	state := &process.ResourceState{}
+	if err := d.store.From(resourcesBucket).One("Key", key, state); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			err = nil
+		}
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// SaveResourceState saves the collection of the resource endpoint.
+func (d *Dao) SaveResourceState(state process.ResourceState) error {
+	return d.store.From(resourcesBucket).Save(&state)
+}
+
+// DeleteResourceState deletes the persisted collection of the resource endpoint by its key.
+func (d *Dao) DeleteResourceState(key string) error {
+	err := d.store.From(resourcesBucket).DeleteStruct(&process.ResourceState{Key: key})
+	if errors.Is(err, storm.ErrNotFound) || errors.Is(err, bbolt.ErrBucketNotFound) {
+		return nil
+	}
+	return err
+}
+
+// DeleteResourceStates deletes all the persisted collections of the resource endpoints.
+func (d *Dao) DeleteResourceStates() error {
+	if err := d.store.Drop(resourcesBucket); err != nil && !errors.Is(err, bbolt.ErrBucketNotFound) {
+		return err
+	}
+	return nil
+}
+
 // Backup backups a bolt db file.
 func (d *Dao) Backup(w http.ResponseWriter, name string) {
-	err := d.db.Bolt.View(func(tx *bbolt.Tx) error {
+	err := d.store.Bolt.View(func(tx *bbolt.Tx) error {
 		w.Header().Set("Content-Type", "application/octet-stream")
 		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
 		w.Header().Set("Content-Length", strconv.Itoa(int(tx.Size())))
@@ -125,7 +410,7 @@ func (d *Dao) Backup(w http.ResponseWriter, name string) {
 
 // CreateDao creates a dao.
 func CreateDao(db *storm.DB) (*Dao, error) {
-	return &Dao{db: db}, nil
+	return &Dao{db: db, store: db, endpoints: endpointStoreOf("", db)}, nil
 }
 
 var (
@@ -136,6 +421,35 @@ var (
 
 func init() {
 	subAssets, _ = fs.Sub(assetsFS, "assets")
+	process.SaveProxyCapture = func(capture process.ProxyCapture) error {
+		return DBDo(func(dao *Dao) error { return dao.SaveProxyCapture(capture) })
+	}
+	process.FindProxyCapture = func(key string) (capture *process.ProxyCapture) {
+		_ = DBDo(func(dao *Dao) error {
+			capture = dao.FindProxyCapture(key)
+			return nil
+		})
+		return capture
+	}
+	process.SaveResourceState = func(state process.ResourceState) error {
+		return DBDo(func(dao *Dao) error { return dao.SaveResourceState(state) })
+	}
+	process.FindResourceState = func(key string) (state *process.ResourceState) {
+		_ = DBDo(func(dao *Dao) error {
+			var err error
+			if state, err = dao.FindResourceState(key); err != nil {
+				log.Printf("E! find resource %s: %v", key, err)
+			}
+			return nil
+		})
+		return state
+	}
+	process.DeleteResourceState = func(key string) error {
+		return DBDo(func(dao *Dao) error { return dao.DeleteResourceState(key) })
+	}
+	process.DeleteResourceStates = func() error {
+		return DBDo(func(dao *Dao) error { return dao.DeleteResourceStates() })
+	}
 	process.DirListTemplate = func() *template.Template {
 		t, err := template.New("dirlist").
 			Funcs(template.FuncMap{
@@ -194,20 +508,29 @@ func timeToString(t time.Time, _ ...string) string {
 	return timeago.Format(time.Now(), t, false)
 }
 
-var dbLock sync.Mutex
+var (
+	dbLock sync.Mutex
+	// store is the long-lived DB handle, opened on the first DBDo.
+	store *storm.DB
+)
 
 // DBDo executes the f.
 func DBDo(f func(dao *Dao) error) error {
 	dbLock.Lock()
 	defer dbLock.Unlock()
 
-	db, err := storm.Open(Envs.DBFile)
-	if err != nil {
-		return fmt.Errorf("store open %q: %w", Envs.DBFile, err)
+	if store == nil {
+		db, err := storm.Open(Envs.DBFile, storm.BoltOptions(0o600, &bbolt.Options{Timeout: 3 * time.Second}))
+		if errors.Is(err, bbolt.ErrTimeout) {
+			return fmt.Errorf("store open %q: %w, the file is locked by a running httplive", Envs.DBFile, err)
+		}
+		if err != nil {
+			return fmt.Errorf("store open %q: %w", Envs.DBFile, err)
+		}
+		store = db
 	}
-	defer iox.Close(db)
 
-	dao, err := CreateDao(db)
+	dao, err := CreateDao(store)
 	if err != nil {
 		return err
 	}
@@ -215,8 +538,37 @@ func DBDo(f func(dao *Dao) error) error {
 	return f(dao)
 }
 
+// CloseDB closes the DB handle, the next DBDo opens it again.
+func CloseDB() error {
+	dbLock.Lock()
+	defer dbLock.Unlock()
+
+	if store == nil {
+		return nil
+	}
+
+	err := store.Close()
+	store = nil
+	return err
+}
+
+// WorkspaceDo executes the f with the dao of the workspace, the default workspace when it is empty.
+func WorkspaceDo(workspace string, f func(dao *Dao) error) error {
+	return DBDo(func(dao *Dao) error {
+		if workspace != "" && dao.FindWorkspace(workspace) == nil {
+			return fmt.Errorf("workspace %q not found", workspace)
+		}
+
+		return f(dao.Workspace(workspace))
+	})
+}
+
 // CreateDB ...
 func CreateDB() error {
+	if err := DBDo(migrateDB); err != nil {
+		return fmt.Errorf("migrate DB: %w", err)
+	}
+
 	if err := DBDo(createDB); err != nil {
 		return err
 	}
@@ -225,6 +577,79 @@ func CreateDB() error {
 	return nil
 }
 
+const (
+	metaBucket = "httplive"
+	// clusterBucket is the bucket of the replicated versions of the endpoints.
+	clusterBucket = "cluster"
+	// resourcesBucket is the bucket of the persisted collections of the resource endpoints.
+	resourcesBucket = "resources"
+	// schemaVersion is the version of the DB schema:
+	// 1, the endpoints are identified by method and path instead of the unique path.
+	schemaVersion = 1
+)
+
+// migrateDB migrates the DB to the current schema version.
+func migrateDB(dao *Dao) error {
+	var version int
+	if err := dao.store.Get(metaBucket, "schemaVersion", &version); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return err
+	}
+	if version >= schemaVersion {
+		return nil
+	}
+
+	// the unique index of the endpoint path is rebuilt as a list index in every workspace.
+	names := []string{""}
+	for _, w := range dao.ListWorkspaces() {
+		names = append(names, w.Name)
+	}
+	for _, name := range names {
+		if err := dao.Workspace(name).reindexEndpoints(); err != nil {
+			return fmt.Errorf("reindex endpoints of workspace %q: %w", name, err)
+		}
+	}
+
+	return dao.store.Set(metaBucket, "schemaVersion", schemaVersion)
+}
+
+// reindexEndpoints drops the index buckets of the endpoints and rebuilds them by updating every endpoint.
+// storm ReIndex is not used because the bucket paths of the index nodes share one slice in a nested node,
+// so it drops the same index bucket twice under a workspace.
+func (d *Dao) reindexEndpoints() error {
+	return d.store.Bolt.Update(func(tx *bbolt.Tx) error {
+		bucket := d.db.GetBucket(tx, "Endpoint")
+		if bucket == nil {
+			return nil // no endpoints yet
+		}
+
+		prefix := []byte("__storm_index_")
+		var indexes [][]byte
+		c := bucket.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if v == nil { // a nested bucket
+				indexes = append(indexes, append([]byte(nil), k...))
+			}
+		}
+		for _, k := range indexes {
+			if err := bucket.DeleteBucket(k); err != nil {
+				return err
+			}
+		}
+
+		node := d.db.WithTransaction(tx)
+		var endpoints []process.Endpoint
+		if err := node.All(&endpoints); err != nil {
+			return err
+		}
+		for i := range endpoints {
+			if err := node.Update(&endpoints[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func createDB(dao *Dao) error {
 	if dao.HasEndpoints() {
 		return nil
@@ -234,6 +659,7 @@ func createDB(dao *Dao) error {
 	dao.AddEndpoint(process.Endpoint{ID: 0, Endpoint: "/auth/demo", Methods: http.MethodGet, MimeType: "", Filename: "", Body: asset("auth.json"), CreateTime: now, UpdateTime: now, DeletedAt: ""})
 	dao.AddEndpoint(process.Endpoint{ID: 0, Endpoint: "/api/demo", Methods: http.MethodGet, MimeType: "", Filename: "", Body: asset("apidemo.json"), CreateTime: now, UpdateTime: now, DeletedAt: ""})
 	dao.AddEndpoint(process.Endpoint{ID: 0, Endpoint: "/dynamic/demo", Methods: http.MethodPost, MimeType: "", Filename: "", Body: asset("dynamicdemo.json"), CreateTime: now, UpdateTime: now, DeletedAt: ""})
+	dao.AddEndpoint(process.Endpoint{ID: 0, Endpoint: "/match/demo", Methods: "ANY", MimeType: "", Filename: "", Body: asset("matchdemo.json"), CreateTime: now, UpdateTime: now, DeletedAt: ""})
 	dao.AddEndpoint(process.Endpoint{ID: 0, Endpoint: "/proxy/demo", Methods: http.MethodGet, MimeType: "", Filename: "", Body: asset("proxydemo.json"), CreateTime: now, UpdateTime: now, DeletedAt: ""})
 	dao.AddEndpoint(process.Endpoint{ID: 0, Endpoint: "/echo/:id", Methods: "ANY", MimeType: "", Filename: "", Body: asset("echo.json"), CreateTime: now, UpdateTime: now, DeletedAt: ""})
 	dao.AddEndpoint(process.Endpoint{ID: 0, Endpoint: "/mockbin", Methods: "ANY", MimeType: "", Filename: "", Body: asset("mockbin.json"), CreateTime: now, UpdateTime: now, DeletedAt: ""})
@@ -256,42 +682,109 @@ func createDB(dao *Dao) error {
 
 // SaveEndpoint ...
 func SaveEndpoint(model process.APIDataModel) (*process.Endpoint, error) {
+	return SaveEndpointBy(model, "")
+}
+
+// SaveEndpointBy saves the endpoint by the author, the previous one is kept as a revision.
+func SaveEndpointBy(model process.APIDataModel, author string) (*process.Endpoint, error) {
+	return saveEndpoint(model, author, false)
+}
+
+// saveEndpoint saves the endpoint, replicated tells the change is replicated from a peer.
+func saveEndpoint(model process.APIDataModel, author string, replicated bool) (*process.Endpoint, error) {
 	if model.Endpoint == "" || model.Method == "" {
 		return nil, fmt.Errorf("model endpoint and method could not be empty")
 	}
+	model.Method = strings.ToUpper(model.Method)
 
-	if err := TestAPIRouter(model); err != nil {
+	if err := checkEndpoint(model); err != nil {
+		return nil, err
+	}
+	e, err := saveRouted(model, author)
+	if err != nil {
 		return nil, err
 	}
 
-	defer SyncAPIRouter()
+	e.Replicated = replicated
+	notifyEndpointChanged(e)
+	return &e.Endpoint, nil
+}
 
-	var ep *process.Endpoint
+// saveRouted tests the routes of the endpoint, saves it and puts its routes in one hold of the saving lock
+// of the routes, or the concurrent saves of the conflicting routes may both pass the test.
+// The listeners are notified after the lock is released, like the cluster saving the replicated changes
+// under its own lock.
+func saveRouted(model process.APIDataModel, author string) (EndpointEvent, error) {
+	routes := routesOf(model.Workspace)
+	routes.saving.Lock()
+	defer routes.saving.Unlock()
+
+	if err := routes.Test(model); err != nil {
+		return EndpointEvent{}, err
+	}
 
-	err := DBDo(func(dao *Dao) error {
+	var ep, renamed *process.Endpoint
+
+	err := WorkspaceDo(model.Workspace, func(dao *Dao) error {
 		old := dao.FindEndpoint(model.ID.Int())
 		if old == nil {
-			old = dao.FindByEndpoint(model.Endpoint)
+			old = dao.FindByEndpoint(model.Endpoint, model.Method)
 		}
 
 		bean := CreateEndpoint(model, old)
+		bean.Author = author
 
 		if old == nil {
 			bean.ID = dao.AddEndpoint(bean)
 		} else {
+			if err := dao.AddRevision(process.CreateEndpointRevision(*old, bean.UpdateTime)); err != nil {
+				return err
+			}
 			dao.UpdateEndpoint(bean)
+			if old.Endpoint != bean.Endpoint || !strings.EqualFold(old.Methods, bean.Methods) {
+				renamed = old
+			}
 		}
 
 		ep = &bean
 
 		return nil
 	})
+	if err != nil {
+		return EndpointEvent{}, err
+	}
+
+	e := EndpointEvent{Endpoint: *ep, Old: renamed, Workspace: model.Workspace, routed: true}
+	routes.apply(e)
+	return e, nil
+}
 
-	return ep, err
+// checkEndpoint checks the path and the config of the endpoint before it is saved,
+// the route is tested separately against the routes of the other endpoints.
+func checkEndpoint(model process.APIDataModel) error {
+	if err := checkEndpointPath(model.Endpoint); err != nil {
+		return err
+	}
+
+	body := process.ParseJSON(string(model.Body))
+	if err := process.CreateRequestValidator(model.Endpoint, body).Err(); err != nil {
+		return err
+	}
+	if _, err := process.CreateMatchRules(model.Endpoint, body); err != nil {
+		return err
+	}
+	if _, err := process.CreateSequence(model.Endpoint, body); err != nil {
+		return err
+	}
+	if _, err := process.CreateWeighted(model.Endpoint, body); err != nil {
+		return err
+	}
+	_, err := process.CreateGRPCMock(&model)
+	return err
 }
 
-// CreateAPIDataModel creates APIDataModel from Endpoint.
-func CreateAPIDataModel(ep *process.Endpoint, query bool) *process.APIDataModel {
+// CreateAPIDataModel creates APIDataModel from Endpoint in the workspace.
+func CreateAPIDataModel(workspace string, ep *process.Endpoint, query bool) *process.APIDataModel {
 	if ep == nil {
 		return nil
 	}
@@ -304,6 +797,7 @@ func CreateAPIDataModel(ep *process.Endpoint, query bool) *process.APIDataModel
 		Filename:    ep.Filename,
 		FileContent: ep.FileContent,
 		Body:        process.RawMessage(ep.Body),
+		Workspace:   workspace,
 	}
 
 	if query {
@@ -316,6 +810,9 @@ func CreateAPIDataModel(ep *process.Endpoint, query bool) *process.APIDataModel
 		return m
 	}
 
+	m.CreateValidator(body)
+	m.CreateFaults(body)
+
 	fnRegistered := m.TryDo(ep.CreateHlHandlers, body, asset)
 	if !fnRegistered {
 		fnRegistered = m.TryDo(ep.CreateEcho, body, nil)
@@ -350,32 +847,74 @@ func CreateEndpoint(model process.APIDataModel, old *process.Endpoint) process.E
 		if old.ID != 0 && ep.ID == 0 {
 			ep.ID = old.ID
 		}
+
+		if old.CreateTime != "" {
+			ep.CreateTime = old.CreateTime
+		}
 	}
 
 	return ep
 }
 
-// DeleteEndpoint ...
-func DeleteEndpoint(id string) error {
-	defer SyncAPIRouter()
+// DeleteEndpoint deletes the endpoint in the workspace.
+func DeleteEndpoint(workspace, id string) error {
+	return deleteEndpoint(workspace, id, false)
+}
 
-	return DBDo(func(dao *Dao) error {
-		dao.DeleteEndpoint(process.Endpoint{
-			ID:        process.ID(id).Int(),
-			DeletedAt: util.TimeFmt(time.Now()),
-		})
+// deleteEndpoint deletes the endpoint, replicated tells the change is replicated from a peer.
+func deleteEndpoint(workspace, id string, replicated bool) error {
+	ep := process.Endpoint{ID: process.ID(id).Int(), DeletedAt: util.TimeFmt(time.Now())}
+	err := WorkspaceDo(workspace, func(dao *Dao) error {
+		if old := dao.FindEndpoint(ep.ID); old != nil {
+			ep.Endpoint, ep.Methods = old.Endpoint, old.Methods
+			if err := dao.AddRevision(process.CreateEndpointRevision(*old, ep.DeletedAt)); err != nil {
+				return err
+			}
+		}
+		dao.DeleteEndpoint(ep)
 
 		return nil
 	})
+	if err == nil {
+		notifyEndpointChanged(EndpointEvent{Endpoint: ep, Workspace: workspace, Deleted: true, Replicated: replicated})
+	}
+
+	return err
+}
+
+// EndpointEvent is the change of an endpoint in the DB.
+type EndpointEvent struct {
+	Endpoint process.Endpoint
+	// Old is the endpoint before the save when its path or method is changed.
+	Old       *process.Endpoint
+	Workspace string
+	Deleted   bool
+	// Replicated tells the change is replicated from a peer of the cluster.
+	Replicated bool
+	// routed tells the routes are applied already, under the saving lock of the routes.
+	routed bool
+}
+
+var endpointListeners []func(EndpointEvent)
+
+// OnEndpointChanged registers the listener to be notified after an endpoint is saved or deleted.
+func OnEndpointChanged(listener func(EndpointEvent)) {
+	endpointListeners = append(endpointListeners, listener)
 }
 
-// GetByEndpoint ...
-func GetByEndpoint(endpoint string) (*process.APIDataModel, error) {
+func notifyEndpointChanged(e EndpointEvent) {
+	for _, listener := range endpointListeners {
+		listener(e)
+	}
+}
+
+// GetByEndpoint gets the endpoint by its path and method, the first one of the path when method is empty.
+func GetByEndpoint(workspace, endpoint, method string) (*process.APIDataModel, error) {
 	var model *process.APIDataModel
 
-	err := DBDo(func(dao *Dao) error {
-		ep := dao.FindByEndpoint(endpoint)
-		model = CreateAPIDataModel(ep, true)
+	err := WorkspaceDo(workspace, func(dao *Dao) error {
+		ep := dao.FindByEndpoint(endpoint, method)
+		model = CreateAPIDataModel(workspace, ep, true)
 
 		return nil
 	})
@@ -384,12 +923,12 @@ func GetByEndpoint(endpoint string) (*process.APIDataModel, error) {
 }
 
 // GetEndpoint ...
-func GetEndpoint(id process.ID) (*process.APIDataModel, error) {
+func GetEndpoint(workspace string, id process.ID) (*process.APIDataModel, error) {
 	var model *process.APIDataModel
 
-	err := DBDo(func(dao *Dao) error {
+	err := WorkspaceDo(workspace, func(dao *Dao) error {
 		ep := dao.FindEndpoint(id.Int())
-		model = CreateAPIDataModel(ep, true)
+		model = CreateAPIDataModel(workspace, ep, true)
 
 		return nil
 	})
@@ -398,20 +937,20 @@ func GetEndpoint(id process.ID) (*process.APIDataModel, error) {
 }
 
 // nolint gochecknoglobals
-var (
-	apiRouter     *gin.Engine
-	apiRouterLock sync.Mutex
-
-	broadcastThrottler = util.MakeThrottle(60, 60*time.Second)
-)
+var broadcastThrottler = util.MakeThrottle(60, 60*time.Second)
 
 func serveAPI(w http.ResponseWriter, r *http.Request) (v process.RouterResult) {
-	apiRouterLock.Lock()
-	router := apiRouter
-	apiRouterLock.Unlock()
+	v.Workspace, r = selectWorkspace(r)
+	routes := routesOf(v.Workspace)
 
 	ctx := context.WithValue(r.Context(), process.RouterResultKey, &v)
-	hlog.StdLogWrapHandler(router).ServeHTTP(w, r.WithContext(ctx))
+	if process.IsGRPCRequest(r) {
+		process.ServeGRPC(w, r.WithContext(ctx), routes.GRPC())
+		v.RouterServed = true
+		return
+	}
+
+	hlog.StdLogWrapHandler(routes).ServeHTTP(w, r.WithContext(ctx))
 
 	return
 }
@@ -435,25 +974,9 @@ func JoinContextPath(elem string, p *process.APIDataModel) string {
 	return path.Join(Envs.ContextPath, elem)
 }
 
-// TestAPIRouter ...
-func TestAPIRouter(p process.APIDataModel) (err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			err = errors.New(r.(string))
-		}
-	}()
-
-	router := httprouter.New()
-	for _, ep := range EndpointList(false) {
-		if ep.ID != p.ID {
-			contextPath := JoinContextPath(ep.Endpoint, &ep)
-			router.GET(contextPath, nil)
-		}
-	}
-
-	contextPath := JoinContextPath(p.Endpoint, &p)
-	router.GET(contextPath, nil)
-	return nil
+// TestAPIRouter tests if the route of p conflicts with the routes of the other endpoints.
+func TestAPIRouter(p process.APIDataModel) error {
+	return routesOf(p.Workspace).Test(p)
 }
 
 func echoXHeaders(c *gin.Context) {
@@ -465,38 +988,11 @@ func echoXHeaders(c *gin.Context) {
 	}
 }
 
-// SyncAPIRouter ...
+// SyncAPIRouter rebuilds the routes of all the endpoints in all the workspaces.
 func SyncAPIRouter() {
-	r := gin.New()
-	r.Use(echoXHeaders)
-
-	for _, ep := range EndpointList(false) {
-		routing(r, ep)
-	}
-
-	r.NoRoute(noRouteHandlerWrap)
-
-	apiRouterLock.Lock()
-	apiRouter = r
-	apiRouterLock.Unlock()
-}
-
-func routing(r *gin.Engine, ep process.APIDataModel) {
-	endpoint := ep.Endpoint
-	if strings.HasPrefix(endpoint, "/_internal") {
-		ep.InternalProcess(endpoint[10:])
-		return
-	}
-
-	h := ep.HandleJSON
-	if ep.MimeType != "" {
-		h = ep.HandleFileDownload
-	}
-
-	if strings.EqualFold(ep.Method, "ANY") {
-		r.Any(JoinContextPath(endpoint, &ep), h)
-	} else {
-		r.Handle(ep.Method, JoinContextPath(endpoint, &ep), h)
+	routesOf("").Reset(EndpointList("", false))
+	for _, w := range loadWorkspaces() {
+		routesOf(w.Name).Reset(EndpointList(w.Name, false))
 	}
 }
 
@@ -614,11 +1110,11 @@ func counterDeal(query func(key string) string) gin.H {
 	}
 }
 
-// EndpointList ...
-func EndpointList(query bool) []process.APIDataModel {
+// EndpointList lists the endpoints in the workspace.
+func EndpointList(workspace string, query bool) []process.APIDataModel {
 	var endPoints []process.Endpoint
 
-	_ = DBDo(func(dao *Dao) error {
+	_ = WorkspaceDo(workspace, func(dao *Dao) error {
 		endPoints = dao.ListEndpoints()
 		return nil
 	})
@@ -626,7 +1122,7 @@ func EndpointList(query bool) []process.APIDataModel {
 	items := make([]process.APIDataModel, len(endPoints))
 	for i, val := range endPoints {
 		val := val
-		items[i] = *CreateAPIDataModel(&val, query)
+		items[i] = *CreateAPIDataModel(workspace, &val, query)
 	}
 
 	return items
@@ -0,0 +1,263 @@
+package httplive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/asdine/storm/v3"
+	"github.com/bingoohuang/httplive/internal/process"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// BenchmarkSaveEndpoint measures the latency of saving an endpoint, which should stay flat
+// as the number of the existing endpoints grows.
+func BenchmarkSaveEndpoint(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("endpoints-%d", n), func(b *testing.B) {
+			prepareEndpoints(b, n)
+
+			model := process.APIDataModel{Endpoint: "/bench/0", Method: "GET"}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				model.Body = process.RawMessage(fmt.Sprintf(`{"seq": %d}`, i))
+				if _, err := SaveEndpoint(model); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkAddEndpoint measures the latency of adding a new endpoint, whose route is added in place.
+func BenchmarkAddEndpoint(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("endpoints-%d", n), func(b *testing.B) {
+			prepareEndpoints(b, n)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				model := process.APIDataModel{
+					Endpoint: fmt.Sprintf("/bench/%d", n+i),
+					Method:   "GET",
+					Body:     process.RawMessage(fmt.Sprintf(`{"id": %d}`, n+i)),
+				}
+				if _, err := SaveEndpoint(model); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestAddEndpointRoute(t *testing.T) {
+	prepareDB(t)
+	SyncAPIRouter()
+
+	for _, p := range []string{"/users/1", "/users/2", "/users/2/books"} {
+		_, err := SaveEndpoint(process.APIDataModel{Endpoint: p, Method: "GET", Body: process.RawMessage(`{"path":"` + p + `"}`)})
+		assert.Nil(t, err, p)
+	}
+	_, err := SaveEndpoint(process.APIDataModel{Endpoint: "/users/:id", Method: "GET", Body: process.RawMessage(`{}`)})
+	assert.NotNil(t, err, "conflicts with /users/1")
+	_, err = SaveEndpoint(process.APIDataModel{Endpoint: "/books/:id", Method: "GET", Body: process.RawMessage(`{}`)})
+	assert.Nil(t, err)
+	_, err = SaveEndpoint(process.APIDataModel{Endpoint: "/books/1", Method: "GET", Body: process.RawMessage(`{}`)})
+	assert.NotNil(t, err, "conflicts with /books/:id")
+	_, err = SaveEndpoint(process.APIDataModel{Endpoint: "/books/1", Method: "POST", Body: process.RawMessage(`{}`)})
+	assert.Nil(t, err, "the other method")
+
+	for _, p := range []string{"/users/1", "/users/2", "/users/2/books"} {
+		w := httptest.NewRecorder()
+		v := serveAPI(w, httptest.NewRequest("GET", p, nil))
+		assert.True(t, v.RouterServed, p)
+		assert.JSONEq(t, `{"path":"`+p+`"}`, w.Body.String())
+	}
+}
+
+func prepareEndpoints(b *testing.B, n int) {
+	b.Helper()
+	prepareDB(b)
+
+	for i := 0; i < n; i++ {
+		model := process.APIDataModel{
+			Endpoint: fmt.Sprintf("/bench/%d", i),
+			Method:   "GET",
+			Body:     process.RawMessage(fmt.Sprintf(`{"id": %d}`, i)),
+		}
+		if _, err := SaveEndpoint(model); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// prepareDB creates a new DB in the temp dir, which is closed at the end of the test.
+func prepareDB(tb testing.TB) {
+	tb.Helper()
+	gin.SetMode(gin.ReleaseMode)
+
+	_ = CloseDB()
+	Envs.DBFile = filepath.Join(tb.TempDir(), "httplive.bolt")
+	Envs.EndpointDir = ""
+	Envs.Init()
+	tb.Cleanup(func() { _ = CloseDB() })
+
+	if err := CreateDB(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestResetResourcesPersisted(t *testing.T) {
+	prepareDB(t)
+
+	// the collections persisted before a restart, not loaded in memory yet.
+	for _, key := range []string{process.ResourceKey("", "/users"), process.ResourceKey("teamA", "/books")} {
+		assert.Nil(t, process.SaveResourceState(process.ResourceState{Key: key, Seed: []byte(`[]`), Items: []json.RawMessage{[]byte(`{"id":1}`)}}))
+		assert.NotNil(t, process.FindResourceState(key))
+	}
+
+	process.ResetResources("", "")
+	assert.Nil(t, process.FindResourceState(process.ResourceKey("", "/users")))
+	assert.Nil(t, process.FindResourceState(process.ResourceKey("teamA", "/books")))
+	process.ResetResources("", "") // the bucket is dropped already
+}
+
+func TestSaveEndpointBrokenValidate(t *testing.T) {
+	prepareDB(t)
+
+	_, err := SaveEndpoint(process.APIDataModel{Endpoint: "/v", Method: "POST", Body: process.RawMessage(`{"_validate": {"body": {"type": 1}}}`)})
+	assert.NotNil(t, err)
+	m, _ := GetByEndpoint("", "/v", "POST")
+	assert.Nil(t, m, "not saved")
+}
+
+func TestSaveEndpointBrokenConfigs(t *testing.T) {
+	prepareDB(t)
+
+	for _, body := range []string{
+		`{"_match": [{"when": {"query": {"page": {"matches": "[0-9"}}}}]}`,
+		`{"_match": [{"when": {"body": {"age": {"min": "18"}}}}]}`,
+		`{"_sequence": [], "response": {}}`,
+		`{"_sequence": [{"status": 202}], "_sequenceMode": "forever"}`,
+		`{"_weighted": [{"weight": 0, "response": {}}]}`,
+		`{"_weighted": [{"weight": 1, "sleep": "soon", "response": {}}]}`,
+		`{"_hl": "grpc", "proto": "syntax = \"proto3\"; service Greeter {"}`,
+		`{"_hl": "grpc", "proto": "syntax = \"proto3\"; message M {} service S { rpc Get (M) returns (M); }", "methods": {"S/Put": {}}}`,
+	} {
+		_, err := SaveEndpoint(process.APIDataModel{Endpoint: "/g", Method: "POST", Body: process.RawMessage(body)})
+		assert.NotNil(t, err, body)
+		m, _ := GetByEndpoint("", "/g", "POST")
+		assert.Nil(t, m, "not saved")
+	}
+}
+
+func TestSaveEndpointConcurrentConflicts(t *testing.T) {
+	prepareDB(t)
+
+	for i := 0; i < 50; i++ {
+		var wg sync.WaitGroup
+		errs := make([]error, 3)
+		for j, save := range []func() error{
+			func() error {
+				_, err := SaveEndpoint(process.APIDataModel{Endpoint: fmt.Sprintf("/c%d/:id", i), Method: "GET", Body: process.RawMessage(`{}`)})
+				return err
+			},
+			func() error {
+				_, err := SaveEndpoint(process.APIDataModel{Endpoint: fmt.Sprintf("/c%d/1", i), Method: "GET", Body: process.RawMessage(`{}`)})
+				return err
+			},
+			func() error {
+				b := bundleOf(BundleEndpoint{Endpoint: fmt.Sprintf("/c%d/*any", i), Method: "GET", Body: `{}`})
+				report, err := ImportBundle("", b, ImportMerge, false, "")
+				if err == nil && !report.Applied {
+					err = fmt.Errorf("not applied: %v", report.Conflicts)
+				}
+				return err
+			},
+		} {
+			wg.Add(1)
+			go func(j int, save func() error) {
+				defer wg.Done()
+				errs[j] = save()
+			}(j, save)
+		}
+		wg.Wait()
+
+		saved := 0
+		for _, err := range errs {
+			if err == nil {
+				saved++
+			}
+		}
+		assert.Equal(t, 1, saved, "only one of the conflicting routes is saved: %v", errs)
+	}
+	assert.Nil(t, TestAPIRouter(process.APIDataModel{Endpoint: "/other", Method: "GET"}))
+}
+
+func TestMigrateUniqueEndpointIndex(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	_ = CloseDB()
+	Envs.DBFile = filepath.Join(t.TempDir(), "httplive.bolt")
+	Envs.EndpointDir = ""
+	Envs.Init()
+	t.Cleanup(func() { _ = CloseDB() })
+
+	// Endpoint is named the same as process.Endpoint for the same storm bucket, with its unique index before the migration.
+	type Endpoint struct {
+		Endpoint    string `name:"endpoint" storm:"unique"`
+		Methods     string `name:"methods"`
+		MimeType    string `name:"mime_type"`
+		Filename    string `name:"filename"`
+		Body        string `name:"body"`
+		CreateTime  string `name:"create_time"`
+		UpdateTime  string `name:"update_time"`
+		DeletedAt   string `name:"deleted_at"`
+		FileContent []byte `name:"file_content"`
+		ID          uint64 `name:"id" storm:"id,increment"`
+	}
+	old, err := storm.Open(Envs.DBFile)
+	assert.Nil(t, err)
+	assert.Nil(t, old.Save(&process.Workspace{Name: "legacy", Prefix: "/legacy"}))
+	for _, node := range []storm.Node{old, old.From(workspacesBucket, "legacy")} {
+		assert.Nil(t, node.Save(&Endpoint{Endpoint: "/users", Methods: "GET", Body: `{"method": "GET"}`}))
+		assert.Nil(t, node.Save(&Endpoint{Endpoint: "/health", Methods: "GET", Body: `{"Status": "old"}`}))
+		assert.NotNil(t, node.Save(&Endpoint{Endpoint: "/users", Methods: "POST"}), "the unique index before the migration")
+	}
+	assert.Nil(t, old.Close())
+
+	assert.Nil(t, CreateDB())
+	assert.Nil(t, DBDo(func(dao *Dao) error {
+		var version int
+		assert.Nil(t, dao.store.Get(metaBucket, "schemaVersion", &version))
+		assert.Equal(t, schemaVersion, version)
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{"GET /health", "GET /users"}, names(EndpointList("", true)), "no demo endpoints added")
+
+	for _, workspace := range []string{"", "legacy"} {
+		_, err := SaveEndpoint(process.APIDataModel{Workspace: workspace, Endpoint: "/users", Method: "POST", Body: process.RawMessage(`{"method": "POST"}`)})
+		assert.Nil(t, err, "the same path with another method after the migration")
+
+		for _, method := range []string{"GET", "POST"} {
+			m, err := GetByEndpoint(workspace, "/users", method)
+			assert.Nil(t, err)
+			if assert.NotNil(t, m, method) {
+				assert.JSONEq(t, `{"method": "`+method+`"}`, string(m.Body))
+			}
+		}
+		assert.Len(t, EndpointList(workspace, true), 3)
+	}
+
+	for target, body := range map[string]string{"/users": `{"method": "POST"}`, "/legacy/users": `{"method": "POST"}`} {
+		w := httptest.NewRecorder()
+		serveAPI(w, httptest.NewRequest("POST", target, nil))
+		assert.JSONEq(t, body, w.Body.String(), target)
+	}
+
+	assert.Nil(t, CreateDB(), "migrated once")
+	t.Cleanup(func() { assert.Nil(t, DeleteWorkspace("legacy")) })
+}
@@ -0,0 +1,715 @@
+package httplive
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bingoohuang/httplive/internal/process"
+	"github.com/hjson/hjson-go/v4"
+)
+
+// workspacesDir is the directory of the named workspaces under the endpoint directory,
+// one sub directory per workspace, holding its definition in workspaceFile.
+const (
+	workspacesDir = ".workspaces"
+	workspaceFile = ".workspace.json"
+)
+
+// workspaceDir returns the directory of the endpoint files of the workspace.
+func workspaceDir(dir, workspace string) string {
+	if workspace == "" {
+		return dir
+	}
+
+	return filepath.Join(dir, workspacesDir, workspace)
+}
+
+// endpointFile is the content of an endpoint file, the metadata with the body.
+// The path and method of the endpoint are the ones of the file path.
+type endpointFile struct {
+	ID          uint64          `json:"id,omitempty"`
+	Author      string          `json:"author,omitempty"`
+	CreateTime  string          `json:"createTime,omitempty"`
+	UpdateTime  string          `json:"updateTime,omitempty"`
+	MimeType    string          `json:"mimeType,omitempty"`
+	Filename    string          `json:"filename,omitempty"`
+	FileContent []byte          `json:"fileContent,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	// BodyText is the body which is not valid JSON, like HJSON with comments.
+	BodyText string `json:"bodyText,omitempty"`
+}
+
+// dirStore stores the endpoints as JSON or HJSON files in a directory tree mirroring the route paths,
+// like api/users/{id}/GET.json for GET /api/users/:id, the endpoints are cached until reloaded.
+type dirStore struct {
+	dir       string
+	lock      sync.RWMutex
+	loaded    bool
+	endpoints map[uint64]process.Endpoint
+	files     map[uint64]string
+}
+
+var (
+	dirStoresLock sync.Mutex
+	dirStores     = map[string]*dirStore{}
+)
+
+// dirStoreOf returns the store of the directory, shared by all the daos.
+func dirStoreOf(dir string) *dirStore {
+	dirStoresLock.Lock()
+	defer dirStoresLock.Unlock()
+
+	s, ok := dirStores[dir]
+	if !ok {
+		s = &dirStore{dir: dir}
+		dirStores[dir] = s
+	}
+	return s
+}
+
+// dropDirStore drops the store of the deleted directory.
+func dropDirStore(dir string) {
+	dirStoresLock.Lock()
+	defer dirStoresLock.Unlock()
+
+	delete(dirStores, dir)
+}
+
+// ownChanges is the changes httplive makes in the endpoint directory by the path, the content hash of
+// the file written, ownDir of the directory created, or "" of the file or directory removed.
+// The watcher of the directory ignores them, see EndpointFileChanged.
+var (
+	ownChangesLock sync.Mutex
+	ownChanges     = map[string]string{}
+)
+
+const ownDir = "/"
+
+// recordOwnChange records the change before it is made, the watcher may see it at once,
+// and returns the state recorded before.
+func recordOwnChange(path, state string) (prev string, ok bool) {
+	ownChangesLock.Lock()
+	defer ownChangesLock.Unlock()
+
+	prev, ok = ownChanges[path]
+	ownChanges[path] = state
+	return prev, ok
+}
+
+// restoreOwnChange restores the state of the path recorded before the change which fails.
+func restoreOwnChange(path, state string, ok bool) {
+	ownChangesLock.Lock()
+	defer ownChangesLock.Unlock()
+
+	if ok {
+		ownChanges[path] = state
+	} else {
+		delete(ownChanges, path)
+	}
+}
+
+func contentHash(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// EndpointFileChanged tells whether the file or directory in the endpoint directory is changed out of httplive,
+// which is not in the state httplive leaves it in.
+func EndpointFileChanged(path string) bool {
+	ownChangesLock.Lock()
+	own, ok := ownChanges[path]
+	ownChangesLock.Unlock()
+	if !ok {
+		return true
+	}
+
+	st, err := os.Stat(path)
+	switch {
+	case err != nil:
+		return own != ""
+	case st.IsDir():
+		return own != ownDir
+	}
+
+	data, err := os.ReadFile(path)
+	return err != nil || contentHash(data) != own
+}
+
+// WatchedEndpointDir tells whether the sub directory of the endpoint directory is watched for the changes,
+// the dot directories like .git are not, except the one of the named workspaces.
+func WatchedEndpointDir(dir string) bool {
+	name := filepath.Base(dir)
+	return !strings.HasPrefix(name, ".") || name == workspacesDir
+}
+
+// mkdirOwn creates the directory with its missing parents, as the changes of httplive.
+func mkdirOwn(dir string) error {
+	for d := dir; ; {
+		if _, err := os.Stat(d); err == nil {
+			break
+		}
+		recordOwnChange(d, ownDir)
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+
+	return os.MkdirAll(dir, 0o755)
+}
+
+// writeOwnFile writes the file with its missing parent directories, as the changes of httplive.
+func writeOwnFile(file string, data []byte) error {
+	if err := mkdirOwn(filepath.Dir(file)); err != nil {
+		return err
+	}
+
+	recordOwnChange(file, contentHash(data))
+	return os.WriteFile(file, data, 0o644)
+}
+
+// removeOwn removes the file or the empty directory, as the change of httplive.
+func removeOwn(path string) error {
+	prev, ok := recordOwnChange(path, "")
+	err := os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		restoreOwnChange(path, prev, ok)
+	}
+	return err
+}
+
+// removeAllOwn removes the directory with all its files, as the changes of httplive.
+func removeAllOwn(dir string) error {
+	_ = filepath.WalkDir(dir, func(path string, _ fs.DirEntry, err error) error {
+		if err == nil {
+			recordOwnChange(path, "")
+		}
+		return nil
+	})
+
+	return os.RemoveAll(dir)
+}
+
+// ReloadEndpointDir reloads the endpoint files changed out of httplive, and rebuilds the routes.
+func ReloadEndpointDir() {
+	dirStoresLock.Lock()
+	for _, s := range dirStores {
+		s.lock.Lock()
+		s.loaded = false
+		s.lock.Unlock()
+	}
+	dirStoresLock.Unlock()
+
+	SyncAPIRouter()
+}
+
+func (s *dirStore) ListEndpoints() ([]process.Endpoint, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	result := make([]process.Endpoint, 0, len(s.endpoints))
+	for _, ep := range s.endpoints {
+		result = append(result, ep)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (s *dirStore) FindEndpoint(id uint64) (*process.Endpoint, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if ep, ok := s.endpoints[id]; ok {
+		return &ep, nil
+	}
+	return nil, nil
+}
+
+func (s *dirStore) FindByEndpoint(endpoint string) ([]process.Endpoint, error) {
+	all, err := s.ListEndpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []process.Endpoint
+	for _, ep := range all {
+		if ep.Endpoint == endpoint {
+			result = append(result, ep)
+		}
+	}
+	return result, nil
+}
+
+func (s *dirStore) AddEndpoint(ep *process.Endpoint) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if ep.ID == 0 {
+		ep.ID = s.nextID()
+	}
+	return s.write(*ep)
+}
+
+func (s *dirStore) UpdateEndpoint(ep process.Endpoint) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	old, ok := s.endpoints[ep.ID]
+	if !ok {
+		return fmt.Errorf("endpoint %d not found", ep.ID)
+	}
+
+	// the same as the storm Update, the zero fields are kept.
+	merged := old
+	merged.Author = ep.Author
+	for to, from := range map[*string]string{
+		&merged.Endpoint: ep.Endpoint, &merged.Methods: ep.Methods, &merged.MimeType: ep.MimeType,
+		&merged.Filename: ep.Filename, &merged.Body: ep.Body,
+		&merged.CreateTime: ep.CreateTime, &merged.UpdateTime: ep.UpdateTime,
+	} {
+		if from != "" {
+			*to = from
+		}
+	}
+	if len(ep.FileContent) > 0 {
+		merged.FileContent = ep.FileContent
+	}
+
+	return s.write(merged)
+}
+
+func (s *dirStore) DeleteEndpoint(ep process.Endpoint) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	file, ok := s.files[ep.ID]
+	if !ok {
+		return nil
+	}
+
+	if err := removeOwn(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	s.removeEmptyDirs(filepath.Dir(file))
+	delete(s.endpoints, ep.ID)
+	delete(s.files, ep.ID)
+	return nil
+}
+
+func (s *dirStore) nextID() (id uint64) {
+	for k := range s.endpoints {
+		if k > id {
+			id = k
+		}
+	}
+	return id + 1
+}
+
+// write writes the endpoint file, in the format of its current file, and moves it when the path or method is changed.
+func (s *dirStore) write(ep process.Endpoint) error {
+	old := s.files[ep.ID]
+	ext := ".json"
+	if old != "" {
+		ext = filepath.Ext(old)
+	}
+
+	file, err := endpointFilePath(s.dir, ep.Endpoint, ep.Methods, ext)
+	if err != nil {
+		return err
+	}
+	if err := writeEndpointFile(file, ep); err != nil {
+		return err
+	}
+
+	if old != "" && old != file {
+		if err := removeOwn(old); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		s.removeEmptyDirs(filepath.Dir(old))
+	}
+
+	s.endpoints[ep.ID] = ep
+	s.files[ep.ID] = file
+	return nil
+}
+
+// removeEmptyDirs removes the empty dir and its empty parents up to the store directory.
+func (s *dirStore) removeEmptyDirs(dir string) {
+	for dir != s.dir && strings.HasPrefix(dir, s.dir) {
+		if removeOwn(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// load loads the endpoint files if they are not loaded yet,
+// the missing or duplicate IDs are assigned and written back to the files.
+func (s *dirStore) load() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.loaded {
+		return nil
+	}
+
+	if err := mkdirOwn(s.dir); err != nil {
+		return err
+	}
+
+	s.endpoints = map[uint64]process.Endpoint{}
+	s.files = map[uint64]string{}
+	var unassigned []process.Endpoint
+	var unassignedFiles []string
+
+	err := filepath.WalkDir(s.dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// the named workspaces are the stores of their own, the other dot directories like .well-known are endpoints.
+			if file == filepath.Join(s.dir, workspacesDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, _ := filepath.Rel(s.dir, file)
+		endpoint, method, ok := parseEndpointFilePath(rel)
+		if !ok {
+			return nil
+		}
+
+		ep, err := readEndpointFile(file)
+		if err != nil {
+			log.Printf("E! read endpoint file %s: %v", file, err)
+			return nil
+		}
+		ep.Endpoint, ep.Methods = endpoint, method
+
+		if _, dup := s.endpoints[ep.ID]; ep.ID == 0 || dup {
+			unassigned = append(unassigned, ep)
+			unassignedFiles = append(unassignedFiles, file)
+			return nil
+		}
+
+		s.endpoints[ep.ID] = ep
+		s.files[ep.ID] = file
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, ep := range unassigned {
+		ep.ID = s.nextID()
+		s.files[ep.ID] = unassignedFiles[i]
+		if err := s.write(ep); err != nil {
+			return err
+		}
+	}
+
+	s.loaded = true
+	return nil
+}
+
+var endpointFileMethods = append([]string{"ANY"}, anyMethods...)
+
+// checkEndpointPath checks the endpoint path can be stored as a file under the directory,
+// without the . or .. segments, and not under the directory of the workspaces.
+func checkEndpointPath(endpoint string) error {
+	for i, seg := range strings.Split(strings.TrimPrefix(endpoint, "/"), "/") {
+		if seg == "." || seg == ".." || i == 0 && seg == workspacesDir {
+			return fmt.Errorf("invalid endpoint path %s", endpoint)
+		}
+	}
+	return nil
+}
+
+// endpointFilePath returns the file path of the endpoint, the path params :id and *file are
+// mapped to {id} and {+file}, which are valid file names in all the systems.
+func endpointFilePath(dir, endpoint, method, ext string) (string, error) {
+	if err := checkEndpointPath(endpoint); err != nil {
+		return "", err
+	}
+
+	parts := []string{dir}
+	for _, seg := range strings.Split(endpoint, "/") {
+		switch {
+		case seg == "":
+			continue
+		case strings.HasPrefix(seg, ":"):
+			seg = "{" + seg[1:] + "}"
+		case strings.HasPrefix(seg, "*"):
+			seg = "{+" + seg[1:] + "}"
+		}
+		parts = append(parts, seg)
+	}
+
+	file := filepath.Join(append(parts, strings.ToUpper(method)+ext)...)
+	if rel, err := filepath.Rel(dir, file); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("endpoint path %s is out of the directory", endpoint)
+	}
+	return file, nil
+}
+
+// parseEndpointFilePath parses the endpoint and method from the file path relative to the store directory.
+func parseEndpointFilePath(rel string) (endpoint, method string, ok bool) {
+	ext := filepath.Ext(rel)
+	if ext != ".json" && ext != ".hjson" {
+		return "", "", false
+	}
+
+	method = strings.TrimSuffix(filepath.Base(rel), ext)
+	found := false
+	for _, m := range endpointFileMethods {
+		found = found || m == method
+	}
+	if !found {
+		return "", "", false
+	}
+
+	var segs []string
+	for _, seg := range strings.Split(filepath.ToSlash(filepath.Dir(rel)), "/") {
+		if seg == "." {
+			continue
+		}
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			switch seg = seg[1 : len(seg)-1]; {
+			case strings.HasPrefix(seg, "+"):
+				seg = "*" + seg[1:]
+			case !strings.HasPrefix(seg, "*"): // {*file} of the earlier versions
+				seg = ":" + seg
+			}
+		}
+		segs = append(segs, seg)
+	}
+
+	return "/" + strings.Join(segs, "/"), method, true
+}
+
+func readEndpointFile(file string) (process.Endpoint, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return process.Endpoint{}, err
+	}
+
+	if filepath.Ext(file) == ".hjson" {
+		var node *hjson.Node
+		if err := hjson.Unmarshal(data, &node); err != nil {
+			return process.Endpoint{}, err
+		}
+		if data, err = json.Marshal(node); err != nil {
+			return process.Endpoint{}, err
+		}
+	}
+
+	var f endpointFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return process.Endpoint{}, err
+	}
+
+	body := f.BodyText
+	if len(f.Body) > 0 {
+		body = string(f.Body)
+	}
+
+	return process.Endpoint{
+		ID: f.ID, Author: f.Author,
+		CreateTime: f.CreateTime, UpdateTime: f.UpdateTime,
+		MimeType: f.MimeType, Filename: f.Filename, FileContent: f.FileContent, Body: body,
+	}, nil
+}
+
+func writeEndpointFile(file string, ep process.Endpoint) error {
+	f := endpointFile{
+		ID: ep.ID, Author: ep.Author,
+		CreateTime: ep.CreateTime, UpdateTime: ep.UpdateTime,
+		MimeType: ep.MimeType, Filename: ep.Filename, FileContent: ep.FileContent,
+	}
+	if body := strings.TrimSpace(ep.Body); json.Valid([]byte(body)) {
+		f.Body = json.RawMessage(body)
+	} else {
+		f.BodyText = ep.Body
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+
+	if filepath.Ext(file) == ".hjson" {
+		var node *hjson.Node
+		if err := hjson.Unmarshal(data, &node); err != nil {
+			return err
+		}
+		var err error
+		if data, err = hjson.Marshal(node); err != nil {
+			return err
+		}
+	}
+
+	return writeOwnFile(file, data)
+}
+
+// ExportEndpointDir exports the endpoints of all the workspaces in the bolt file to the directory.
+func ExportEndpointDir(dir string) error {
+	return DBDo(func(dao *Dao) error {
+		names := []string{""}
+		for _, w := range dao.ListWorkspaces() {
+			names = append(names, w.Name)
+			if err := writeWorkspaceFile(workspaceDir(dir, w.Name), w); err != nil {
+				return err
+			}
+		}
+
+		for _, name := range names {
+			endpoints, err := boltStore{node: dao.Workspace(name).db}.ListEndpoints()
+			if err != nil {
+				return err
+			}
+
+			s := &dirStore{dir: workspaceDir(dir, name)}
+			if err := s.load(); err != nil {
+				return err
+			}
+			for _, ep := range endpoints {
+				ep := ep
+				if err := s.AddEndpoint(&ep); err != nil {
+					return fmt.Errorf("export %s %s: %w", ep.Methods, ep.Endpoint, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// ImportEndpointDir imports the endpoint files of all the workspaces in the directory to the bolt file,
+// replacing the endpoints with the same method and path.
+func ImportEndpointDir(dir string) error {
+	workspaces, err := listWorkspaceFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	return DBDo(func(dao *Dao) error {
+		names := []string{""}
+		for _, w := range workspaces {
+			if err := dao.store.Save(&w); err != nil {
+				return err
+			}
+			names = append(names, w.Name)
+		}
+
+		for _, name := range names {
+			endpoints, err := (&dirStore{dir: workspaceDir(dir, name)}).ListEndpoints()
+			if err != nil {
+				return err
+			}
+
+			s := boltStore{node: dao.Workspace(name).db}
+			for _, ep := range endpoints {
+				ep := ep
+				// the IDs of the files are not the ones in the bolt file, the endpoint with the same
+				// method and path is replaced with its ID, and the others are added with new IDs.
+				ep.ID = 0
+				same, _ := s.FindByEndpoint(ep.Endpoint)
+				for _, old := range same {
+					if !strings.EqualFold(old.Methods, ep.Methods) {
+						continue
+					}
+					if ep.ID == 0 {
+						ep.ID = old.ID
+					} else if err := s.DeleteEndpoint(old); err != nil {
+						return err
+					}
+				}
+				if err := s.AddEndpoint(&ep); err != nil {
+					return fmt.Errorf("import %s %s: %w", ep.Methods, ep.Endpoint, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// listWorkspaceFiles lists the workspaces in the directory.
+func listWorkspaceFiles(dir string) ([]process.Workspace, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, workspacesDir))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	var result []process.Workspace
+	for _, e := range entries {
+		if e.IsDir() {
+			w, err := readWorkspaceFile(workspaceDir(dir, e.Name()), e.Name())
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, w)
+		}
+	}
+	return result, nil
+}
+
+func writeWorkspaceFile(dir string, w process.Workspace) error {
+	data, err := json.MarshalIndent(w, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeOwnFile(filepath.Join(dir, workspaceFile), data)
+}
+
+func readWorkspaceFile(dir, name string) (process.Workspace, error) {
+	w := process.Workspace{Name: name}
+	data, err := os.ReadFile(filepath.Join(dir, workspaceFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return w, nil
+	}
+	if err != nil {
+		return w, err
+	}
+
+	if err := json.Unmarshal(data, &w); err != nil {
+		return w, fmt.Errorf("read %s: %w", filepath.Join(dir, workspaceFile), err)
+	}
+	w.Name = name
+	return w, w.Normalize()
+}
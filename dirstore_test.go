@@ -0,0 +1,86 @@
+package httplive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bingoohuang/httplive/internal/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointFilePath(t *testing.T) {
+	dir := filepath.FromSlash("/data/endpoints")
+	for _, c := range []struct {
+		endpoint, method, file string
+	}{
+		{"/api/users/:id", "GET", "api/users/{id}/GET.json"},
+		{"/static/*file", "GET", "static/{+file}/GET.json"},
+		{"/.well-known/openid-configuration", "GET", ".well-known/openid-configuration/GET.json"},
+	} {
+		file, err := endpointFilePath(dir, c.endpoint, c.method, ".json")
+		assert.Nil(t, err, c.endpoint)
+		assert.Equal(t, filepath.Join(dir, filepath.FromSlash(c.file)), file)
+
+		endpoint, method, ok := parseEndpointFilePath(filepath.FromSlash(c.file))
+		assert.True(t, ok)
+		assert.Equal(t, c.endpoint, endpoint)
+		assert.Equal(t, c.method, method, c.endpoint)
+	}
+
+	for _, endpoint := range []string{"/../../etc/x", "/a/../../x", "/a/./b", "/..", "/.workspaces/x"} {
+		_, err := endpointFilePath(dir, endpoint, "GET", ".json")
+		assert.NotNil(t, err, endpoint)
+	}
+
+	endpoint, _, _ := parseEndpointFilePath(filepath.FromSlash("static/{*file}/GET.json"))
+	assert.Equal(t, "/static/*file", endpoint)
+}
+
+func TestDirStoreLoadsDotDirs(t *testing.T) {
+	dir := t.TempDir()
+	s := &dirStore{dir: dir}
+	assert.Nil(t, s.AddEndpoint(&process.Endpoint{Endpoint: "/.well-known/x", Methods: "GET", Body: `{"a":1}`}))
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, workspacesDir, "w", "api"), 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, workspacesDir, "w", "api", "GET.json"), []byte(`{}`), 0o644))
+
+	endpoints, err := (&dirStore{dir: dir}).ListEndpoints()
+	assert.Nil(t, err)
+	assert.Len(t, endpoints, 1)
+	assert.Equal(t, "/.well-known/x", endpoints[0].Endpoint)
+}
+
+func TestEndpointFileChanged(t *testing.T) {
+	dir := t.TempDir()
+	s := &dirStore{dir: filepath.Join(dir, "endpoints")}
+	ep := &process.Endpoint{Endpoint: "/api/users/:id", Methods: "GET", Body: `{"a":1}`}
+	assert.Nil(t, s.AddEndpoint(ep))
+
+	file := s.files[ep.ID]
+	assert.False(t, EndpointFileChanged(file), "written by httplive")
+	assert.False(t, EndpointFileChanged(filepath.Dir(file)), "created by httplive")
+	assert.False(t, EndpointFileChanged(s.dir), "created by httplive")
+
+	assert.Nil(t, os.WriteFile(file, []byte(`{"body":{"a":2}}`), 0o644))
+	assert.True(t, EndpointFileChanged(file), "edited out of httplive")
+	assert.Nil(t, s.UpdateEndpoint(process.Endpoint{ID: ep.ID, Body: `{"a":3}`}))
+	assert.False(t, EndpointFileChanged(file))
+
+	other := filepath.Join(s.dir, "api", "GET.json")
+	assert.Nil(t, os.WriteFile(other, []byte(`{}`), 0o644))
+	assert.True(t, EndpointFileChanged(other), "added out of httplive")
+
+	assert.Nil(t, s.DeleteEndpoint(*ep))
+	assert.False(t, EndpointFileChanged(file), "removed by httplive")
+	assert.False(t, EndpointFileChanged(filepath.Dir(file)), "the empty dir removed by httplive")
+	assert.False(t, EndpointFileChanged(filepath.Join(s.dir, "api")), "kept, not empty")
+
+	assert.Nil(t, os.MkdirAll(filepath.Dir(file), 0o755))
+	assert.True(t, EndpointFileChanged(filepath.Dir(file)), "created again out of httplive")
+	assert.Nil(t, os.WriteFile(file, []byte(`{}`), 0o644))
+	assert.True(t, EndpointFileChanged(file), "added again out of httplive")
+
+	assert.True(t, WatchedEndpointDir(filepath.Join(s.dir, "api")))
+	assert.True(t, WatchedEndpointDir(filepath.Join(s.dir, workspacesDir)))
+	assert.False(t, WatchedEndpointDir(filepath.Join(s.dir, ".git")))
+}
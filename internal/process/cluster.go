@@ -0,0 +1,74 @@
+package process
+
+// VersionVector counts the changes made on each node of the cluster.
+type VersionVector map[string]uint64
+
+// Copy returns a copy of the vector.
+func (v VersionVector) Copy() VersionVector {
+	c := make(VersionVector, len(v))
+	for node, n := range v {
+		c[node] = n
+	}
+	return c
+}
+
+// Merge returns the element-wise maximum of the two vectors.
+func (v VersionVector) Merge(o VersionVector) VersionVector {
+	c := v.Copy()
+	for node, n := range o {
+		if n > c[node] {
+			c[node] = n
+		}
+	}
+	return c
+}
+
+// Descends tells whether v has seen all the changes of o.
+func (v VersionVector) Descends(o VersionVector) bool {
+	for node, n := range o {
+		if v[node] < n {
+			return false
+		}
+	}
+	return true
+}
+
+// ClusterEntry is the replicated version of an endpoint, identified by its workspace, method and path.
+// It is kept as a tombstone after the endpoint is deleted.
+type ClusterEntry struct {
+	Key       string        `json:"key" storm:"id"`
+	Workspace string        `json:"workspace,omitempty"`
+	Method    string        `json:"method"`
+	Endpoint  string        `json:"endpoint"`
+	Vector    VersionVector `json:"vector"`
+	// Origin is the node which made the change, with the UpdateTime to pick the winner of the concurrent changes.
+	Origin     string `json:"origin"`
+	UpdateTime string `json:"updateTime"`
+	Deleted    bool   `json:"deleted,omitempty"`
+	// Local is the update time of the local endpoint when the entry is written,
+	// to find the changes made while the cluster mode is off.
+	Local string `json:"local,omitempty"`
+}
+
+// ClusterKey returns the key of the endpoint in the cluster.
+func ClusterKey(workspace, method, endpoint string) string {
+	return workspace + " " + method + " " + endpoint
+}
+
+// Wins tells whether the change of e wins the concurrent change of o.
+func (e ClusterEntry) Wins(o ClusterEntry) bool {
+	if e.UpdateTime != o.UpdateTime {
+		return e.UpdateTime > o.UpdateTime
+	}
+	return e.Origin > o.Origin
+}
+
+// ClusterChange is the replicated change of an endpoint, with its content unless it is deleted.
+type ClusterChange struct {
+	ClusterEntry
+	MimeType    string `json:"mimeType,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+	FileContent []byte `json:"fileContent,omitempty"`
+	Body        string `json:"body,omitempty"`
+	Author      string `json:"author,omitempty"`
+}
@@ -0,0 +1,46 @@
+package process
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionVectorDescends(t *testing.T) {
+	for _, c := range []struct {
+		v, o VersionVector
+		want bool
+	}{
+		{VersionVector{}, VersionVector{}, true},
+		{VersionVector{"a": 1}, VersionVector{}, true},
+		{VersionVector{}, VersionVector{"a": 1}, false},
+		{VersionVector{"a": 2, "b": 1}, VersionVector{"a": 1, "b": 1}, true},
+		{VersionVector{"a": 1, "b": 1}, VersionVector{"a": 2}, false},
+		{VersionVector{"a": 1}, VersionVector{"b": 1}, false}, // concurrent
+		{VersionVector{"b": 1}, VersionVector{"a": 1}, false},
+	} {
+		assert.Equal(t, c.want, c.v.Descends(c.o), "%v descends %v", c.v, c.o)
+	}
+}
+
+func TestVersionVectorMerge(t *testing.T) {
+	v := VersionVector{"a": 2, "b": 1}
+	o := VersionVector{"b": 3, "c": 1}
+
+	m := v.Merge(o)
+	assert.Equal(t, VersionVector{"a": 2, "b": 3, "c": 1}, m)
+	assert.True(t, m.Descends(v))
+	assert.True(t, m.Descends(o))
+	assert.Equal(t, VersionVector{"a": 2, "b": 1}, v, "merge keeps the vectors untouched")
+}
+
+func TestClusterEntryWins(t *testing.T) {
+	a := ClusterEntry{Origin: "a", UpdateTime: "2026-10-18 10:00:00.000"}
+	b := ClusterEntry{Origin: "b", UpdateTime: "2026-10-18 09:00:00.000"}
+	assert.True(t, a.Wins(b))
+	assert.False(t, b.Wins(a))
+
+	b.UpdateTime = a.UpdateTime
+	assert.True(t, b.Wins(a), "the origin breaks the tie")
+	assert.False(t, a.Wins(b))
+}
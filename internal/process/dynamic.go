@@ -23,6 +23,26 @@ type DynamicValue struct {
 	Condition           string          `json:"condition"`
 	Response            json.RawMessage `json:"response"`
 	Status              int             `json:"status"`
+
+	// Scenario is the name of the scenario which the rule belongs to.
+	Scenario string `json:"scenario"`
+	// RequiredState is the state of the scenario required to match the rule, empty for any state.
+	RequiredState string `json:"requiredState"`
+	// NewState is the state of the scenario to move to after the rule matched, empty to keep.
+	NewState string `json:"newState"`
+
+	// disabled is the rule with a broken condition, which never matches.
+	disabled bool
+	// workspace is the workspace of the endpoint, which the scenario of the rule belongs to.
+	workspace string
+}
+
+func (v DynamicValue) matchScenario() bool {
+	return v.Scenario == "" || v.RequiredState == "" || Scenarios.Get(v.workspace, v.Scenario) == v.RequiredState
+}
+
+func (v DynamicValue) transitScenario() bool {
+	return v.Scenario == "" || Scenarios.Transit(v.workspace, v.Scenario, v.RequiredState, v.NewState)
 }
 
 func (v DynamicValue) responseDynamic(ep APIDataModel, c *gin.Context) {
@@ -31,12 +51,13 @@ func (v DynamicValue) responseDynamic(ep APIDataModel, c *gin.Context) {
 		statusCode = http.StatusOK
 	}
 
+	t := NewRequestTemplate(c)
 	contentType := ""
 	for k, v := range v.Headers {
 		if strings.EqualFold(k, "Content-Type") {
 			contentType = v
 		} else {
-			c.Header(k, v)
+			c.Header(k, t.Render(v))
 		}
 	}
 
@@ -44,6 +65,7 @@ func (v DynamicValue) responseDynamic(ep APIDataModel, c *gin.Context) {
 	if err != nil {
 		c.String(http.StatusInternalServerError, err.Error())
 	}
+	payload = t.RenderBody(payload)
 
 	if contentType == "" {
 		contentType = util.DetectContentType([]byte(payload))
@@ -56,16 +78,17 @@ func Eval(endpoint string, body string) (string, error) {
 	return eval.JjGen(eval.Execute(endpoint, body))
 }
 
-func MakeParamValuer(jsonConfig string, vars []string) map[string]Valuer {
+// MakeParamValuer makes the valuers of the condition variables, the scenario ones read the scenarios of the workspace.
+func MakeParamValuer(workspace, jsonConfig string, vars []string) map[string]Valuer {
 	parameters := make(map[string]Valuer)
 	for _, va := range vars {
-		parameters[va] = makeParameter(va, jsonConfig)
+		parameters[va] = makeParameter(workspace, va, jsonConfig)
 	}
 
 	return parameters
 }
 
-func makeParameter(va string, jsonConfig string) Valuer {
+func makeParameter(workspace, va, jsonConfig string) Valuer {
 	switch {
 	case util.HasPrefix(va, "json_"):
 		k := va[5:]
@@ -80,12 +103,15 @@ func makeParameter(va string, jsonConfig string) Valuer {
 	case util.HasPrefix(va, "header_"):
 		k := va[7:]
 		return func(_ []byte, c *gin.Context) interface{} { return c.GetHeader(k) }
+	case util.HasPrefix(va, "scenario_"):
+		k := va[9:]
+		return func(_ []byte, c *gin.Context) interface{} { return Scenarios.Get(workspace, k) }
 	default:
 		indirectVa := jj.Get(jsonConfig, va).String()
 		if indirectVa == "" {
 			return func(_ []byte, c *gin.Context) interface{} { return nil }
 		}
 
-		return makeParameter(indirectVa, jsonConfig)
+		return makeParameter(workspace, indirectVa, jsonConfig)
 	}
 }
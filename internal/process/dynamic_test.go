@@ -0,0 +1,52 @@
+package process
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bingoohuang/jj"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func matchTestRequest(t *testing.T, rules []DynamicValue, target, body string) (string, bool) {
+	t.Helper()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", target, nil)
+	v, ok, err := matchDynamic(c, []byte(body), rules)
+	assert.Nil(t, err)
+	return string(v.Response), ok
+}
+
+func TestCreateDynamicsBrokenCondition(t *testing.T) {
+	rules := createDynamics("", `{}`, []byte(`[
+		{"condition": "json_name ==", "response": "broken"},
+		{"response": "no condition"},
+		{"condition": "json_name == 'bingoo'", "response": "bingoo"}
+	]`))
+
+	assert.True(t, rules[0].disabled)
+	assert.True(t, rules[1].disabled)
+	assert.False(t, rules[2].disabled)
+
+	r, ok := matchTestRequest(t, rules, "/", `{"name":"bingoo"}`)
+	assert.True(t, ok)
+	assert.Equal(t, `"bingoo"`, r)
+
+	_, ok = matchTestRequest(t, rules, "/", `{"name":"other"}`)
+	assert.False(t, ok)
+}
+
+func TestGRPCRulesFallback(t *testing.T) {
+	assert.Equal(t, `[{"condition":"true","response":{"a":1}}]`, grpcRules(jj.Parse(`{"a":1}`)))
+
+	rules := createDynamics("", `{}`, []byte(grpcRules(jj.Parse(`[
+		{"condition": "json_name == 'bingoo'", "response": "bingoo"},
+		{"response": "fallback"}
+	]`))))
+
+	r, ok := matchTestRequest(t, rules, "/", `{"name":"other"}`)
+	assert.True(t, ok)
+	assert.Equal(t, `"fallback"`, r)
+}
@@ -0,0 +1,306 @@
+package process
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bingoohuang/gg/pkg/thinktime"
+	"github.com/bingoohuang/httplive/pkg/shapeio"
+	"github.com/bingoohuang/jj"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+"_faults": { // every fault is injected by its probability, default 1 when absent
+  "latency": {"probability": 0.5, "delay": "100ms-2s", "distribution": "normal"}, // uniform (default), normal or exponential
+  "error": {"probability": 0.1, "statuses": [500, 503]}, // default 500, 502, 503, 504
+  "reset": {"probability": 0.05},                        // reset the connection without any response
+  "truncate": {"probability": 0.1, "ratio": 0.5},        // or "bytes": 10, then close the connection
+  "slowDrip": {"probability": 0.1, "rate": 64},          // response bytes per second
+  "malformed": {"probability": 0.1}                      // break the JSON response
+}
+*/
+
+// Fault is a fault injected by the probability.
+type Fault struct {
+	Probability *float64 `json:"probability"`
+}
+
+func (f *Fault) hit() bool {
+	return f != nil && (f.Probability == nil || rand.Float64() < *f.Probability)
+}
+
+// LatencyFault adds the latency in the range of delay by the distribution.
+type LatencyFault struct {
+	Fault
+	Delay        string `json:"delay"`
+	Distribution string `json:"distribution"`
+
+	think *thinktime.ThinkTime
+}
+
+// ErrorFault responds a random status in statuses.
+type ErrorFault struct {
+	Fault
+	Statuses []int `json:"statuses"`
+}
+
+// TruncateFault writes only part of the response, and then closes the connection.
+type TruncateFault struct {
+	Fault
+	Ratio float64 `json:"ratio"`
+	Bytes int     `json:"bytes"`
+}
+
+// SlowDripFault writes the response in the rate of bytes per second.
+type SlowDripFault struct {
+	Fault
+	Rate int `json:"rate"`
+}
+
+// Faults is the `_faults` block of the endpoint.
+type Faults struct {
+	Latency   *LatencyFault  `json:"latency"`
+	Error     *ErrorFault    `json:"error"`
+	Reset     *Fault         `json:"reset"`
+	Truncate  *TruncateFault `json:"truncate"`
+	SlowDrip  *SlowDripFault `json:"slowDrip"`
+	Malformed *Fault         `json:"malformed"`
+}
+
+// CreateFaults creates the Faults from the `_faults` block of the endpoint body, nil when the block is absent.
+func CreateFaults(endpoint, body string) *Faults {
+	block := jj.Get(body, "_faults")
+	if block.Type != jj.JSON {
+		return nil
+	}
+
+	var f Faults
+	if err := json.Unmarshal([]byte(block.Raw), &f); err != nil {
+		log.Printf("E! parse _faults of %s: %v", endpoint, err)
+		return nil
+	}
+
+	if f.Latency != nil {
+		think, err := thinktime.ParseThinkTime(f.Latency.Delay)
+		if err != nil || think == nil {
+			log.Printf("E! parse _faults.latency.delay %q of %s: %v", f.Latency.Delay, endpoint, err)
+			f.Latency = nil
+		} else {
+			f.Latency.think = think
+		}
+	}
+
+	if f.Error != nil && len(f.Error.Statuses) == 0 {
+		f.Error.Statuses = []int{http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+
+	return &f
+}
+
+// Serve serves the request by serve with the faults injected, returns the names of the injected faults.
+func (f *Faults) Serve(c *gin.Context, serve func()) (injected []string) {
+	if f == nil {
+		serve()
+		return nil
+	}
+
+	if f.Latency != nil && f.Latency.hit() {
+		injected = append(injected, "latency")
+		time.Sleep(f.Latency.delay())
+	}
+
+	if f.Reset.hit() {
+		injected = append(injected, "reset")
+		abortConnection(c)
+		return injected
+	}
+
+	if f.Error != nil && f.Error.hit() {
+		status := f.Error.Statuses[rand.Intn(len(f.Error.Statuses))]
+		c.JSON(status, gin.H{"error": http.StatusText(status), "fault": "injected"})
+		return append(injected, "error")
+	}
+
+	truncate := f.Truncate != nil && f.Truncate.hit()
+	slowDrip := f.SlowDrip != nil && f.SlowDrip.hit()
+	malformed := f.Malformed.hit()
+	if !truncate && !slowDrip && !malformed {
+		serve()
+		return injected
+	}
+
+	w := &faultWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+	serve()
+	c.Writer = w.ResponseWriter
+
+	data := w.buf.Bytes()
+	if malformed {
+		injected = append(injected, "malformed")
+		data = malformJSON(data)
+	}
+
+	size := len(data)
+	if truncate {
+		injected = append(injected, "truncate")
+		data = data[:f.Truncate.size(size)]
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Length", strconv.Itoa(size))
+	c.Writer.WriteHeader(w.status())
+	c.Writer.WriteHeaderNow()
+
+	if slowDrip {
+		injected = append(injected, "slowDrip")
+		f.SlowDrip.write(c.Writer, data)
+	} else {
+		_, _ = c.Writer.Write(data)
+	}
+
+	if truncate {
+		c.Writer.Flush()
+		abortConnection(c)
+	}
+
+	return injected
+}
+
+func (f *LatencyFault) delay() time.Duration {
+	lo, hi := float64(f.think.Min), float64(f.think.Max)
+	var d float64
+	switch f.Distribution {
+	case "normal": // 99.7% in the range
+		d = (lo+hi)/2 + rand.NormFloat64()*(hi-lo)/6
+	case "exponential": // long tail from the min
+		d = lo + rand.ExpFloat64()*(hi-lo)/4
+	default:
+		d = lo + rand.Float64()*(hi-lo)
+	}
+
+	return time.Duration(math.Max(lo, math.Min(hi, d)))
+}
+
+func (f *TruncateFault) size(total int) int {
+	n := f.Bytes
+	if n <= 0 {
+		ratio := f.Ratio
+		if ratio <= 0 || ratio >= 1 {
+			ratio = 0.5
+		}
+		n = int(float64(total) * ratio)
+	}
+
+	if n >= total {
+		n = total - 1
+	}
+	if n < 0 {
+		n = 0
+	}
+	return n
+}
+
+func (f *SlowDripFault) write(w gin.ResponseWriter, data []byte) {
+	rate := f.Rate
+	if rate <= 0 {
+		rate = 64
+	}
+
+	sw := shapeio.NewWriter(w, shapeio.WithRateLimit(float64(rate)))
+	chunk := rate/10 + 1
+	for len(data) > 0 {
+		n := chunk
+		if n > len(data) {
+			n = len(data)
+		}
+		if _, err := sw.Write(data[:n]); err != nil {
+			return
+		}
+		w.Flush()
+		data = data[n:]
+	}
+}
+
+// malformJSON breaks the JSON by removing its closing character and appending a dangling comma.
+func malformJSON(data []byte) []byte {
+	trimmed := bytes.TrimRight(data, " \r\n\t")
+	if len(trimmed) == 0 {
+		return []byte("{")
+	}
+
+	return append(append([]byte{}, trimmed[:len(trimmed)-1]...), ',')
+}
+
+// abortConnection closes the client connection immediately, with a TCP reset when possible.
+// The HTTP/2 streams can not be hijacked, they end with what has been written, an empty response at most.
+func abortConnection(c *gin.Context) {
+	if c.Request.ProtoMajor >= 2 {
+		c.Abort()
+		return
+	}
+
+	conn, _, err := c.Writer.Hijack()
+	if err != nil {
+		c.Abort()
+		return
+	}
+
+	if tc, ok := conn.(*net.TCPConn); ok {
+		_ = tc.SetLinger(0)
+	}
+	_ = conn.Close()
+}
+
+// faultWriter buffers the response to inject the faults after it is completed.
+type faultWriter struct {
+	gin.ResponseWriter
+	buf  bytes.Buffer
+	code int
+}
+
+// WriteHeader records the status code only.
+func (w *faultWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+// WriteHeaderNow does nothing until the response is completed.
+func (w *faultWriter) WriteHeaderNow() {}
+
+// Flush does nothing until the response is completed.
+func (w *faultWriter) Flush() {}
+
+func (w *faultWriter) Write(data []byte) (int, error) {
+	return w.buf.Write(data)
+}
+
+func (w *faultWriter) WriteString(s string) (int, error) {
+	return w.buf.WriteString(s)
+}
+
+func (w *faultWriter) Status() int {
+	return w.status()
+}
+
+func (w *faultWriter) Written() bool {
+	return w.code != 0 || w.buf.Len() > 0
+}
+
+func (w *faultWriter) Size() int {
+	return w.buf.Len()
+}
+
+func (w *faultWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
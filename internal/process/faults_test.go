@@ -0,0 +1,34 @@
+package process
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaultsAbortHTTP2(t *testing.T) {
+	for _, c := range []struct {
+		body, want string
+	}{
+		{`{"_faults": {"reset": {}}}`, ""},
+		{`{"_faults": {"truncate": {"bytes": 5}}}`, `{"nam`},
+	} {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/faults", nil)
+		ctx.Request.ProtoMajor, ctx.Request.Proto = 2, "HTTP/2.0"
+
+		f := CreateFaults("/faults", c.body)
+		assert.NotPanics(t, func() {
+			f.Serve(ctx, func() { ctx.JSON(http.StatusOK, gin.H{"name": "bingoo"}) })
+		}, c.body)
+		ctx.Writer.WriteHeaderNow()
+
+		assert.True(t, ctx.IsAborted(), c.body)
+		assert.Equal(t, http.StatusOK, w.Code, c.body)
+		assert.Equal(t, c.want, w.Body.String(), c.body)
+	}
+}
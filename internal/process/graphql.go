@@ -0,0 +1,636 @@
+package process
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/bingoohuang/jj"
+	"github.com/gin-gonic/gin"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"github.com/vektah/gqlparser/v2/validator"
+)
+
+/*
+"_hl": "graphql",
+"schema": "type Query { user(id: ID!): User, users: [User!]! } type Mutation { createUser(name: String!): User } type User { id: ID! name: String age: Int }",
+"resolvers": {
+  "User": {"id": "@objectId", "name": "@姓名", "age": "@random_int(18-60)"}, // per-type JSON snippets of jj.Gen
+  "Query.users": ["|2-5", {"id": "@objectId"}]                              // per-field ones, taking precedence
+},
+"listSize": 3 // the size of the lists generated from the schema types, default 3
+
+The fields missing in the resolvers are generated from their schema types, and the arguments of the field
+are echoed to the fields of the same names of its object, like the id of user(id: 1).
+The queries are POST as {"query": "...", "operationName": "...", "variables": {}} or GET by the same query params,
+and the introspection works for GraphiQL and the codegen tools.
+*/
+
+// HlGraphQL is the _hl of the GraphQL mock endpoints.
+const HlGraphQL = "graphql"
+
+func init() {
+	registerHlHandlers(HlGraphQL, func() HlHandler { return &GraphQL{} })
+}
+
+// GraphQL answers the GraphQL queries and mutations by the SDL schema with the mock values.
+type GraphQL struct {
+	Schema    string                     `json:"schema"`
+	Resolvers map[string]json.RawMessage `json:"resolvers"`
+	ListSize  int                        `json:"listSize"`
+
+	schema    *ast.Schema
+	schemaErr error
+}
+
+// GraphQLRequest is the request of a GraphQL query.
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// AfterUnmashal loads the schema.
+func (g *GraphQL) AfterUnmashal() {
+	if g.schema, g.schemaErr = gqlparser.LoadSchema(&ast.Source{Name: "schema", Input: g.Schema}); g.schemaErr != nil {
+		log.Printf("E! load graphql schema: %v", g.schemaErr)
+	}
+	if g.ListSize <= 0 {
+		g.ListSize = 3
+	}
+}
+
+// HlHandle answers the GraphQL request.
+func (g *GraphQL) HlHandle(c *gin.Context, _ *APIDataModel, _ func(name string) string) error {
+	if g.schemaErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": gqlerror.List{gqlerror.Wrap(g.schemaErr)}})
+		return nil
+	}
+
+	req, err := readGraphQLRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": gqlerror.List{gqlerror.Wrap(err)}})
+		return nil
+	}
+
+	doc, errs := gqlparser.LoadQuery(g.schema, req.Query)
+	if len(errs) > 0 {
+		c.JSON(http.StatusOK, gin.H{"errors": errs})
+		return nil
+	}
+
+	op := doc.Operations.ForName(req.OperationName)
+	if op == nil {
+		c.JSON(http.StatusOK, gin.H{"errors": gqlerror.List{gqlerror.Errorf("operation %q not found", req.OperationName)}})
+		return nil
+	}
+
+	vars, err := validator.VariableValues(g.schema, op, req.Variables)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"errors": gqlerror.List{gqlerror.Wrap(err)}})
+		return nil
+	}
+
+	var root *ast.Definition
+	switch op.Operation {
+	case ast.Mutation:
+		root = g.schema.Mutation
+	case ast.Subscription:
+		c.JSON(http.StatusOK, gin.H{"errors": gqlerror.List{gqlerror.Errorf("subscription is not supported")}})
+		return nil
+	default:
+		root = g.schema.Query
+	}
+
+	e := &graphqlExec{GraphQL: g, vars: vars}
+	c.JSON(http.StatusOK, gin.H{"data": e.selectionSet(op.SelectionSet, root, nil)})
+	return nil
+}
+
+func readGraphQLRequest(c *gin.Context) (req GraphQLRequest, err error) {
+	if c.Request.Method == http.MethodGet {
+		req.Query, req.OperationName = c.Query("query"), c.Query("operationName")
+		if v := c.Query("variables"); v != "" {
+			err = json.Unmarshal([]byte(v), &req.Variables)
+		}
+		return req, err
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return req, err
+	}
+	if strings.Contains(c.ContentType(), "graphql") {
+		req.Query = string(body)
+		return req, nil
+	}
+
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	return req, d.Decode(&req)
+}
+
+// graphqlExec executes an operation.
+type graphqlExec struct {
+	*GraphQL
+	vars map[string]interface{}
+}
+
+// jsonObject is a JSON object keeping the order of its fields.
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// MarshalJSON marshals the fields in order.
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		value, err := json.Marshal(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// selectionSet resolves the selections on the parent of the object type, the parent is the mock object
+// (map[string]interface{}), or the schema definitions for the introspection types.
+func (e *graphqlExec) selectionSet(set ast.SelectionSet, typ *ast.Definition, parent interface{}) *jsonObject {
+	o := &jsonObject{values: map[string]interface{}{}}
+	fields := map[string][]*ast.Field{}
+	e.collectFields(set, typ, &o.keys, fields)
+
+	for _, key := range o.keys {
+		o.values[key] = e.field(typ, parent, fields[key])
+	}
+	return o
+}
+
+// collectFields collects the fields by their response keys, with the fragments applied and @skip/@include evaluated.
+func (e *graphqlExec) collectFields(set ast.SelectionSet, typ *ast.Definition, keys *[]string, fields map[string][]*ast.Field) {
+	for _, sel := range set {
+		switch s := sel.(type) {
+		case *ast.Field:
+			if !e.included(s.Directives) {
+				continue
+			}
+			key := s.Alias
+			if key == "" {
+				key = s.Name
+			}
+			if _, ok := fields[key]; !ok {
+				*keys = append(*keys, key)
+			}
+			fields[key] = append(fields[key], s)
+		case *ast.FragmentSpread:
+			if e.included(s.Directives) && e.typeApplies(typ, s.Definition.TypeCondition) {
+				e.collectFields(s.Definition.SelectionSet, typ, keys, fields)
+			}
+		case *ast.InlineFragment:
+			if e.included(s.Directives) && (s.TypeCondition == "" || e.typeApplies(typ, s.TypeCondition)) {
+				e.collectFields(s.SelectionSet, typ, keys, fields)
+			}
+		}
+	}
+}
+
+func (e *graphqlExec) included(directives ast.DirectiveList) bool {
+	if d := directives.ForName("skip"); d != nil && d.ArgumentMap(e.vars)["if"] == true {
+		return false
+	}
+	if d := directives.ForName("include"); d != nil && d.ArgumentMap(e.vars)["if"] == false {
+		return false
+	}
+	return true
+}
+
+func (e *graphqlExec) typeApplies(typ *ast.Definition, condition string) bool {
+	if typ.Name == condition {
+		return true
+	}
+	for _, t := range e.schema.GetPossibleTypes(e.schema.Types[condition]) {
+		if t.Name == typ.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// field resolves the fields of the same response key, with their selections merged.
+func (e *graphqlExec) field(typ *ast.Definition, parent interface{}, fields []*ast.Field) interface{} {
+	f := fields[0]
+	if f.Name == "__typename" {
+		return typ.Name
+	}
+
+	var set ast.SelectionSet
+	for _, field := range fields {
+		set = append(set, field.SelectionSet...)
+	}
+
+	args := f.ArgumentMap(e.vars)
+	switch {
+	case typ == e.schema.Query && f.Name == "__schema":
+		return e.complete(f.Definition.Type, e.schema, true, set, nil)
+	case typ == e.schema.Query && f.Name == "__type":
+		var t interface{}
+		if name, _ := args["name"].(string); e.schema.Types[name] != nil {
+			t = ast.NamedType(name, nil)
+		}
+		return e.complete(f.Definition.Type, t, true, set, nil)
+	case strings.HasPrefix(typ.Name, "__"):
+		return e.complete(f.Definition.Type, e.introspect(typ.Name, parent, f.Name, args), true, set, nil)
+	}
+
+	if r, ok := e.Resolvers[typ.Name+"."+f.Name]; ok {
+		return e.complete(f.Definition.Type, e.gen(r), true, set, args)
+	}
+	if m, ok := parent.(map[string]interface{}); ok {
+		if v, ok := m[f.Name]; ok {
+			return e.complete(f.Definition.Type, v, true, set, args)
+		}
+	}
+	return e.complete(f.Definition.Type, nil, false, set, args)
+}
+
+// complete completes the value of the type, the value is generated from the type when it is not present.
+func (e *graphqlExec) complete(t *ast.Type, v interface{}, present bool, set ast.SelectionSet, args map[string]interface{}) interface{} {
+	if t.Elem != nil {
+		if !present {
+			items := make([]interface{}, e.ListSize)
+			for i := range items {
+				items[i] = e.complete(t.Elem, nil, false, set, args)
+			}
+			return items
+		}
+
+		items, ok := v.([]interface{})
+		if !ok {
+			if v == nil {
+				return nil
+			}
+			items = []interface{}{v}
+		}
+		result := make([]interface{}, len(items))
+		for i, item := range items {
+			result[i] = e.complete(t.Elem, item, true, set, args)
+		}
+		return result
+	}
+
+	if present && v == nil {
+		return nil
+	}
+
+	def := e.schema.Types[t.NamedType]
+	switch def.Kind {
+	case ast.Scalar, ast.Enum:
+		if present {
+			return v
+		}
+		return e.scalar(def)
+	case ast.InputObject:
+		return v
+	}
+
+	if def.Kind != ast.Object {
+		def = e.concreteType(def, v)
+	}
+	if !present {
+		v = map[string]interface{}{}
+		if r, ok := e.Resolvers[def.Name]; ok {
+			v = e.gen(r)
+		}
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		v = e.echoArgs(def, m, args)
+	}
+
+	return e.selectionSet(set, def, v)
+}
+
+// concreteType returns the object type of the interface or union value, by its __typename or the first possible type.
+func (e *graphqlExec) concreteType(def *ast.Definition, v interface{}) *ast.Definition {
+	possible := e.schema.GetPossibleTypes(def)
+	if m, ok := v.(map[string]interface{}); ok {
+		if name, _ := m["__typename"].(string); name != "" {
+			for _, p := range possible {
+				if p.Name == name {
+					return p
+				}
+			}
+		}
+	}
+	if len(possible) > 0 {
+		return possible[0]
+	}
+	return def
+}
+
+// echoArgs sets the scalar fields of the object by the arguments of the same names.
+func (e *graphqlExec) echoArgs(def *ast.Definition, m map[string]interface{}, args map[string]interface{}) map[string]interface{} {
+	for name, arg := range args {
+		f := def.Fields.ForName(name)
+		if f == nil || arg == nil || f.Type.Elem != nil {
+			continue
+		}
+		if k := e.schema.Types[f.Type.NamedType].Kind; k == ast.Scalar || k == ast.Enum {
+			m[name] = arg
+		}
+	}
+	return m
+}
+
+// gen generates the value of the resolver snippet by jj.Gen.
+func (e *graphqlExec) gen(snippet json.RawMessage) interface{} {
+	s, err := jj.Gen(string(snippet))
+	if err != nil {
+		log.Printf("E! gen %s: %v", snippet, err)
+		s = string(snippet)
+	}
+
+	var v interface{}
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return s
+	}
+	return v
+}
+
+// scalar generates the value of the scalar or enum type, by its resolver or its kind.
+func (e *graphqlExec) scalar(def *ast.Definition) interface{} {
+	if r, ok := e.Resolvers[def.Name]; ok {
+		return e.gen(r)
+	}
+
+	switch def.Name {
+	case "Int":
+		return rand.Intn(100)
+	case "Float":
+		return float64(rand.Intn(10000)) / 100
+	case "Boolean":
+		return rand.Intn(2) == 1
+	case "ID":
+		return e.gen(json.RawMessage(`"@objectId"`))
+	}
+
+	if def.Kind == ast.Enum && len(def.EnumValues) > 0 {
+		return def.EnumValues[rand.Intn(len(def.EnumValues))].Name
+	}
+	return e.gen(json.RawMessage(`"@name"`))
+}
+
+// introspect resolves the field of the introspection type on the schema definition of the parent.
+func (e *graphqlExec) introspect(typeName string, parent interface{}, field string, args map[string]interface{}) interface{} {
+	includeDeprecated := args["includeDeprecated"] == true
+
+	switch p := parent.(type) {
+	case *ast.Schema:
+		return e.introspectSchema(p, field)
+	case *ast.Type:
+		return e.introspectType(p, field, includeDeprecated)
+	case *ast.FieldDefinition:
+		if typeName == "__InputValue" {
+			return introspectInputValue(p.Name, p.Description, p.Type, p.DefaultValue, p.Directives, field)
+		}
+		switch field {
+		case "args":
+			return inputValues(p.Arguments, includeDeprecated)
+		case "type":
+			return p.Type
+		default:
+			return introspectCommon(p.Name, p.Description, p.Directives, field)
+		}
+	case *ast.ArgumentDefinition:
+		return introspectInputValue(p.Name, p.Description, p.Type, p.DefaultValue, p.Directives, field)
+	case *ast.EnumValueDefinition:
+		return introspectCommon(p.Name, p.Description, p.Directives, field)
+	case *ast.DirectiveDefinition:
+		switch field {
+		case "locations":
+			locations := make([]interface{}, len(p.Locations))
+			for i, l := range p.Locations {
+				locations[i] = string(l)
+			}
+			return locations
+		case "args":
+			return inputValues(p.Arguments, includeDeprecated)
+		case "isRepeatable":
+			return p.IsRepeatable
+		default:
+			return introspectCommon(p.Name, p.Description, nil, field)
+		}
+	}
+
+	return nil
+}
+
+func (e *graphqlExec) introspectSchema(s *ast.Schema, field string) interface{} {
+	rootType := func(def *ast.Definition) interface{} {
+		if def == nil {
+			return nil
+		}
+		return ast.NamedType(def.Name, nil)
+	}
+
+	switch field {
+	case "description":
+		return nullString(s.Description)
+	case "types":
+		names := make([]string, 0, len(s.Types))
+		for name := range s.Types {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		types := make([]interface{}, len(names))
+		for i, name := range names {
+			types[i] = ast.NamedType(name, nil)
+		}
+		return types
+	case "queryType":
+		return rootType(s.Query)
+	case "mutationType":
+		return rootType(s.Mutation)
+	case "subscriptionType":
+		return rootType(s.Subscription)
+	case "directives":
+		names := make([]string, 0, len(s.Directives))
+		for name := range s.Directives {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		directives := make([]interface{}, len(names))
+		for i, name := range names {
+			directives[i] = s.Directives[name]
+		}
+		return directives
+	default:
+		return nil
+	}
+}
+
+func (e *graphqlExec) introspectType(t *ast.Type, field string, includeDeprecated bool) interface{} {
+	switch {
+	case t.NonNull:
+		switch field {
+		case "kind":
+			return "NON_NULL"
+		case "ofType":
+			return &ast.Type{NamedType: t.NamedType, Elem: t.Elem}
+		default:
+			return nil
+		}
+	case t.Elem != nil:
+		switch field {
+		case "kind":
+			return "LIST"
+		case "ofType":
+			return t.Elem
+		default:
+			return nil
+		}
+	}
+
+	def := e.schema.Types[t.NamedType]
+	switch field {
+	case "kind":
+		return string(def.Kind)
+	case "name":
+		return def.Name
+	case "description":
+		return nullString(def.Description)
+	case "specifiedByURL":
+		if d := def.Directives.ForName("specifiedBy"); d != nil {
+			return d.ArgumentMap(nil)["url"]
+		}
+		return nil
+	case "fields":
+		if def.Kind != ast.Object && def.Kind != ast.Interface {
+			return nil
+		}
+		fields := []interface{}{}
+		for _, f := range def.Fields {
+			if !strings.HasPrefix(f.Name, "__") && (includeDeprecated || f.Directives.ForName("deprecated") == nil) {
+				fields = append(fields, f)
+			}
+		}
+		return fields
+	case "interfaces":
+		if def.Kind != ast.Object && def.Kind != ast.Interface {
+			return nil
+		}
+		interfaces := make([]interface{}, len(def.Interfaces))
+		for i, name := range def.Interfaces {
+			interfaces[i] = ast.NamedType(name, nil)
+		}
+		return interfaces
+	case "possibleTypes":
+		if def.Kind != ast.Interface && def.Kind != ast.Union {
+			return nil
+		}
+		possible := e.schema.GetPossibleTypes(def)
+		types := make([]interface{}, len(possible))
+		for i, p := range possible {
+			types[i] = ast.NamedType(p.Name, nil)
+		}
+		return types
+	case "enumValues":
+		if def.Kind != ast.Enum {
+			return nil
+		}
+		values := []interface{}{}
+		for _, v := range def.EnumValues {
+			if includeDeprecated || v.Directives.ForName("deprecated") == nil {
+				values = append(values, v)
+			}
+		}
+		return values
+	case "inputFields":
+		if def.Kind != ast.InputObject {
+			return nil
+		}
+		fields := []interface{}{}
+		for _, f := range def.Fields {
+			if includeDeprecated || f.Directives.ForName("deprecated") == nil {
+				fields = append(fields, f)
+			}
+		}
+		return fields
+	case "isOneOf":
+		return def.Directives.ForName("oneOf") != nil
+	default:
+		return nil
+	}
+}
+
+func inputValues(args ast.ArgumentDefinitionList, includeDeprecated bool) []interface{} {
+	values := []interface{}{}
+	for _, a := range args {
+		if includeDeprecated || a.Directives.ForName("deprecated") == nil {
+			values = append(values, a)
+		}
+	}
+	return values
+}
+
+func introspectInputValue(name, description string, t *ast.Type, defaultValue *ast.Value, directives ast.DirectiveList, field string) interface{} {
+	switch field {
+	case "type":
+		return t
+	case "defaultValue":
+		if defaultValue == nil {
+			return nil
+		}
+		return defaultValue.String()
+	default:
+		return introspectCommon(name, description, directives, field)
+	}
+}
+
+// introspectCommon resolves the name, description and deprecation of the fields, arguments and enum values.
+func introspectCommon(name, description string, directives ast.DirectiveList, field string) interface{} {
+	deprecated := directives.ForName("deprecated")
+	switch field {
+	case "name":
+		return name
+	case "description":
+		return nullString(description)
+	case "isDeprecated":
+		return deprecated != nil
+	case "deprecationReason":
+		if deprecated == nil {
+			return nil
+		}
+		if reason := deprecated.Arguments.ForName("reason"); reason != nil {
+			return reason.Value.Raw
+		}
+		return "No longer supported"
+	default:
+		return nil
+	}
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
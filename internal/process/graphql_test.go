@@ -0,0 +1,134 @@
+package process
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bingoohuang/jj"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const graphqlTestConfig = `{
+	"schema": "type Query { user(id: ID!): User, users: [User!]! } type Mutation { createUser(name: String!): User } type User { id: ID! name: String age: Int tags: [String] }",
+	"resolvers": {
+		"User": {"name": "bingoo", "age": 18},
+		"Query.users": [{"id": "1"}, {"id": "2"}]
+	},
+	"listSize": 2
+}`
+
+func serveGraphQL(t *testing.T, query string, variables map[string]interface{}) string {
+	t.Helper()
+
+	g := &GraphQL{}
+	assert.Nil(t, json.Unmarshal([]byte(graphqlTestConfig), g))
+	g.AfterUnmashal()
+
+	body, _ := json.Marshal(GraphQLRequest{Query: query, Variables: variables})
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	c.Request.Header.Set("Content-Type", "application/json")
+	assert.Nil(t, g.HlHandle(c, &APIDataModel{Endpoint: "/graphql"}, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
+
+func TestGraphQLQuery(t *testing.T) {
+	rsp := serveGraphQL(t, `query Q($id: ID!) { user(id: $id) { id name age tags __typename } users { id name } }`,
+		map[string]interface{}{"id": "42"})
+
+	assert.False(t, jj.Get(rsp, "errors").Exists(), rsp)
+	assert.JSONEq(t, `{"id":"42","name":"bingoo","age":18,"__typename":"User","tags":`+jj.Get(rsp, "data.user.tags").Raw+`}`,
+		jj.Get(rsp, "data.user").Raw)
+	assert.Len(t, jj.Get(rsp, "data.user.tags").Array(), 2, "the list size")
+	// the fields missing in the field resolvers are generated from their types.
+	assert.Equal(t, `["1","2"]`, jj.Get(rsp, "data.users.#.id").Raw)
+	for _, name := range jj.Get(rsp, "data.users.#.name").Array() {
+		assert.Equal(t, jj.String, name.Type)
+	}
+
+	rsp = serveGraphQL(t, `mutation { createUser(name: "huang") { name } }`, nil)
+	assert.JSONEq(t, `{"data":{"createUser":{"name":"huang"}}}`, rsp)
+
+	rsp = serveGraphQL(t, `{ user(id: 1) { missing } }`, nil)
+	assert.True(t, jj.Get(rsp, "errors.0.message").Exists(), rsp)
+	assert.False(t, jj.Get(rsp, "data").Exists(), rsp)
+}
+
+func TestGraphQLFragmentSkip(t *testing.T) {
+	query := `query Q($skip: Boolean!) {
+		user(id: "1") { ...UserFields age @skip(if: $skip) }
+	}
+	fragment UserFields on User { id name @include(if: true) tags @skip(if: true) }`
+
+	rsp := serveGraphQL(t, query, map[string]interface{}{"skip": true})
+	assert.JSONEq(t, `{"data":{"user":{"id":"1","name":"bingoo"}}}`, rsp)
+
+	rsp = serveGraphQL(t, query, map[string]interface{}{"skip": false})
+	assert.JSONEq(t, `{"data":{"user":{"id":"1","name":"bingoo","age":18}}}`, rsp)
+}
+
+// introspectionQuery is the standard introspection query of GraphiQL.
+const introspectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types { ...FullType }
+    directives { name description locations args { ...InputValue } }
+  }
+}
+fragment FullType on __Type {
+  kind name description
+  fields(includeDeprecated: true) { name description args { ...InputValue } type { ...TypeRef } isDeprecated deprecationReason }
+  inputFields { ...InputValue }
+  interfaces { ...TypeRef }
+  enumValues(includeDeprecated: true) { name description isDeprecated deprecationReason }
+  possibleTypes { ...TypeRef }
+}
+fragment InputValue on __InputValue { name description type { ...TypeRef } defaultValue }
+fragment TypeRef on __Type {
+  kind name
+  ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name } } } } } } }
+}`
+
+func TestGraphQLIntrospection(t *testing.T) {
+	rsp := serveGraphQL(t, introspectionQuery, nil)
+	assert.False(t, jj.Get(rsp, "errors").Exists(), rsp)
+
+	schema := jj.Get(rsp, "data.__schema")
+	assert.Equal(t, "Query", schema.Get("queryType.name").String())
+	assert.Equal(t, "Mutation", schema.Get("mutationType.name").String())
+	assert.Equal(t, jj.Null, schema.Get("subscriptionType").Type)
+
+	types := map[string]jj.Result{}
+	for _, typ := range schema.Get("types").Array() {
+		types[typ.Get("name").String()] = typ
+	}
+	for _, name := range []string{"Query", "Mutation", "User", "String", "ID", "Int", "Boolean", "__Schema", "__Type"} {
+		assert.Contains(t, types, name)
+	}
+
+	user := types["User"]
+	assert.Equal(t, "OBJECT", user.Get("kind").String())
+	assert.Equal(t, `["id","name","age","tags"]`, user.Get("fields.#.name").Raw)
+	assert.JSONEq(t, `{"kind":"NON_NULL","name":null,"ofType":{"kind":"SCALAR","name":"ID","ofType":null}}`,
+		user.Get("fields.0.type").Raw)
+	assert.JSONEq(t, `{"kind":"LIST","name":null,"ofType":{"kind":"SCALAR","name":"String","ofType":null}}`,
+		user.Get("fields.3.type").Raw)
+	assert.Equal(t, jj.Null, user.Get("inputFields").Type)
+
+	userField := types["Query"].Get(`fields.#(name=="user")`)
+	assert.JSONEq(t, `[{"name":"id","description":null,"defaultValue":null,
+		"type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"SCALAR","name":"ID","ofType":null}}}]`,
+		userField.Get("args").Raw)
+
+	directives := schema.Get("directives.#.name").String()
+	assert.Contains(t, directives, `"skip"`)
+	assert.Contains(t, directives, `"include"`)
+}
@@ -0,0 +1,447 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/bingoohuang/jj"
+	"github.com/bufbuild/protocompile"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	v1reflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	v1alphareflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+/*
+"_hl": "grpc",
+"proto": "syntax = \"proto3\"; package helloworld; service Greeter { rpc SayHello (HelloRequest) returns (HelloReply); } ...",
+"methods": {
+  "helloworld.Greeter/SayHello": {"message": "hello {{request.body.name}}"}, // the response of the method
+  "helloworld.Greeter/SayBye": [                                             // the _dynamic-style rules on the request fields
+    {"condition": "json_name == 'bingoo'", "response": {"message": "bye bingoo"}, "headers": {"x-mock": "yes"}},
+    {"condition": "json_name == ''", "status": 3, "response": {"message": "name required"}}, // gRPC code and message
+    {"response": {"message": "bye"}}
+  ]
+}
+The .proto can also be uploaded as the file of the endpoint instead of the proto field.
+The unary methods are served on /<package>.<Service>/<Method> for gRPC (HTTP/2, h2c or TLS) and gRPC-Web,
+the responses are mapped to the output messages by the protobuf JSON mapping with the proto field names,
+and the server reflection is enabled for grpcurl.
+*/
+
+// HlGRPC is the _hl of the gRPC mock endpoints.
+const HlGRPC = "grpc"
+
+// GRPCMock is a gRPC mock endpoint, serving the unary methods of the services of its .proto.
+type GRPCMock struct {
+	Endpoint string
+	File     protoreflect.FileDescriptor
+	methods  map[string]*grpcMethod // by the full method like /helloworld.Greeter/SayHello
+}
+
+type grpcMethod struct {
+	endpoint string
+	desc     protoreflect.MethodDescriptor
+	rules    []DynamicValue
+}
+
+// CreateGRPCMock creates the gRPC mock of the endpoint, nil when it is not a grpc one.
+func CreateGRPCMock(ep *APIDataModel) (*GRPCMock, error) {
+	body := ParseJSON(string(ep.Body))
+	if jj.Get(body, "_hl").String() != HlGRPC {
+		return nil, nil
+	}
+
+	source, filename := jj.Get(body, "proto").String(), ""
+	if source == "" && strings.HasSuffix(ep.Filename, ".proto") {
+		source, filename = string(ep.FileContent), ep.Filename
+	}
+	if source == "" {
+		return nil, fmt.Errorf("grpc endpoint %s: no .proto", ep.Endpoint)
+	}
+	if filename == "" {
+		filename = strings.Trim(ep.Endpoint, "/")
+		if !strings.HasSuffix(filename, ".proto") {
+			filename += ".proto"
+		}
+	}
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(map[string]string{filename: source}),
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), filename)
+	if err != nil {
+		return nil, fmt.Errorf("grpc endpoint %s: %w", ep.Endpoint, err)
+	}
+
+	m := &GRPCMock{Endpoint: ep.Endpoint, File: files[0], methods: map[string]*grpcMethod{}}
+	services := m.File.Services()
+	for i := 0; i < services.Len(); i++ {
+		methods := services.Get(i).Methods()
+		for j := 0; j < methods.Len(); j++ {
+			md := methods.Get(j)
+			m.methods[grpcFullMethod(md)] = &grpcMethod{endpoint: ep.Endpoint, desc: md}
+		}
+	}
+
+	var unknown []string
+	jj.Get(body, "methods").ForEach(func(key, value jj.Result) bool {
+		method, ok := m.methods["/"+strings.TrimPrefix(key.String(), "/")]
+		if !ok {
+			unknown = append(unknown, key.String())
+			return true
+		}
+
+		method.rules = createDynamics(ep.Workspace, body, []byte(grpcRules(value)))
+		return true
+	})
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("grpc endpoint %s: unknown methods %v", ep.Endpoint, unknown)
+	}
+
+	return m, nil
+}
+
+// grpcRules returns the _dynamic rules of the method config, the response object is the rule always matching,
+// and so are the rules without the condition and the scenario, which are the fallbacks of the methods.
+func grpcRules(config jj.Result) string {
+	if !config.IsArray() {
+		return `[{"condition":"true","response":` + config.Raw + `}]`
+	}
+
+	rules := config.Raw
+	for i, rule := range config.Array() {
+		if rule.Get("condition").String() == "" && rule.Get("scenario").String() == "" {
+			rules, _ = jj.Set(rules, fmt.Sprintf("%d.condition", i), "true")
+		}
+	}
+	return rules
+}
+
+func grpcFullMethod(md protoreflect.MethodDescriptor) string {
+	return "/" + string(md.Parent().FullName()) + "/" + string(md.Name())
+}
+
+// Describe responds the methods of the mock to the plain HTTP requests of the endpoint.
+func (m *GRPCMock) Describe(c *gin.Context) {
+	methods := make([]string, 0, len(m.methods))
+	for name := range m.methods {
+		methods = append(methods, name)
+	}
+	sort.Strings(methods)
+
+	c.JSON(http.StatusOK, gin.H{"proto": m.File.Path(), "methods": methods})
+}
+
+// GRPCServices are the gRPC mocks of a workspace.
+type GRPCServices struct {
+	methods map[string]*grpcMethod
+	infos   map[string]grpc.ServiceInfo
+	files   *protoregistry.Files
+}
+
+// NewGRPCServices collects the services of the mocks, the conflicting ones of the later mocks are ignored.
+func NewGRPCServices(mocks []*GRPCMock) *GRPCServices {
+	s := &GRPCServices{
+		methods: map[string]*grpcMethod{},
+		infos:   map[string]grpc.ServiceInfo{},
+		files:   new(protoregistry.Files),
+	}
+
+	for _, m := range mocks {
+		if err := s.files.RegisterFile(m.File); err != nil {
+			log.Printf("E! grpc endpoint %s: %v", m.Endpoint, err)
+			continue
+		}
+
+		services := m.File.Services()
+		for i := 0; i < services.Len(); i++ {
+			sd := services.Get(i)
+			info := grpc.ServiceInfo{Metadata: m.File.Path()}
+			methods := sd.Methods()
+			for j := 0; j < methods.Len(); j++ {
+				md := methods.Get(j)
+				info.Methods = append(info.Methods, grpc.MethodInfo{
+					Name: string(md.Name()), IsClientStream: md.IsStreamingClient(), IsServerStream: md.IsStreamingServer(),
+				})
+				s.methods[grpcFullMethod(md)] = m.methods[grpcFullMethod(md)]
+			}
+			s.infos[string(sd.FullName())] = info
+		}
+	}
+
+	return s
+}
+
+// GetServiceInfo returns the services for the server reflection.
+func (s *GRPCServices) GetServiceInfo() map[string]grpc.ServiceInfo {
+	return s.infos
+}
+
+// FindFileByPath finds the file of the mocks, or the well-known ones.
+func (s *GRPCServices) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if fd, err := s.files.FindFileByPath(path); err == nil {
+		return fd, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+// FindDescriptorByName finds the descriptor of the mocks, or the well-known ones.
+func (s *GRPCServices) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, err := s.files.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
+
+type grpcContextKey int
+
+const (
+	grpcServicesKey grpcContextKey = iota
+	grpcRequestKey
+)
+
+// IsGRPCRequest tells whether the request is a gRPC or gRPC-Web one.
+func IsGRPCRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+var grpcServer = newGRPCServer()
+
+func newGRPCServer() *grpc.Server {
+	s := grpc.NewServer(grpc.UnknownServiceHandler(serveGRPCStream))
+	v1reflectiongrpc.RegisterServerReflectionServer(s, grpcReflection{})
+	v1alphareflectiongrpc.RegisterServerReflectionServer(s, grpcReflectionV1Alpha{})
+	return s
+}
+
+// ServeGRPC serves the gRPC or gRPC-Web request by the services.
+func ServeGRPC(w http.ResponseWriter, r *http.Request, services *GRPCServices) {
+	ctx := context.WithValue(r.Context(), grpcServicesKey, services)
+	r = r.WithContext(context.WithValue(ctx, grpcRequestKey, r))
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc-web") {
+		serveGRPCWeb(w, r, services)
+		return
+	}
+
+	grpcServer.ServeHTTP(&grpcResponseWriter{ResponseWriter: w}, r)
+}
+
+// grpcResponseWriter writes the status 200 explicitly, which grpc leaves to the default of the http.ResponseWriter,
+// while the gin writer of the no route requests defaults to 404.
+type grpcResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *grpcResponseWriter) WriteHeader(code int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *grpcResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *grpcResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func servicesOf(ctx context.Context) *GRPCServices {
+	if s, ok := ctx.Value(grpcServicesKey).(*GRPCServices); ok {
+		return s
+	}
+	return NewGRPCServices(nil)
+}
+
+func (s *GRPCServices) method(fullMethod string) (*grpcMethod, error) {
+	m, ok := s.methods[fullMethod]
+	switch {
+	case !ok:
+		return nil, status.Errorf(codes.Unimplemented, "method %s not found", fullMethod)
+	case m.desc.IsStreamingClient() || m.desc.IsStreamingServer():
+		return nil, status.Errorf(codes.Unimplemented, "streaming method %s is not supported", fullMethod)
+	default:
+		return m, nil
+	}
+}
+
+func serveGRPCStream(_ interface{}, stream grpc.ServerStream) error {
+	fullMethod, _ := grpc.MethodFromServerStream(stream)
+	m, err := servicesOf(stream.Context()).method(fullMethod)
+	if err != nil {
+		return err
+	}
+
+	in := dynamicpb.NewMessage(m.desc.Input())
+	if err := stream.RecvMsg(in); err != nil {
+		return err
+	}
+
+	r := stream.Context().Value(grpcRequestKey).(*http.Request)
+	out, md, err := m.invoke(stream.Context(), r, in)
+	if len(md) > 0 {
+		_ = stream.SetHeader(md)
+	}
+	if err != nil {
+		return err
+	}
+	return stream.SendMsg(out)
+}
+
+// invoke responds by the first matched rule of the method, or the empty output message when no rule matches.
+func (m *grpcMethod) invoke(ctx context.Context, r *http.Request, in proto.Message) (proto.Message, metadata.MD, error) {
+	reqJSON, err := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(in)
+	if err != nil {
+		return nil, nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	req := r.Clone(ctx)
+	req.Body = io.NopCloser(bytes.NewReader(reqJSON))
+	c := &gin.Context{Request: req}
+
+	out := dynamicpb.NewMessage(m.desc.Output())
+	v, ok, err := matchDynamic(c, reqJSON, m.rules)
+	if err != nil {
+		return nil, nil, status.Error(codes.Internal, err.Error())
+	}
+	if !ok {
+		return out, nil, nil
+	}
+
+	t := NewRequestTemplate(c)
+	md := metadata.MD{}
+	for k, h := range v.Headers {
+		md.Append(k, t.Render(h))
+	}
+
+	payload, err := Eval(m.endpoint, string(v.Response))
+	if err != nil {
+		return nil, md, status.Error(codes.Internal, err.Error())
+	}
+	payload = t.RenderBody(payload)
+
+	if v.Status != 0 {
+		message := jj.Get(payload, "message").String()
+		return nil, md, status.Error(codes.Code(v.Status), message)
+	}
+
+	if strings.TrimSpace(payload) != "" {
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal([]byte(payload), out); err != nil {
+			return nil, md, status.Errorf(codes.Internal, "response of %s: %v", grpcFullMethod(m.desc), err)
+		}
+	}
+	return out, md, nil
+}
+
+// serveGRPCWeb serves the unary gRPC-Web request, in binary or base64 text.
+func serveGRPCWeb(w http.ResponseWriter, r *http.Request, services *GRPCServices) {
+	contentType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	text := strings.HasPrefix(contentType, "application/grpc-web-text")
+
+	out, md, err := invokeGRPCWeb(r, services, text)
+	for k, values := range md {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err == nil {
+		data, _ := proto.Marshal(out)
+		writeGRPCWebFrame(&buf, 0, data)
+	}
+	st := status.Convert(err)
+	trailer := fmt.Sprintf("grpc-status: %d\r\ngrpc-message: %s\r\n", st.Code(), url.PathEscape(st.Message()))
+	writeGRPCWebFrame(&buf, 0x80, []byte(trailer))
+
+	data := buf.Bytes()
+	if text {
+		data = []byte(base64.StdEncoding.EncodeToString(data))
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Access-Control-Expose-Headers", "grpc-status, grpc-message")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+func invokeGRPCWeb(r *http.Request, services *GRPCServices, text bool) (proto.Message, metadata.MD, error) {
+	// the path may have the prefix of the context path or the workspace, the method is its last two segments.
+	segments := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	if len(segments) < 3 {
+		return nil, nil, status.Errorf(codes.Unimplemented, "method %s not found", r.URL.Path)
+	}
+	m, err := services.method("/" + strings.Join(segments[len(segments)-2:], "/"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err == nil && text {
+		body, err = base64.StdEncoding.DecodeString(string(body))
+	}
+	if err == nil && (len(body) < 5 || int(binary.BigEndian.Uint32(body[1:5])) > len(body)-5) {
+		err = errors.New("malformed gRPC-Web frame")
+	}
+	if err != nil {
+		return nil, nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	in := dynamicpb.NewMessage(m.desc.Input())
+	if err := proto.Unmarshal(body[5:5+binary.BigEndian.Uint32(body[1:5])], in); err != nil {
+		return nil, nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return m.invoke(r.Context(), r, in)
+}
+
+func writeGRPCWebFrame(buf *bytes.Buffer, flag byte, data []byte) {
+	buf.WriteByte(flag)
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(data)))
+	buf.Write(data)
+}
+
+// grpcReflection serves the server reflection by the services of the workspace of the request.
+type grpcReflection struct{}
+
+func (grpcReflection) ServerReflectionInfo(stream v1reflectiongrpc.ServerReflection_ServerReflectionInfoServer) error {
+	s := servicesOf(stream.Context())
+	return reflection.NewServerV1(reflection.ServerOptions{Services: s, DescriptorResolver: s}).ServerReflectionInfo(stream)
+}
+
+type grpcReflectionV1Alpha struct{}
+
+func (grpcReflectionV1Alpha) ServerReflectionInfo(stream v1alphareflectiongrpc.ServerReflection_ServerReflectionInfoServer) error {
+	s := servicesOf(stream.Context())
+	return reflection.NewServer(reflection.ServerOptions{Services: s, DescriptorResolver: s}).ServerReflectionInfo(stream)
+}
@@ -0,0 +1,206 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	v1reflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const greeterProto = `syntax = "proto3";
+package helloworld;
+service Greeter {
+  rpc SayHello (HelloRequest) returns (HelloReply);
+  rpc SayBye (HelloRequest) returns (HelloReply);
+}
+message HelloRequest { string name = 1; }
+message HelloReply { string message = 1; }`
+
+func prepareGreeter(t *testing.T) *GRPCMock {
+	t.Helper()
+
+	m, err := CreateGRPCMock(&APIDataModel{Endpoint: "/greeter", Body: RawMessage(`{
+		"_hl": "grpc",
+		"proto": ` + quoteJSON(greeterProto) + `,
+		"methods": {
+			"helloworld.Greeter/SayHello": {"message": "hello {{request.body.name}}"},
+			"helloworld.Greeter/SayBye": [
+				{"condition": "json_name == 'bingoo'", "response": {"message": "bye bingoo"}, "headers": {"x-mock": "yes"}},
+				{"condition": "json_name == ''", "status": 3, "response": {"message": "name required"}},
+				{"response": {"message": "bye"}}
+			]
+		}
+	}`)})
+	assert.Nil(t, err)
+	return m
+}
+
+func quoteJSON(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func greeterMessage(m *GRPCMock, name protoreflect.Name, fields map[string]string) *dynamicpb.Message {
+	d := m.File.Messages().ByName(name)
+	msg := dynamicpb.NewMessage(d)
+	for k, v := range fields {
+		msg.Set(d.Fields().ByName(protoreflect.Name(k)), protoreflect.ValueOfString(v))
+	}
+	return msg
+}
+
+func messageOf(msg proto.Message) string {
+	m := msg.ProtoReflect()
+	return m.Get(m.Descriptor().Fields().ByName("message")).String()
+}
+
+// serveGreeter serves the gRPC requests over h2c, the same as httplive does by the gin engine.
+func serveGreeter(t *testing.T, m *GRPCMock) *grpc.ClientConn {
+	t.Helper()
+	gin.SetMode(gin.ReleaseMode)
+
+	services := NewGRPCServices([]*GRPCMock{m})
+	e := gin.New()
+	e.UseH2C = true
+	e.NoRoute(func(c *gin.Context) { ServeGRPC(c.Writer, c.Request, services) })
+	srv := httptest.NewServer(e.Handler())
+	t.Cleanup(srv.Close)
+
+	conn, err := grpc.NewClient(strings.TrimPrefix(srv.URL, "http://"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestGRPCUnaryH2C(t *testing.T) {
+	m := prepareGreeter(t)
+	conn := serveGreeter(t, m)
+	ctx := context.Background()
+
+	out := greeterMessage(m, "HelloReply", nil)
+	in := greeterMessage(m, "HelloRequest", map[string]string{"name": "bingoo"})
+	assert.Nil(t, conn.Invoke(ctx, "/helloworld.Greeter/SayHello", in, out))
+	assert.Equal(t, "hello bingoo", messageOf(out))
+
+	var header metadata.MD
+	assert.Nil(t, conn.Invoke(ctx, "/helloworld.Greeter/SayBye", in, out, grpc.Header(&header)))
+	assert.Equal(t, "bye bingoo", messageOf(out))
+	assert.Equal(t, []string{"yes"}, header.Get("x-mock"))
+
+	in = greeterMessage(m, "HelloRequest", map[string]string{"name": "other"})
+	assert.Nil(t, conn.Invoke(ctx, "/helloworld.Greeter/SayBye", in, out))
+	assert.Equal(t, "bye", messageOf(out), "the fallback")
+
+	in = greeterMessage(m, "HelloRequest", nil)
+	err := conn.Invoke(ctx, "/helloworld.Greeter/SayBye", in, out)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "name required", status.Convert(err).Message())
+
+	err = conn.Invoke(ctx, "/helloworld.Greeter/SayNothing", in, out)
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestGRPCReflection(t *testing.T) {
+	conn := serveGreeter(t, prepareGreeter(t))
+
+	stream, err := v1reflectiongrpc.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	assert.Nil(t, err)
+	assert.Nil(t, stream.Send(&v1reflectiongrpc.ServerReflectionRequest{
+		MessageRequest: &v1reflectiongrpc.ServerReflectionRequest_ListServices{},
+	}))
+	rsp, err := stream.Recv()
+	assert.Nil(t, err)
+
+	var services []string
+	for _, s := range rsp.GetListServicesResponse().GetService() {
+		services = append(services, s.GetName())
+	}
+	assert.Equal(t, []string{"helloworld.Greeter"}, services)
+}
+
+// grpcWebCall calls the mock by gRPC-Web, and returns the message and the trailer frames.
+func grpcWebCall(t *testing.T, m *GRPCMock, contentType string, body []byte) (message, trailer []byte) {
+	t.Helper()
+
+	text := strings.HasPrefix(contentType, "application/grpc-web-text")
+	if text {
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+	r := httptest.NewRequest("POST", "/helloworld.Greeter/SayHello", bytes.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	ServeGRPC(w, r, NewGRPCServices([]*GRPCMock{m}))
+	assert.Equal(t, contentType, w.Header().Get("Content-Type"))
+
+	data := w.Body.Bytes()
+	if text {
+		var err error
+		data, err = base64.StdEncoding.DecodeString(string(data))
+		assert.Nil(t, err)
+	}
+	for len(data) >= 5 {
+		flag, n := data[0], binary.BigEndian.Uint32(data[1:5])
+		frame := data[5 : 5+n]
+		if flag&0x80 != 0 {
+			trailer = frame
+		} else {
+			message = frame
+		}
+		data = data[5+n:]
+	}
+	return message, trailer
+}
+
+func TestGRPCWeb(t *testing.T) {
+	m := prepareGreeter(t)
+	in, _ := proto.Marshal(greeterMessage(m, "HelloRequest", map[string]string{"name": "web"}))
+	var frame bytes.Buffer
+	writeGRPCWebFrame(&frame, 0, in)
+
+	for _, contentType := range []string{"application/grpc-web+proto", "application/grpc-web-text"} {
+		message, trailer := grpcWebCall(t, m, contentType, frame.Bytes())
+		assert.Contains(t, string(trailer), "grpc-status: 0\r\n", contentType)
+
+		out := greeterMessage(m, "HelloReply", nil)
+		assert.Nil(t, proto.Unmarshal(message, out))
+		assert.Equal(t, "hello web", messageOf(out), contentType)
+	}
+
+	for _, body := range [][]byte{[]byte("abc"), {0, 0, 0, 0, 9, 1}} {
+		message, trailer := grpcWebCall(t, m, "application/grpc-web+proto", body)
+		assert.Nil(t, message)
+		assert.Equal(t, "grpc-status: 3\r\ngrpc-message: malformed%20gRPC-Web%20frame\r\n", string(trailer))
+	}
+}
+
+func TestCreateGRPCMockErrors(t *testing.T) {
+	for _, body := range []string{
+		`{"_hl": "grpc"}`,
+		`{"_hl": "grpc", "proto": "syntax = \"proto3\"; service Greeter {"}`,
+		`{"_hl": "grpc", "proto": ` + quoteJSON(greeterProto) + `, "methods": {"helloworld.Greeter/SayNothing": {}}}`,
+	} {
+		m, err := CreateGRPCMock(&APIDataModel{Endpoint: "/greeter", Body: RawMessage(body)})
+		assert.Nil(t, m, body)
+		assert.NotNil(t, err, body)
+	}
+
+	m, err := CreateGRPCMock(&APIDataModel{Endpoint: "/greeter", Body: RawMessage(`{"a": 1}`)})
+	assert.Nil(t, m, "not a grpc endpoint")
+	assert.Nil(t, err)
+}
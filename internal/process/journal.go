@@ -0,0 +1,69 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// JournalEntry is a served request/response pair kept in the request journal.
+type JournalEntry struct {
+	WsMessage `storm:"inline"`
+	Timestamp time.Time `json:"timestamp"`
+	Endpoint  string    `json:"endpoint"`
+	Workspace string    `json:"workspace,omitempty"`
+	ID        uint64    `json:"id" storm:"id,increment"`
+}
+
+// JournalFilter filters the journal entries, the zero fields match any.
+type JournalFilter struct {
+	Since    time.Time
+	Until    time.Time
+	Endpoint string
+	Method   string
+	// Body is the substring of the request body.
+	Body      string
+	Status    int
+	Limit     int
+	Workspace string
+}
+
+// Match tests if the entry matches the filter.
+func (f JournalFilter) Match(e JournalEntry) bool {
+	switch {
+	case f.Endpoint != "" && f.Endpoint != e.Endpoint && f.Endpoint != e.Path:
+		return false
+	case f.Workspace != "" && f.Workspace != e.Workspace:
+		return false
+	case f.Method != "" && !strings.EqualFold(f.Method, e.Method):
+		return false
+	case f.Status != 0 && f.Status != e.ResponseStatus:
+		return false
+	case !f.Since.IsZero() && e.Timestamp.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && e.Timestamp.After(f.Until):
+		return false
+	case f.Body != "" && !strings.Contains(bodyString(e.Body), f.Body):
+		return false
+	default:
+		return true
+	}
+}
+
+func bodyString(body interface{}) string {
+	switch t := body.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case json.RawMessage:
+		return string(t)
+	default:
+		data, err := json.Marshal(t)
+		if err != nil {
+			return fmt.Sprintf("%v", t)
+		}
+		return string(data)
+	}
+}
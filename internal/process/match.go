@@ -0,0 +1,152 @@
+package process
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/bingoohuang/jj"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+"_match": [
+  {
+    "priority": 10, // the rules of higher priority are tried first, then by their order
+    "when": {
+      "method": "POST",
+      "headers": {"Content-Type": {"contains": "json"}},
+      "query": {"page": "1", "size": {"min": 1, "max": 100}},
+      "params": {"id": {"matches": "^[0-9]+$"}}, // the router params like /user/:id
+      "body": {"user.name": "bingoo", "user.age": {"min": 18}, "items": {"exists": true}} // jj paths
+    },
+    "status": 201,
+    "headers": {"X-Matched": "yes"},
+    "response": {"id": 1, "name": "bingoo"}
+  }
+]
+// the endpoint responds its default response when no rule matches.
+*/
+
+// MatchRule is a declarative rule of `_match`, which responds when all its matchers match the request.
+type MatchRule struct {
+	When     RequestMatcher    `json:"when"`
+	Headers  map[string]string `json:"headers"`
+	Response json.RawMessage   `json:"response"`
+	Status   int               `json:"status"`
+	Priority int               `json:"priority"`
+}
+
+// RequestMatcher matches the method, headers, query, router params and JSON body of the request.
+type RequestMatcher struct {
+	Headers map[string]*ValueMatcher `json:"headers"`
+	Query   map[string]*ValueMatcher `json:"query"`
+	Params  map[string]*ValueMatcher `json:"params"`
+	Body    map[string]*ValueMatcher `json:"body"`
+	Method  string                   `json:"method"`
+}
+
+// CreateMatchRules creates the rules from the `_match` block of the endpoint body, ordered by their priorities,
+// nil when the block is absent.
+func CreateMatchRules(endpoint, body string) ([]MatchRule, error) {
+	match := jj.Get(body, "_match")
+	if !match.Exists() {
+		return nil, nil
+	}
+	if !match.IsArray() {
+		return nil, fmt.Errorf("_match of %s: an array of rules expected", endpoint)
+	}
+
+	var rules []MatchRule
+	if err := json.Unmarshal([]byte(match.Raw), &rules); err != nil {
+		return nil, fmt.Errorf("_match of %s: %w", endpoint, err)
+	}
+
+	for i, r := range rules {
+		if err := r.When.Compile(); err != nil {
+			return nil, fmt.Errorf("_match #%d of %s: %w", i+1, endpoint, err)
+		}
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].Priority > rules[j].Priority })
+	return rules, nil
+}
+
+// Compile compiles the regular expressions of the matchers.
+func (m *RequestMatcher) Compile() error {
+	return compileMatchers(map[string]map[string]*ValueMatcher{
+		"headers": m.Headers, "query": m.Query, "params": m.Params, "body": m.Body,
+	})
+}
+
+// Match tells whether the request matches all the matchers, reqBody is the body of the request.
+func (m *RequestMatcher) Match(c *gin.Context, reqBody []byte) bool {
+	if m.Method != "" && !strings.EqualFold(m.Method, c.Request.Method) {
+		return false
+	}
+
+	for k, vm := range m.Headers {
+		values := c.Request.Header.Values(k)
+		if !vm.matchString(strings.Join(values, ","), len(values) > 0) {
+			return false
+		}
+	}
+	for k, vm := range m.Query {
+		if value, exists := c.GetQuery(k); !vm.matchString(value, exists) {
+			return false
+		}
+	}
+	for k, vm := range m.Params {
+		if value, exists := c.Params.Get(k); !vm.matchString(value, exists) {
+			return false
+		}
+	}
+
+	for k, vm := range m.Body {
+		if !vm.matchJSON(jj.GetBytes(reqBody, k)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchProcess responds by the first matched `_match` rule, false when no rule matches.
+func matchProcess(c *gin.Context, ep APIDataModel) bool {
+	if len(ep.matchRules) == 0 {
+		return false
+	}
+
+	reqBody, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		log.Printf("E! readall %v", err)
+		return false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(reqBody))
+
+	for _, r := range ep.matchRules {
+		if r.When.Match(c, reqBody) {
+			DynamicValue{Headers: r.Headers, Response: r.Response, Status: r.Status}.responseDynamic(ep, c)
+			return true
+		}
+	}
+
+	return false
+}
+
+// compileMatchers compiles the regular expressions of the matchers of the parts.
+func compileMatchers(parts map[string]map[string]*ValueMatcher) error {
+	for part, matchers := range parts {
+		for k, m := range matchers {
+			if err := m.compile(); err != nil {
+				return fmt.Errorf("%s %s: %w", part, k, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package process
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const matchTestConfig = `{
+	"message": "no rule matched",
+	"_match": [
+		{"when": {"query": {"page": {"matches": "^[0-9]+$"}}}, "response": {"rule": "page"}},
+		{"priority": 10, "when": {"method": "POST", "body": {"name": "bingoo", "age": {"min": 18, "max": 60}}},
+			"status": 201, "headers": {"X-Matched": "adult"}, "response": {"rule": "adult"}},
+		{"priority": 5, "when": {"headers": {"Content-Type": {"contains": "xml"}}}, "response": {"rule": "xml"}},
+		{"priority": 5, "when": {"params": {"id": {"matches": "^[0-9]+$"}}, "query": {"size": {"max": 100}}},
+			"response": {"rule": "id"}},
+		{"when": {"body": {"items": {"exists": true}, "deleted": {"exists": false}}}, "response": {"rule": "items"}}
+	]
+}`
+
+// serveMatch serves the request by the default endpoint /users/:id of the config.
+func serveMatch(t *testing.T, config, method, target, id, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	m := &APIDataModel{Endpoint: "/users/:id", Body: RawMessage(config)}
+	assert.True(t, (&Endpoint{Endpoint: "/users/:id"}).CreateDefault(m, config, nil))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		c.Request.Header.Set("Content-Type", contentType)
+	}
+	if id != "" {
+		c.Params = gin.Params{{Key: "id", Value: id}}
+	}
+	m.ServeFn(c)
+	c.Writer.WriteHeaderNow()
+	return w
+}
+
+func TestCreateMatchRulesPriority(t *testing.T) {
+	rules, err := CreateMatchRules("/users/:id", matchTestConfig)
+	assert.Nil(t, err)
+
+	var order []string
+	for _, r := range rules {
+		order = append(order, string(r.Response))
+	}
+	assert.Equal(t, []string{`{"rule": "adult"}`, `{"rule": "xml"}`, `{"rule": "id"}`, `{"rule": "page"}`, `{"rule": "items"}`}, order,
+		"by the priorities, then by the order")
+
+	rules, err = CreateMatchRules("/users/:id", `{"message": "no rules"}`)
+	assert.Nil(t, err)
+	assert.Nil(t, rules)
+}
+
+func TestMatchRules(t *testing.T) {
+	for _, c := range []struct {
+		name, method, target, id, contentType, body string
+		status                                      int
+		rule                                        string
+	}{
+		{"priority wins the order", "POST", "/users/1?page=1", "", "", `{"name": "bingoo", "age": 18}`, 201, "adult"},
+		{"method", "PUT", "/users/1?page=1", "", "", `{"name": "bingoo", "age": 18}`, 200, "page"},
+		{"min", "POST", "/users/1", "", "", `{"name": "bingoo", "age": 17}`, 200, ""},
+		{"max", "POST", "/users/1", "", "", `{"name": "bingoo", "age": 61}`, 200, ""},
+		{"body equals", "POST", "/users/1", "", "", `{"name": "other", "age": 20}`, 200, ""},
+		{"header contains", "GET", "/users/1", "", "application/xml", "", 200, "xml"},
+		{"params matches", "GET", "/users/1?size=10", "1", "", "", 200, "id"},
+		{"params mismatches", "GET", "/users/me?size=10", "me", "", "", 200, ""},
+		{"query max", "GET", "/users/1?size=100", "1", "", "", 200, "id"},
+		{"query over max", "GET", "/users/1?size=101", "1", "", "", 200, ""},
+		{"query matches", "GET", "/users/1?page=2", "", "", "", 200, "page"},
+		{"query mismatches", "GET", "/users/1?page=x", "", "", "", 200, ""},
+		{"body exists", "POST", "/users/1", "", "", `{"items": []}`, 200, "items"},
+		{"body exists false", "POST", "/users/1", "", "", `{"items": [], "deleted": true}`, 200, ""},
+	} {
+		w := serveMatch(t, matchTestConfig, c.method, c.target, c.id, c.contentType, c.body)
+		assert.Equal(t, c.status, w.Code, c.name)
+		if c.rule == "" {
+			assert.JSONEq(t, `{"message": "no rule matched"}`, w.Body.String(), c.name)
+		} else {
+			assert.JSONEq(t, `{"rule": "`+c.rule+`"}`, w.Body.String(), c.name)
+		}
+		if c.rule == "adult" {
+			assert.Equal(t, "adult", w.Header().Get("X-Matched"))
+		}
+	}
+}
+
+func TestCreateMatchRulesErrors(t *testing.T) {
+	for _, config := range []string{
+		`{"_match": {"when": {}}}`,
+		`{"_match": [{"when": {"query": {"page": {"matches": "[0-9"}}}}]}`,
+		`{"_match": [{"when": {"body": {"age": {"min": "18"}}}}]}`,
+		`{"_match": [{"when": {"method": ["GET"]}}]}`,
+	} {
+		rules, err := CreateMatchRules("/users/:id", config)
+		assert.Nil(t, rules, config)
+		assert.NotNil(t, err, config)
+	}
+}
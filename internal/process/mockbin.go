@@ -85,15 +85,39 @@ func (m Mockbin) Redirect(c *gin.Context) {
 	}
 }
 
-func (m Mockbin) HlHandle(c *gin.Context, _ *APIDataModel, _ func(name string) string) error {
+func (m Mockbin) HlHandle(c *gin.Context, apiModel *APIDataModel, _ func(name string) string) error {
 	M := strings.ToUpper(m.Method)
 	if M != "" && !strings.Contains(M, "ANY") && !strings.Contains(M, c.Request.Method) {
 		c.Status(http.StatusMethodNotAllowed)
 		return nil
 	}
 
+	var step SequenceStep
+	if apiModel != nil && (apiModel.sequence != nil || apiModel.weighted != nil) {
+		if apiModel.sequence != nil {
+			var ok bool
+			if step, ok = apiModel.sequence.Next(c, *apiModel); !ok {
+				c.Status(http.StatusNotFound)
+				return nil
+			}
+		} else {
+			v := apiModel.weighted.Pick()
+			step, m.Sleep = v.SequenceStep, util.Or(v.Sleep, m.Sleep)
+		}
+		if step.Status != 0 {
+			m.Status = step.Status
+		}
+		if body := step.Body(); len(body) > 0 {
+			m.Payload, m.PayloadFile = body, ""
+		}
+	}
+
+	t := NewRequestTemplate(c)
 	for k, v := range m.Headers {
-		c.Header(k, v)
+		c.Header(k, t.Render(v))
+	}
+	for k, v := range step.Headers {
+		c.Header(k, t.Render(v))
 	}
 
 	for _, v := range m.Cookies {
@@ -106,6 +130,7 @@ func (m Mockbin) HlHandle(c *gin.Context, _ *APIDataModel, _ func(name string) s
 	}
 
 	if m.RedirectURL != "" {
+		m.RedirectURL = t.Render(m.RedirectURL)
 		m.Redirect(c)
 		return nil
 	}
@@ -137,6 +162,7 @@ func (m Mockbin) HlHandle(c *gin.Context, _ *APIDataModel, _ func(name string) s
 			return err
 		}
 	}
+	payload = t.RenderBody(payload)
 
 	c.Header("Content-Length", fmt.Sprintf("%d", len(payload)))
 	c.Data(m.Status, m.ContentType, []byte(payload))
@@ -99,8 +99,14 @@ type APIDataModel struct {
 	Filename    string          `json:"filename"`
 	FileContent []byte          `json:"-"`
 	Body        RawMessage      `json:"body"`
+	Workspace   string          `json:"workspace,omitempty" form:"workspace"`
 
 	dynamicValuers []DynamicValue
+	matchRules     []MatchRule
+	sequence       *Sequence
+	weighted       *Weighted
+	validator      *RequestValidator
+	faults         *Faults
 }
 
 // WsMessage ...
@@ -117,11 +123,13 @@ type WsMessage struct {
 	RemoteAddr     string            `json:"remoteAddr"`
 	ResponseStatus int               `json:"status"`
 	ResponseSize   int               `json:"responseSize"`
+	Violations     []Violation       `json:"violations,omitempty"`
+	Faults         []string          `json:"faults,omitempty"`
 }
 
-// Endpoint is the structure for table httplive_endpoint.
+// Endpoint is the structure for table httplive_endpoint, identified by its methods and endpoint path.
 type Endpoint struct {
-	Endpoint    string `name:"endpoint" storm:"unique"`
+	Endpoint    string `name:"endpoint" storm:"index"`
 	Methods     string `name:"methods"`
 	MimeType    string `name:"mime_type"`
 	Filename    string `name:"filename"`
@@ -129,6 +137,7 @@ type Endpoint struct {
 	CreateTime  string `name:"create_time"`
 	UpdateTime  string `name:"update_time"`
 	DeletedAt   string `name:"deleted_at"`
+	Author      string `name:"author"`
 	FileContent []byte `name:"file_content"`
 	ID          uint64 `name:"id" storm:"id,increment"`
 }
@@ -140,6 +149,7 @@ func (a APIDataModel) HandleFileDownload(c *gin.Context) {
 
 	rr := c.Request.Context().Value(RouterResultKey).(*RouterResult)
 	rr.RouterServed = true
+	rr.Endpoint = a.Endpoint
 	rr.Filename = a.Filename
 	c.Status(http.StatusOK)
 
@@ -207,6 +217,8 @@ func (a APIDataModel) CreateJsTreeModel() JsTreeDataModel {
 }
 
 func (a APIDataModel) HandleJSON(c *gin.Context) {
+	rr := c.Request.Context().Value(RouterResultKey).(*RouterResult)
+	rr.Endpoint = a.Endpoint
 	Sleep(c)
 
 	yes, fn := dealHl(c, a)
@@ -217,12 +229,18 @@ func (a APIDataModel) HandleJSON(c *gin.Context) {
 	cw := util.NewGinCopyWriter(c.Writer, c)
 	c.Writer = cw
 
-	a.ServeFn(c)
-	if fn != nil {
-		fn(c)
+	if violations := a.validator.Validate(c); len(violations) > 0 {
+		rr.Violations = violations
+		a.validator.Reject(c, violations)
+	} else {
+		rr.Faults = a.faults.Serve(c, func() {
+			a.ServeFn(c)
+			if fn != nil {
+				fn(c)
+			}
+		})
 	}
 
-	rr := c.Request.Context().Value(RouterResultKey).(*RouterResult)
 	if !rr.RouterServed {
 		rr.RouterServed = true
 		rr.RouterBody = cw.Bytes()
@@ -407,6 +425,16 @@ func (a APIDataModel) createCasbin() (*casbin.Enforcer, *sariaf.Router, map[stri
 	return e, sariafRouter, authMap
 }
 
+// CreateValidator creates the request validator from the `_validate` block of the body.
+func (a *APIDataModel) CreateValidator(body string) {
+	a.validator = CreateRequestValidator(a.Endpoint, body)
+}
+
+// CreateFaults creates the fault injection from the `_faults` block of the body.
+func (a *APIDataModel) CreateFaults(body string) {
+	a.faults = CreateFaults(a.Endpoint, body)
+}
+
 func (a *APIDataModel) TryDo(f func(*APIDataModel, string, func(name string) string) bool, body string, asset func(name string) string) bool {
 	if a.ServeFn != nil {
 		return false
@@ -518,23 +546,44 @@ func dynamicProcess(c *gin.Context, ep APIDataModel) bool {
 
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(reqBody))
 
-	for _, v := range ep.dynamicValuers {
-		parameters := make(gin.H, len(v.ParametersEvaluator))
-		for k, valuer := range v.ParametersEvaluator {
-			parameters[k] = valuer(reqBody, c)
+	v, ok, err := matchDynamic(c, reqBody, ep.dynamicValuers)
+	if err != nil {
+		log.Printf("E! Evaluate  error %v", err)
+		return false
+	}
+	if ok {
+		v.responseDynamic(ep, c)
+	}
+	return ok
+}
+
+// matchDynamic returns the first rule matching the request and its scenario, with the scenario transited.
+func matchDynamic(c *gin.Context, reqBody []byte, values []DynamicValue) (DynamicValue, bool, error) {
+	for _, v := range values {
+		if v.disabled || !v.matchScenario() {
+			continue
 		}
 
-		evaluateResult, err := expr.Run(v.Expr, parameters)
-		if err != nil {
-			log.Printf("E! Evaluate  error %v", err)
-			return false
+		if v.Expr != nil {
+			parameters := make(gin.H, len(v.ParametersEvaluator))
+			for k, valuer := range v.ParametersEvaluator {
+				parameters[k] = valuer(reqBody, c)
+			}
+
+			evaluateResult, err := expr.Run(v.Expr, parameters)
+			if err != nil {
+				return DynamicValue{}, false, err
+			}
+
+			if yes, ok := evaluateResult.(bool); !ok || !yes {
+				continue
+			}
 		}
 
-		if yes, ok := evaluateResult.(bool); ok && yes {
-			v.responseDynamic(ep, c)
-			return true
+		if v.transitScenario() {
+			return v, true, nil
 		}
 	}
 
-	return false
+	return DynamicValue{}, false, nil
 }
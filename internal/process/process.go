@@ -28,8 +28,12 @@ import (
 type RouterResult struct {
 	ResponseHeader map[string]string
 	Filename       string
+	Endpoint       string
+	Workspace      string
 	RemoteAddr     string
 	RouterBody     []byte
+	Violations     []Violation
+	Faults         []string
 	ResponseStatus int
 	ResponseSize   int
 	RouterServed   bool
@@ -60,13 +64,26 @@ func (ep Endpoint) CreateProxy(m *APIDataModel, body string, _ func(name string)
 		}
 	}
 
+	recorder := createProxyRecorder(body)
+
 	m.ServeFn = func(c *gin.Context) {
 		if teeHandler != nil {
 			teeHandler.Tee(c.Request)
 		}
 
+		var capture ProxyCapture
+		if recorder != nil {
+			capture = NewProxyCapture(ep, c.Request)
+			if recorder.Replay(c, capture.Key) {
+				return
+			}
+		}
+
 		p := pool.GetNextPeer()
 		rp := util.ReverseProxy(c.Request.URL.String(), p.Addr.Host, p.Addr.Path)
+		if recorder != nil {
+			recorder.Record(rp, capture)
+		}
 		rp.ServeHTTP(c.Writer, c.Request)
 	}
 
@@ -98,7 +115,17 @@ func (ep *Endpoint) CreateDirect(m *APIDataModel, body string, _ func(name strin
 func (ep *Endpoint) CreateDefault(m *APIDataModel, body string, _ func(name string) string) bool {
 	dynamic := jj.Get(body, "_dynamic")
 	if dynamic.Type == jj.JSON && dynamic.IsArray() {
-		m.dynamicValuers = createDynamics(body, []byte(dynamic.Raw))
+		m.dynamicValuers = createDynamics(m.Workspace, body, []byte(dynamic.Raw))
+	}
+	var err error
+	if m.matchRules, err = CreateMatchRules(ep.Endpoint, body); err != nil {
+		log.Printf("E! %v", err)
+	}
+	if m.sequence, err = CreateSequence(ep.Endpoint, body); err != nil {
+		log.Printf("E! %v", err)
+	}
+	if m.weighted, err = CreateWeighted(ep.Endpoint, body); err != nil {
+		log.Printf("E! %v", err)
 	}
 
 	model := *m
@@ -106,13 +133,21 @@ func (ep *Endpoint) CreateDefault(m *APIDataModel, body string, _ func(name stri
 	body, authBean := ParseAuth(body)
 	body, _ = jj.Delete(body, "_hl")
 	body, _ = jj.Delete(body, "_dynamic")
+	body, _ = jj.Delete(body, "_match")
+	body, _ = jj.Delete(body, "_sequence")
+	body, _ = jj.Delete(body, "_sequenceMode")
+	body, _ = jj.Delete(body, "_sequenceScope")
+	body, _ = jj.Delete(body, "_weighted")
+	body, _ = jj.Delete(body, "_weightedSeed")
+	body, _ = jj.Delete(body, "_validate")
+	body, _ = jj.Delete(body, "_faults")
 
 	m.ServeFn = func(c *gin.Context) {
 		if !authBean.AuthRequest(c) {
 			return
 		}
 
-		if dynamicProcess(c, model) {
+		if dynamicProcess(c, model) || matchProcess(c, model) || sequenceProcess(c, model) || weightedProcess(c, model) {
 			return
 		}
 
@@ -121,7 +156,7 @@ func (ep *Endpoint) CreateDefault(m *APIDataModel, body string, _ func(name stri
 		if err != nil {
 			log.Printf("E! eval %s: %v", ep.Endpoint, err)
 		}
-		util.GinData(c, []byte(dat))
+		util.GinData(c, []byte(NewRequestTemplate(c).RenderBody(dat)))
 	}
 
 	return true
@@ -194,6 +229,13 @@ func (ep *Endpoint) CreateHlHandler(m *APIDataModel, body string, asset func(nam
 	}
 
 	_, authBean := ParseAuth(body)
+	var err error
+	if m.sequence, err = CreateSequence(ep.Endpoint, body); err != nil {
+		log.Printf("E! %v", err)
+	}
+	if m.weighted, err = CreateWeighted(ep.Endpoint, body); err != nil {
+		log.Printf("E! %v", err)
+	}
 
 	m.ServeFn = func(ctx *gin.Context) {
 		if !authBean.AuthRequest(ctx) {
@@ -333,38 +375,51 @@ type visitor struct {
 	identifiers []string
 }
 
-func (v *visitor) Enter(_ *ast.Node) {}
-func (v *visitor) Visit(*ast.Node)   {}
-func (v *visitor) Exit(node *ast.Node) {
+// Visit collects the identifiers of the condition.
+func (v *visitor) Visit(node *ast.Node) {
 	if n, ok := (*node).(*ast.IdentifierNode); ok {
 		v.identifiers = append(v.identifiers, n.Value)
 	}
 }
 
-func createDynamics(epBody string, dynamicRaw []byte) (dynamicValues []DynamicValue) {
+// createDynamics creates the `_dynamic` rules of the endpoint in the workspace.
+func createDynamics(workspace, epBody string, dynamicRaw []byte) (dynamicValues []DynamicValue) {
 	if err := json.Unmarshal(dynamicRaw, &dynamicValues); err != nil {
 		fmt.Println(err)
 		return
 	}
 
 	for i, v := range dynamicValues {
+		dynamicValues[i].workspace = workspace
+		if v.Scenario != "" {
+			Scenarios.Register(workspace, v.Scenario)
+		}
+
+		// a rule without condition matches by its scenario state only.
+		if v.Condition == "" && v.Scenario != "" {
+			continue
+		}
+
+		// the broken rule never matches, the others still work.
 		tree, err := parser.Parse(v.Condition)
 		if err != nil {
-			fmt.Println(err)
-			return
+			log.Printf("E! _dynamic rule #%d condition %q: %v", i+1, v.Condition, err)
+			dynamicValues[i].disabled = true
+			continue
 		}
 
 		exp, err := expr.Compile(v.Condition)
 		if err != nil {
-			fmt.Println(err)
-			return
+			log.Printf("E! _dynamic rule #%d condition %q: %v", i+1, v.Condition, err)
+			dynamicValues[i].disabled = true
+			continue
 		}
 
 		vi := &visitor{}
 		ast.Walk(&tree.Node, vi)
 
 		dynamicValues[i].Expr = exp
-		dynamicValues[i].ParametersEvaluator = MakeParamValuer(epBody, vi.identifiers)
+		dynamicValues[i].ParametersEvaluator = MakeParamValuer(workspace, epBody, vi.identifiers)
 	}
 
 	return
@@ -0,0 +1,32 @@
+package process
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/expr-lang/expr"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamicConditionParameters(t *testing.T) {
+	rules := createDynamics("", `{}`, []byte(`[{"condition": "json_name == 'bingoo' && query_age == '18'", "response": "ok"}]`))
+	assert.Contains(t, rules[0].ParametersEvaluator, "json_name")
+	assert.Contains(t, rules[0].ParametersEvaluator, "query_age")
+
+	eval := func(target, body string) interface{} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", target, nil)
+		parameters := gin.H{}
+		for k, valuer := range rules[0].ParametersEvaluator {
+			parameters[k] = valuer([]byte(body), c)
+		}
+		result, err := expr.Run(rules[0].Expr, parameters)
+		assert.Nil(t, err)
+		return result
+	}
+
+	assert.Equal(t, true, eval("/?age=18", `{"name":"bingoo"}`))
+	assert.Equal(t, false, eval("/?age=18", `{"name":"other"}`))
+	assert.Equal(t, false, eval("/?age=8", `{"name":"bingoo"}`))
+}
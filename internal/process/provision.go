@@ -0,0 +1,23 @@
+package process
+
+// ProvisionRecord records the endpoints provisioned from a file of the provisioning directory,
+// to remove them when they are removed from the file, or the file is deleted.
+type ProvisionRecord struct {
+	File      string                `json:"file" storm:"id"`
+	Endpoints []ProvisionedEndpoint `json:"endpoints"`
+}
+
+// ProvisionedEndpoint is the identity of a provisioned endpoint.
+type ProvisionedEndpoint struct {
+	Workspace string `json:"workspace,omitempty"`
+	Method    string `json:"method"`
+	Endpoint  string `json:"endpoint"`
+}
+
+// String returns the identity like "GET /path", prefixed by the workspace in brackets.
+func (p ProvisionedEndpoint) String() string {
+	if p.Workspace != "" {
+		return "[" + p.Workspace + "] " + p.Method + " " + p.Endpoint
+	}
+	return p.Method + " " + p.Endpoint
+}
@@ -0,0 +1,174 @@
+package process
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/bingoohuang/httplive/pkg/util"
+	"github.com/bingoohuang/jj"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+"_proxy": "http://127.0.0.1:5003/api/demo",
+"_record": true, // capture every upstream request/response pair into the bolt DB
+"_replay": true  // serve the captures without contacting the backend, fallback to proxy when missing
+*/
+
+// ProxyCapture is an upstream request/response pair captured by a `_record` proxy endpoint.
+type ProxyCapture struct {
+	Key        string              `json:"key" storm:"id"`
+	Workspace  string              `json:"workspace"`
+	Methods    string              `json:"methods"`
+	Endpoint   string              `json:"endpoint" storm:"index"`
+	Method     string              `json:"method"`
+	Path       string              `json:"path"`
+	Query      string              `json:"query"`
+	BodyHash   string              `json:"bodyHash"`
+	Header     map[string][]string `json:"header"`
+	Body       []byte              `json:"body"`
+	CreateTime string              `json:"createTime"`
+	Status     int                 `json:"status"`
+}
+
+var (
+	// SaveProxyCapture persists the capture, it is set by the httplive package.
+	SaveProxyCapture = func(ProxyCapture) error { return nil }
+	// FindProxyCapture finds the capture by its key, it is set by the httplive package.
+	FindProxyCapture = func(key string) *ProxyCapture { return nil }
+)
+
+type proxyRecorder struct {
+	record bool
+	replay bool
+}
+
+func createProxyRecorder(body string) *proxyRecorder {
+	r := &proxyRecorder{
+		record: jj.Get(body, "_record").Type == jj.True,
+		replay: jj.Get(body, "_replay").Type == jj.True,
+	}
+
+	if !r.record && !r.replay {
+		return nil
+	}
+
+	return r
+}
+
+// captureQueryIgnored are the httplive control query parameters which should not be part of the capture key.
+var captureQueryIgnored = []string{"_hl", "_sleep", "_target", "_abort"}
+
+// NewProxyCapture creates a capture of the request to the endpoint with the key made by the workspace,
+// the methods and the path of the endpoint, and the method, path, normalized query and body hash of the request.
+// The endpoints proxying the same requests to different backends have their own captures.
+func NewProxyCapture(ep Endpoint, r *http.Request) ProxyCapture {
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewBuffer(body))
+	}
+
+	workspace := ""
+	if rr, ok := r.Context().Value(RouterResultKey).(*RouterResult); ok {
+		workspace = rr.Workspace
+	}
+
+	sum := sha256.Sum256(body)
+	capture := ProxyCapture{
+		Workspace: workspace,
+		Methods:   ep.Methods,
+		Endpoint:  ep.Endpoint,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Query:     normalizeQuery(r.URL.Query()),
+		BodyHash:  hex.EncodeToString(sum[:]),
+	}
+	capture.Key = capture.Workspace + " " + capture.Methods + " " + capture.Endpoint + "|" +
+		capture.Method + " " + capture.Path + "?" + capture.Query + "#" + capture.BodyHash
+	return capture
+}
+
+func normalizeQuery(values url.Values) string {
+	for _, k := range captureQueryIgnored {
+		delete(values, k)
+	}
+
+	for _, v := range values {
+		sort.Strings(v)
+	}
+
+	// Encode sorts the keys.
+	return values.Encode()
+}
+
+// Replay writes the captured response if it exists.
+func (r *proxyRecorder) Replay(c *gin.Context, key string) bool {
+	if !r.replay {
+		return false
+	}
+
+	capture := FindProxyCapture(key)
+	if capture == nil {
+		return false
+	}
+
+	h := c.Writer.Header()
+	for k, values := range capture.Header {
+		if util.AnyOf(http.CanonicalHeaderKey(k), "Content-Length", "Transfer-Encoding", "Connection") {
+			continue
+		}
+		for _, v := range values {
+			h.Add(k, v)
+		}
+	}
+	h.Set("Replayed", "Httplive")
+
+	c.Status(capture.Status)
+	if _, err := c.Writer.Write(capture.Body); err != nil {
+		log.Printf("E! replay %s: %v", key, err)
+	}
+
+	return true
+}
+
+// Record hooks the reverse proxy to capture the upstream response.
+func (r *proxyRecorder) Record(rp *httputil.ReverseProxy, capture ProxyCapture) {
+	if !r.record {
+		return
+	}
+
+	modifyResponse := rp.ModifyResponse
+	rp.ModifyResponse = func(rsp *http.Response) error {
+		if modifyResponse != nil {
+			if err := modifyResponse(rsp); err != nil {
+				return err
+			}
+		}
+
+		body, err := io.ReadAll(rsp.Body)
+		_ = rsp.Body.Close()
+		rsp.Body = io.NopCloser(bytes.NewBuffer(body))
+		if err != nil {
+			return err
+		}
+
+		capture.Header = rsp.Header.Clone()
+		capture.Body = body
+		capture.Status = rsp.StatusCode
+		capture.CreateTime = util.TimeFmt(time.Now())
+		if err := SaveProxyCapture(capture); err != nil {
+			log.Printf("E! record %s: %v", capture.Key, err)
+		}
+
+		return nil
+	}
+}
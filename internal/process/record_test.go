@@ -0,0 +1,140 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// prepareCaptures keeps the captures in the memory for the test.
+func prepareCaptures(t *testing.T) map[string]ProxyCapture {
+	captures := map[string]ProxyCapture{}
+	var lock sync.Mutex
+
+	save, find := SaveProxyCapture, FindProxyCapture
+	t.Cleanup(func() { SaveProxyCapture, FindProxyCapture = save, find })
+	SaveProxyCapture = func(capture ProxyCapture) error {
+		lock.Lock()
+		defer lock.Unlock()
+		captures[capture.Key] = capture
+		return nil
+	}
+	FindProxyCapture = func(key string) *ProxyCapture {
+		lock.Lock()
+		defer lock.Unlock()
+		if capture, ok := captures[key]; ok {
+			return &capture
+		}
+		return nil
+	}
+	return captures
+}
+
+// prepareUpstream serves the responses numbered by the hits of the backend, and returns its URL.
+func prepareUpstream(t *testing.T, name string) (string, *atomic.Int32) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Backend", name)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%s #%d %s", name, hits.Add(1), body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, &hits
+}
+
+func prepareProxy(t *testing.T, upstream, config string) *APIDataModel {
+	t.Helper()
+
+	ep := Endpoint{Endpoint: "/api", Methods: "ANY"}
+	m := &APIDataModel{Endpoint: ep.Endpoint, Method: ep.Methods}
+	body := `{"_proxy": "` + upstream + `/api", ` + config + `}`
+	assert.True(t, ep.CreateProxy(m, body, nil))
+	return m
+}
+
+// serveProxy serves the request to the proxy endpoint in the workspace, by a server for the reverse proxy
+// which needs the response writer to be a http.CloseNotifier.
+func serveProxy(t *testing.T, m *APIDataModel, workspace, target, body string) (rsp *http.Response, data string) {
+	t.Helper()
+	gin.SetMode(gin.ReleaseMode)
+
+	e := gin.New()
+	e.Any(m.Endpoint, func(c *gin.Context) {
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), RouterResultKey, &RouterResult{Workspace: workspace}))
+		m.ServeFn(c)
+	})
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	rsp, err := http.Post(srv.URL+target, "text/plain", strings.NewReader(body))
+	assert.Nil(t, err)
+	defer rsp.Body.Close()
+	b, _ := io.ReadAll(rsp.Body)
+	return rsp, string(b)
+}
+
+func TestNewProxyCapture(t *testing.T) {
+	ep := Endpoint{Endpoint: "/api/:id", Methods: "GET"}
+	r := httptest.NewRequest("POST", "/api/1?b=2&a=1&a=0&_sleep=1s", strings.NewReader("x"))
+	r = r.WithContext(context.WithValue(r.Context(), RouterResultKey, &RouterResult{Workspace: "ws"}))
+
+	capture := NewProxyCapture(ep, r)
+	assert.Equal(t, "ws", capture.Workspace)
+	assert.Equal(t, "a=0&a=1&b=2", capture.Query)
+	assert.Equal(t, "ws GET /api/:id|POST /api/1?a=0&a=1&b=2#"+capture.BodyHash, capture.Key)
+	body, _ := io.ReadAll(r.Body)
+	assert.Equal(t, "x", string(body), "the body is kept for the proxy")
+
+	r = httptest.NewRequest("POST", "/api/1?a=0&a=1&b=2", strings.NewReader("x"))
+	other := NewProxyCapture(Endpoint{Endpoint: "/api/*any", Methods: "GET"}, r)
+	assert.NotEqual(t, capture.Key, other.Key, "by the workspace and the endpoint")
+	assert.Equal(t, capture.BodyHash, other.BodyHash)
+}
+
+func TestProxyRecordReplay(t *testing.T) {
+	captures := prepareCaptures(t)
+	upstreamA, hitsA := prepareUpstream(t, "a")
+	upstreamB, hitsB := prepareUpstream(t, "b")
+
+	recorder := prepareProxy(t, upstreamA, `"_record": true`)
+	_, body := serveProxy(t, recorder, "ws", "/api?b=2&a=1", "x")
+	assert.Equal(t, "a #1 x", body)
+	assert.Len(t, captures, 1)
+
+	replayer := prepareProxy(t, upstreamA, `"_record": true, "_replay": true`)
+	rsp, body := serveProxy(t, replayer, "ws", "/api?a=1&b=2&_sleep=1ms", "x")
+	assert.Equal(t, http.StatusCreated, rsp.StatusCode)
+	assert.Equal(t, "a #1 x", body, "replayed")
+	assert.Equal(t, "Httplive", rsp.Header.Get("Replayed"))
+	assert.Equal(t, "a", rsp.Header.Get("X-Backend"))
+	assert.Equal(t, int32(1), hitsA.Load())
+
+	rsp, body = serveProxy(t, replayer, "ws", "/api?a=1&b=2", "y")
+	assert.Equal(t, "a #2 y", body, "another body is proxied and recorded")
+	assert.Empty(t, rsp.Header.Get("Replayed"))
+	assert.Len(t, captures, 2)
+
+	other := prepareProxy(t, upstreamB, `"_record": true, "_replay": true`)
+	_, body = serveProxy(t, other, "other", "/api?a=1&b=2", "x")
+	assert.Equal(t, "b #1 x", body, "not replayed from the captures of the other workspace")
+	rsp, body = serveProxy(t, other, "other", "/api?a=1&b=2", "x")
+	assert.Equal(t, "b #1 x", body)
+	assert.Equal(t, "Httplive", rsp.Header.Get("Replayed"))
+	assert.Equal(t, int32(1), hitsB.Load())
+	assert.Len(t, captures, 3)
+
+	replayOnly := prepareProxy(t, upstreamA, `"_replay": true`)
+	_, body = serveProxy(t, replayOnly, "ws", "/api", "z")
+	assert.Equal(t, "a #3 z", body, "proxied when the capture is missing")
+	assert.Len(t, captures, 3, "not recorded")
+}
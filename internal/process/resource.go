@@ -0,0 +1,426 @@
+package process
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bingoohuang/jj"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+/*
+"_hl": "resource", // with the method ANY, the endpoint /users serves /users and /users/:id
+"idField": "id",   // the id field of the items, default id
+"persist": true,   // keep the collection in the bolt DB across restarts
+"data": [{"id": 1, "name": "bingoo", "age": 18}, {"id": 2, "name": "huang", "age": 20}]
+
+GET    /users?name=bingoo&_sort=-age,name&_page=1&_limit=10  list, filtered by the fields (jj paths), X-Total-Count header
+GET    /users/:id    get, 404 when missing
+POST   /users        create, 201, the id is generated when absent (max+1 for numeric ids, uuid otherwise), 409 when it exists
+PUT    /users/:id    replace, 404 when missing, 409 when the id of the body differs
+PATCH  /users/:id    JSON merge-patch (RFC 7386), 404 when missing, 409 when the id is changed
+DELETE /users/:id    delete, 204, 404 when missing
+*/
+
+// HlResource is the _hl of the CRUD resource endpoints.
+const HlResource = "resource"
+
+// resourceIDParam is the router param of the item path of the resource endpoints.
+const resourceIDParam = "id"
+
+func init() {
+	registerHlHandlers(HlResource, func() HlHandler { return &Resource{} })
+}
+
+// Resource serves a stateful in-memory collection of JSON items, seeded from its data.
+type Resource struct {
+	IDField string          `json:"idField"`
+	Data    json.RawMessage `json:"data"`
+	Persist bool            `json:"persist"`
+}
+
+// ResourceState is the collection of a resource endpoint, reseeded when the seed data of the endpoint changes.
+type ResourceState struct {
+	Key   string            `json:"key" storm:"id"`
+	Seed  json.RawMessage   `json:"seed"`
+	Items []json.RawMessage `json:"items"`
+}
+
+var (
+	// SaveResourceState persists the collection, it is set by the httplive package.
+	SaveResourceState = func(ResourceState) error { return nil }
+	// FindResourceState finds the persisted collection by its key, it is set by the httplive package.
+	FindResourceState = func(key string) *ResourceState { return nil }
+	// DeleteResourceState deletes the persisted collection by its key, it is set by the httplive package.
+	DeleteResourceState = func(key string) error { return nil }
+	// DeleteResourceStates deletes all the persisted collections, it is set by the httplive package.
+	DeleteResourceStates = func() error { return nil }
+)
+
+var (
+	resourcesLock sync.Mutex
+	// resources are the collections in memory by their keys.
+	resources = map[string]*ResourceState{}
+)
+
+// ResourceKey returns the key of the collection of the resource endpoint.
+func ResourceKey(workspace, endpoint string) string {
+	return workspace + " " + endpoint
+}
+
+// ResourceItemPath returns the item path like /users/:id of the resource endpoint, false when the endpoint
+// is not a resource one or its path has params already.
+func ResourceItemPath(ep *APIDataModel) (string, bool) {
+	if jj.Get(ParseJSON(string(ep.Body)), "_hl").String() != HlResource {
+		return "", false
+	}
+	if _, hasParams := ParsePathParams(ep); hasParams {
+		return "", false
+	}
+
+	return path.Join(ep.Endpoint, ":"+resourceIDParam), true
+}
+
+// ResourceCounts returns the numbers of the items of the collections in memory, keyed by the collection keys.
+func ResourceCounts() map[string]int {
+	resourcesLock.Lock()
+	defer resourcesLock.Unlock()
+
+	counts := make(map[string]int, len(resources))
+	for key, s := range resources {
+		counts[key] = len(s.Items)
+	}
+	return counts
+}
+
+// ResetResources drops the collections of the endpoint, or all the collections when endpoint is empty,
+// including the persisted ones not loaded yet, they are reseeded from their data on the next requests.
+// It returns the number of the collections in memory dropped.
+func ResetResources(workspace, endpoint string) int {
+	resourcesLock.Lock()
+	defer resourcesLock.Unlock()
+
+	if endpoint != "" {
+		key := ResourceKey(workspace, endpoint)
+		if err := DeleteResourceState(key); err != nil {
+			log.Printf("E! delete resource %s: %v", key, err)
+		}
+		if _, ok := resources[key]; ok {
+			delete(resources, key)
+			return 1
+		}
+		return 0
+	}
+
+	n := len(resources)
+	if err := DeleteResourceStates(); err != nil {
+		log.Printf("E! delete resources: %v", err)
+	}
+	resources = map[string]*ResourceState{}
+	return n
+}
+
+// state returns the collection of the key, loaded from the bolt DB or seeded from the data when it is absent.
+// It must be called with resourcesLock held.
+func (r *Resource) state(key string) (*ResourceState, error) {
+	if s, ok := resources[key]; ok && bytes.Equal(s.Seed, r.Data) {
+		return s, nil
+	}
+
+	if r.Persist {
+		if s := FindResourceState(key); s != nil && bytes.Equal(s.Seed, r.Data) {
+			resources[key] = s
+			return s, nil
+		}
+	}
+
+	s := &ResourceState{Key: key, Seed: r.Data}
+	if len(r.Data) > 0 {
+		if err := json.Unmarshal(r.Data, &s.Items); err != nil {
+			return nil, err
+		}
+	}
+	resources[key] = s
+	return s, r.save(s)
+}
+
+func (r *Resource) save(s *ResourceState) error {
+	if !r.Persist {
+		return nil
+	}
+	return SaveResourceState(*s)
+}
+
+func (r *Resource) idField() string {
+	if r.IDField == "" {
+		return "id"
+	}
+	return r.IDField
+}
+
+// HlHandle serves the list and create requests on the collection path, and the others on the item path.
+func (r *Resource) HlHandle(c *gin.Context, apiModel *APIDataModel, _ func(name string) string) error {
+	workspace := ""
+	if rr, ok := c.Request.Context().Value(RouterResultKey).(*RouterResult); ok {
+		workspace = rr.Workspace
+	}
+
+	resourcesLock.Lock()
+	defer resourcesLock.Unlock()
+
+	s, err := r.state(ResourceKey(workspace, apiModel.Endpoint))
+	if err != nil {
+		return err
+	}
+
+	id, isItem := c.Params.Get(resourceIDParam)
+	switch {
+	case !isItem && c.Request.Method == http.MethodGet:
+		r.list(c, s)
+		return nil
+	case !isItem && c.Request.Method == http.MethodPost:
+		return r.create(c, s)
+	case !isItem:
+		c.Status(http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	i := r.find(s, id)
+	if i < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "item " + id + " not found"})
+		return nil
+	}
+
+	switch c.Request.Method {
+	case http.MethodGet:
+		c.Data(http.StatusOK, "application/json; charset=utf-8", s.Items[i])
+		return nil
+	case http.MethodPut, http.MethodPatch:
+		return r.update(c, s, i, id)
+	case http.MethodDelete:
+		s.Items = append(s.Items[:i], s.Items[i+1:]...)
+		c.Status(http.StatusNoContent)
+		return r.save(s)
+	default:
+		c.Status(http.StatusMethodNotAllowed)
+		return nil
+	}
+}
+
+// find returns the index of the item with the id, -1 when not found.
+func (r *Resource) find(s *ResourceState, id string) int {
+	for i, item := range s.Items {
+		if v := jj.GetBytes(item, r.idField()); v.Exists() && v.String() == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *Resource) list(c *gin.Context, s *ResourceState) {
+	items := make([]json.RawMessage, 0, len(s.Items))
+	for _, item := range s.Items {
+		if matchFilters(item, c.Request.URL.Query()) {
+			items = append(items, item)
+		}
+	}
+
+	if sortBy := c.Query("_sort"); sortBy != "" {
+		sortItems(items, strings.Split(sortBy, ","))
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(len(items)))
+	page, _ := strconv.Atoi(c.Query("_page"))
+	limit, _ := strconv.Atoi(c.Query("_limit"))
+	if page > 0 && limit <= 0 {
+		limit = 10
+	}
+	if limit > 0 {
+		start := min(max(page-1, 0)*limit, len(items))
+		items = items[start:min(start+limit, len(items))]
+	}
+
+	data, _ := json.Marshal(items)
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// matchFilters tells whether the item matches the query params not starting with _, any of the values of a param.
+func matchFilters(item json.RawMessage, query map[string][]string) bool {
+	for k, values := range query {
+		if strings.HasPrefix(k, "_") {
+			continue
+		}
+
+		v := jj.GetBytes(item, k)
+		matched := false
+		for _, value := range values {
+			if v.Exists() && v.String() == value {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// sortItems sorts the items by the fields, descending for the ones prefixed with -.
+func sortItems(items []json.RawMessage, fields []string) {
+	sort.SliceStable(items, func(i, j int) bool {
+		for _, field := range fields {
+			desc := strings.HasPrefix(field, "-")
+			field = strings.TrimPrefix(field, "-")
+			if c := compareResults(jj.GetBytes(items[i], field), jj.GetBytes(items[j], field)); c != 0 {
+				return c < 0 != desc
+			}
+		}
+		return false
+	})
+}
+
+func compareResults(a, b jj.Result) int {
+	if a.Type == jj.Number && b.Type == jj.Number {
+		switch {
+		case a.Float() < b.Float():
+			return -1
+		case a.Float() > b.Float():
+			return 1
+		default:
+			return 0
+		}
+	}
+	return strings.Compare(a.String(), b.String())
+}
+
+func (r *Resource) create(c *gin.Context, s *ResourceState) error {
+	item, ok := readJSONObject(c)
+	if !ok {
+		return nil
+	}
+
+	idField := r.idField()
+	if v := jj.GetBytes(item, idField); v.Exists() {
+		if r.find(s, v.String()) >= 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "item " + v.String() + " exists"})
+			return nil
+		}
+	} else {
+		var err error
+		if item, err = jj.SetBytes(item, idField, r.nextID(s)); err != nil {
+			return err
+		}
+	}
+
+	s.Items = append(s.Items, item)
+	c.Header("Location", path.Join(c.Request.URL.Path, jj.GetBytes(item, idField).String()))
+	c.Data(http.StatusCreated, "application/json; charset=utf-8", item)
+	return r.save(s)
+}
+
+// nextID returns the max+1 of the ids when they are all numeric, or a uuid.
+func (r *Resource) nextID(s *ResourceState) interface{} {
+	maxID := int64(0)
+	for _, item := range s.Items {
+		v := jj.GetBytes(item, r.idField())
+		if v.Type != jj.Number {
+			return uuid.NewString()
+		}
+		maxID = max(maxID, v.Int())
+	}
+	return maxID + 1
+}
+
+func (r *Resource) update(c *gin.Context, s *ResourceState, i int, id string) error {
+	body, ok := readJSONObject(c)
+	if !ok {
+		return nil
+	}
+
+	idField := r.idField()
+	item := body
+	if c.Request.Method == http.MethodPatch {
+		var err error
+		if item, err = mergePatch(s.Items[i], body); err != nil {
+			return err
+		}
+	}
+
+	if v := jj.GetBytes(item, idField); !v.Exists() {
+		var err error
+		if item, err = jj.SetRawBytes(item, idField, []byte(jj.GetBytes(s.Items[i], idField).Raw)); err != nil {
+			return err
+		}
+	} else if v.String() != id {
+		c.JSON(http.StatusConflict, gin.H{"error": "id " + v.String() + " differs from " + id})
+		return nil
+	}
+
+	s.Items[i] = item
+	c.Data(http.StatusOK, "application/json; charset=utf-8", item)
+	return r.save(s)
+}
+
+// readJSONObject reads the JSON object of the request body, it responds 400 and returns false when it is not.
+func readJSONObject(c *gin.Context) (json.RawMessage, bool) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err == nil && jj.GetBytes(body, "@this").IsObject() {
+		var compact bytes.Buffer
+		if err = json.Compact(&compact, body); err == nil {
+			return compact.Bytes(), true
+		}
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{"error": "JSON object body required"})
+	return nil, false
+}
+
+// mergePatch applies the JSON merge-patch (RFC 7386) to the item.
+func mergePatch(item, patch json.RawMessage) (json.RawMessage, error) {
+	var target, p interface{}
+	if err := unmarshalUseNumber(item, &target); err != nil {
+		return nil, err
+	}
+	if err := unmarshalUseNumber(patch, &p); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(mergeValue(target, p))
+}
+
+// unmarshalUseNumber unmarshals the data with the numbers kept as they are, like the big int ids.
+func unmarshalUseNumber(data []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+func mergeValue(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergeValue(t[k], v)
+		}
+	}
+	return t
+}
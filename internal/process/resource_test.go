@@ -0,0 +1,129 @@
+package process
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatch(t *testing.T) {
+	for _, c := range []struct {
+		item, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`{"a":"foo"}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		{`{"id":12345678901234567890}`, `{"n":1}`, `{"id":12345678901234567890,"n":1}`},
+	} {
+		got, err := mergePatch(json.RawMessage(c.item), json.RawMessage(c.patch))
+		assert.Nil(t, err)
+		assert.JSONEq(t, c.want, string(got), "%s + %s", c.item, c.patch)
+	}
+}
+
+// serveResource serves the request by the resource endpoint /users, the id is the item path param if any.
+func serveResource(t *testing.T, r *Resource, method, target, id, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, strings.NewReader(body))
+	if id != "" {
+		c.Params = gin.Params{{Key: resourceIDParam, Value: id}}
+	}
+	assert.Nil(t, r.HlHandle(c, &APIDataModel{Endpoint: "/users"}, nil))
+	c.Writer.WriteHeaderNow()
+	return w
+}
+
+func TestResourceList(t *testing.T) {
+	ResetResources("", "/users")
+	r := &Resource{Data: json.RawMessage(`[
+		{"id": 1, "name": "a", "age": 30},
+		{"id": 2, "name": "b", "age": 20},
+		{"id": 3, "name": "c", "age": 20},
+		{"id": 4, "name": "d", "age": 10},
+		{"id": 5, "name": "e", "age": 30}
+	]`)}
+
+	for _, c := range []struct {
+		query, ids, total string
+	}{
+		{"", "[1,2,3,4,5]", "5"},
+		{"?_sort=age", "[4,2,3,1,5]", "5"},
+		{"?_sort=-age,name", "[1,5,2,3,4]", "5"},
+		{"?_sort=-age,-name", "[5,1,3,2,4]", "5"},
+		{"?_page=1&_limit=2", "[1,2]", "5"},
+		{"?_page=3&_limit=2", "[5]", "5"},
+		{"?_page=4&_limit=2", "[]", "5"},
+		{"?_limit=3", "[1,2,3]", "5"},
+		{"?_page=1", "[1,2,3,4,5]", "5"}, // default limit 10
+		{"?age=20&_sort=-id", "[3,2]", "2"},
+		{"?age=20&age=10", "[2,3,4]", "3"},
+		{"?_sort=age&_page=2&_limit=2", "[3,1]", "5"},
+	} {
+		w := serveResource(t, r, http.MethodGet, "/users"+c.query, "", "")
+		assert.Equal(t, http.StatusOK, w.Code, c.query)
+		assert.Equal(t, c.total, w.Header().Get("X-Total-Count"), c.query)
+
+		var items []struct{ ID int }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &items), c.query)
+		ids := make([]int, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		got, _ := json.Marshal(ids)
+		assert.Equal(t, c.ids, string(got), c.query)
+	}
+}
+
+func TestResourceStatuses(t *testing.T) {
+	ResetResources("", "/users")
+	r := &Resource{Data: json.RawMessage(`[{"id": 1, "name": "a"}, {"id": 2, "name": "b"}]`)}
+
+	for _, c := range []struct {
+		name, method, id, body string
+		status                 int
+		want                   string
+	}{
+		{"create", http.MethodPost, "", `{"name":"c"}`, http.StatusCreated, `{"name":"c","id":3}`},
+		{"create with id", http.MethodPost, "", `{"id":10,"name":"d"}`, http.StatusCreated, `{"id":10,"name":"d"}`},
+		{"create existing", http.MethodPost, "", `{"id":1,"name":"x"}`, http.StatusConflict, ""},
+		{"create not object", http.MethodPost, "", `[1]`, http.StatusBadRequest, ""},
+		{"get", http.MethodGet, "1", "", http.StatusOK, `{"id":1,"name":"a"}`},
+		{"get missing", http.MethodGet, "99", "", http.StatusNotFound, ""},
+		{"put", http.MethodPut, "2", `{"name":"bb"}`, http.StatusOK, `{"name":"bb","id":2}`},
+		{"put other id", http.MethodPut, "2", `{"id":3,"name":"bb"}`, http.StatusConflict, ""},
+		{"put missing", http.MethodPut, "99", `{"name":"x"}`, http.StatusNotFound, ""},
+		{"patch", http.MethodPatch, "1", `{"age":18,"name":null}`, http.StatusOK, `{"id":1,"age":18}`},
+		{"patch id", http.MethodPatch, "1", `{"id":5}`, http.StatusConflict, ""},
+		{"delete", http.MethodDelete, "10", "", http.StatusNoContent, ""},
+		{"delete missing", http.MethodDelete, "10", "", http.StatusNotFound, ""},
+		{"collection put", http.MethodPut, "", `{}`, http.StatusMethodNotAllowed, ""},
+	} {
+		target := "/users"
+		if c.id != "" {
+			target += "/" + c.id
+		}
+		w := serveResource(t, r, c.method, target, c.id, c.body)
+		assert.Equal(t, c.status, w.Code, c.name)
+		if c.want != "" {
+			assert.JSONEq(t, c.want, w.Body.String(), c.name)
+		}
+	}
+
+	w := serveResource(t, r, http.MethodGet, "/users", "", "")
+	assert.Equal(t, "3", w.Header().Get("X-Total-Count"))
+}
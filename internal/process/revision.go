@@ -0,0 +1,32 @@
+package process
+
+// EndpointRevision is a previous revision of an endpoint, kept when the endpoint is saved or deleted.
+type EndpointRevision struct {
+	Endpoint    string `json:"endpoint"`
+	Methods     string `json:"methods"`
+	MimeType    string `json:"mimeType"`
+	Filename    string `json:"filename"`
+	Body        string `json:"body"`
+	Author      string `json:"author"`
+	UpdateTime  string `json:"updateTime"`
+	RevisedTime string `json:"revisedTime"`
+	FileContent []byte `json:"fileContent,omitempty"`
+	EndpointID  uint64 `json:"endpointId" storm:"index"`
+	ID          uint64 `json:"id" storm:"id,increment"`
+}
+
+// CreateEndpointRevision creates the revision of the endpoint, revised at the time.
+func CreateEndpointRevision(ep Endpoint, revisedTime string) EndpointRevision {
+	return EndpointRevision{
+		Endpoint:    ep.Endpoint,
+		Methods:     ep.Methods,
+		MimeType:    ep.MimeType,
+		Filename:    ep.Filename,
+		Body:        ep.Body,
+		Author:      ep.Author,
+		UpdateTime:  ep.UpdateTime,
+		RevisedTime: revisedTime,
+		FileContent: ep.FileContent,
+		EndpointID:  ep.ID,
+	}
+}
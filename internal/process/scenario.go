@@ -0,0 +1,125 @@
+package process
+
+import (
+	"sync"
+)
+
+/*
+"_dynamic": [
+  {
+    "scenario": "login",
+    "requiredState": "Started",
+    "newState": "LoggedIn",
+    "status": 401,
+    "response": {"error": "unauthorized"}
+  },
+  {
+    "scenario": "login",
+    "requiredState": "LoggedIn",
+    "response": {"token": "@uuid"}
+  }
+]
+*/
+
+// ScenarioStarted is the initial state of every scenario.
+const ScenarioStarted = "Started"
+
+// ScenarioStates keeps the current states of the named scenarios, keyed by the workspace and the name,
+// so the scenarios named the same in the workspaces are apart.
+type ScenarioStates struct {
+	states map[string]map[string]string
+	lock   sync.Mutex
+}
+
+// Scenarios is the server side states of the scenarios used in the `_dynamic` rules.
+var Scenarios = &ScenarioStates{states: map[string]map[string]string{}}
+
+// Register registers the scenario of the workspace with its initial state if it is unknown yet.
+func (s *ScenarioStates) Register(workspace, name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.states[workspace][name]; !ok {
+		s.set(workspace, name, ScenarioStarted)
+	}
+}
+
+func (s *ScenarioStates) set(workspace, name, state string) {
+	states, ok := s.states[workspace]
+	if !ok {
+		states = map[string]string{}
+		s.states[workspace] = states
+	}
+
+	states[name] = state
+}
+
+// Get returns the current state of the scenario of the workspace.
+func (s *ScenarioStates) Get(workspace, name string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if state, ok := s.states[workspace][name]; ok {
+		return state
+	}
+
+	return ScenarioStarted
+}
+
+// All returns a copy of all scenarios of the workspace and their states.
+func (s *ScenarioStates) All(workspace string) map[string]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	m := make(map[string]string, len(s.states[workspace]))
+	for k, v := range s.states[workspace] {
+		m[k] = v
+	}
+
+	return m
+}
+
+// Transit moves the scenario of the workspace to the newState if its current state is the requiredState.
+// Empty requiredState matches any state, and empty newState keeps the current state.
+func (s *ScenarioStates) Transit(workspace, name, requiredState, newState string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	state, ok := s.states[workspace][name]
+	if !ok {
+		state = ScenarioStarted
+	}
+
+	if requiredState != "" && requiredState != state {
+		return false
+	}
+
+	if newState != "" {
+		s.set(workspace, name, newState)
+	} else if !ok {
+		s.set(workspace, name, state)
+	}
+
+	return true
+}
+
+// Reset resets the scenario of the workspace to its initial state, or all the scenarios of the workspace when name is empty.
+func (s *ScenarioStates) Reset(workspace, name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	states := s.states[workspace]
+	for k := range states {
+		if name == "" || k == name {
+			states[k] = ScenarioStarted
+		}
+	}
+}
+
+// Drop forgets all the scenarios of the workspace, like when the workspace is deleted.
+func (s *ScenarioStates) Drop(workspace string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.states, workspace)
+}
@@ -0,0 +1,59 @@
+package process
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScenarioStates(t *testing.T) {
+	s := &ScenarioStates{states: map[string]map[string]string{}}
+	s.Register("a", "login")
+	s.Register("b", "login")
+	assert.Equal(t, map[string]string{"login": ScenarioStarted}, s.All("a"))
+	assert.Empty(t, s.All("c"))
+
+	assert.True(t, s.Transit("a", "login", ScenarioStarted, "LoggedIn"))
+	assert.Equal(t, "LoggedIn", s.Get("a", "login"))
+	assert.Equal(t, ScenarioStarted, s.Get("b", "login"), "the same name in another workspace")
+	assert.False(t, s.Transit("b", "login", "LoggedIn", ""))
+
+	assert.True(t, s.Transit("b", "cart", "", ""), "any state")
+	assert.Equal(t, map[string]string{"login": ScenarioStarted, "cart": ScenarioStarted}, s.All("b"))
+
+	s.Transit("b", "login", "", "LoggedIn")
+	s.Reset("a", "")
+	assert.Equal(t, ScenarioStarted, s.Get("a", "login"))
+	assert.Equal(t, "LoggedIn", s.Get("b", "login"), "reset in the workspace only")
+
+	s.Drop("b")
+	assert.Empty(t, s.All("b"))
+	assert.Equal(t, map[string]string{"login": ScenarioStarted}, s.All("a"))
+}
+
+func TestDynamicScenarioByWorkspace(t *testing.T) {
+	t.Cleanup(func() { Scenarios.Drop("wsA"); Scenarios.Drop("wsB") })
+
+	const config = `[
+		{"scenario": "dynLogin", "requiredState": "Started", "newState": "LoggedIn", "status": 401, "response": "unauthorized"},
+		{"scenario": "dynLogin", "condition": "scenario_dynLogin == 'LoggedIn'", "response": "token"}
+	]`
+	rulesA := createDynamics("wsA", `{}`, []byte(config))
+	rulesB := createDynamics("wsB", `{}`, []byte(config))
+	assert.Equal(t, map[string]string{"dynLogin": ScenarioStarted}, Scenarios.All("wsA"), "registered in the workspace")
+
+	r, _ := matchTestRequest(t, rulesA, "/", `{}`)
+	assert.Equal(t, `"unauthorized"`, r)
+	r, _ = matchTestRequest(t, rulesA, "/", `{}`)
+	assert.Equal(t, `"token"`, r)
+
+	r, _ = matchTestRequest(t, rulesB, "/", `{}`)
+	assert.Equal(t, `"unauthorized"`, r, "not moved by the scenario of the other workspace")
+	assert.Equal(t, "LoggedIn", Scenarios.Get("wsB", "dynLogin"))
+
+	Scenarios.Reset("wsA", "dynLogin")
+	r, _ = matchTestRequest(t, rulesA, "/", `{}`)
+	assert.Equal(t, `"unauthorized"`, r)
+	r, _ = matchTestRequest(t, rulesB, "/", `{}`)
+	assert.Equal(t, `"token"`, r)
+}
@@ -0,0 +1,217 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bingoohuang/httplive/pkg/countable"
+	"github.com/bingoohuang/httplive/pkg/util"
+	"github.com/bingoohuang/jj"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+"_sequence": [
+  {"status": 202, "response": {"state": "pending"}},
+  {"status": 202, "response": {"state": "running"}, "headers": {"Retry-After": "1"}},
+  {"response": {"state": "done"}}
+],
+"_sequenceMode": "stick-last",       // cycle (default), stick-last or once-then-404
+"_sequenceScope": "header:X-Client"  // global (default), ip, or header:<name> to count by the header value
+*/
+
+const (
+	// SequenceCycle responds the steps in turn, from the first again after the last one.
+	SequenceCycle = "cycle"
+	// SequenceStickLast responds the last step after all the steps are responded.
+	SequenceStickLast = "stick-last"
+	// SequenceOnceThen404 responds 404 after all the steps are responded.
+	SequenceOnceThen404 = "once-then-404"
+)
+
+// Sequence responds its steps in turn, counted separately for each client in its scope.
+type Sequence struct {
+	Steps []SequenceStep
+	Mode  string
+	Scope string
+}
+
+// SequenceStep is a response in the sequence, mockbin endpoints can use payload instead of response.
+type SequenceStep struct {
+	Headers  map[string]string `json:"headers"`
+	Response json.RawMessage   `json:"response"`
+	Payload  json.RawMessage   `json:"payload"`
+	Status   int               `json:"status"`
+}
+
+// Body returns the response of the step.
+func (s SequenceStep) Body() json.RawMessage {
+	if len(s.Response) > 0 {
+		return s.Response
+	}
+	return s.Payload
+}
+
+// SequenceCounter counts the responded steps of the sequences by the sequence name and the scope value.
+var SequenceCounter countable.Counter
+
+// SequenceIdle is the idle time after which the counters of the ip and header scopes are expired,
+// then the client starts from the first step again. The clients may be countless, so their counters
+// are not kept forever like the ones of the global scope.
+var SequenceIdle = 30 * time.Minute
+
+var (
+	sequenceTouched   sync.Map // the scoped counter key to its last touched time in unix nano, *int64
+	sequenceLastSweep atomic.Int64
+)
+
+// CreateSequence creates the sequence from the `_sequence` block of the endpoint body, nil when it is absent.
+func CreateSequence(endpoint, body string) (*Sequence, error) {
+	seq := jj.Get(body, "_sequence")
+	if !seq.Exists() {
+		return nil, nil
+	}
+	if seq.Type != jj.JSON || !seq.IsArray() {
+		return nil, fmt.Errorf("_sequence of %s: array expected", endpoint)
+	}
+
+	s := &Sequence{
+		Mode:  strings.ToLower(jj.Get(body, "_sequenceMode").String()),
+		Scope: jj.Get(body, "_sequenceScope").String(),
+	}
+	if err := json.Unmarshal([]byte(seq.Raw), &s.Steps); err != nil {
+		return nil, fmt.Errorf("_sequence of %s: %w", endpoint, err)
+	}
+	if len(s.Steps) == 0 {
+		return nil, fmt.Errorf("_sequence of %s: no steps", endpoint)
+	}
+
+	switch s.Mode {
+	case "":
+		s.Mode = SequenceCycle
+	case SequenceCycle, SequenceStickLast, SequenceOnceThen404:
+	default:
+		return nil, fmt.Errorf("_sequenceMode %q of %s, cycle, stick-last or once-then-404 expected", s.Mode, endpoint)
+	}
+
+	return s, nil
+}
+
+// SequenceName returns the name of the sequence of the endpoint, to count and reset it.
+func SequenceName(workspace, method, endpoint string) string {
+	return workspace + " " + method + " " + endpoint
+}
+
+// Next counts the request and returns the step to respond, false when the sequence is exhausted in the once-then-404 mode.
+func (s *Sequence) Next(c *gin.Context, ep APIDataModel) (SequenceStep, bool) {
+	workspace := ""
+	if rr, ok := c.Request.Context().Value(RouterResultKey).(*RouterResult); ok {
+		workspace = rr.Workspace
+	}
+
+	scope := s.scopeValue(c)
+	key := SequenceName(workspace, ep.Method, ep.Endpoint) + "|" + scope
+	i := int(SequenceCounter.Add(key, 1) - 1)
+	if scope != "" {
+		touchSequence(key, time.Now())
+	}
+
+	switch n := len(s.Steps); {
+	case i < n:
+		return s.Steps[i], true
+	case s.Mode == SequenceStickLast:
+		return s.Steps[n-1], true
+	case s.Mode == SequenceOnceThen404:
+		return SequenceStep{}, false
+	default:
+		return s.Steps[i%n], true
+	}
+}
+
+func (s *Sequence) scopeValue(c *gin.Context) string {
+	switch {
+	case strings.EqualFold(s.Scope, "ip"):
+		return c.ClientIP()
+	case util.HasPrefix(s.Scope, "header:"):
+		return c.GetHeader(strings.TrimSpace(s.Scope[len("header:"):]))
+	default:
+		return ""
+	}
+}
+
+// touchSequence records the time the scoped counter is touched, and expires the idle counters once a minute.
+func touchSequence(key string, now time.Time) {
+	t := now.UnixNano()
+	if v, loaded := sequenceTouched.LoadOrStore(key, &t); loaded {
+		atomic.StoreInt64(v.(*int64), t)
+	}
+
+	last := sequenceLastSweep.Load()
+	if now.Sub(time.Unix(0, last)) >= time.Minute && sequenceLastSweep.CompareAndSwap(last, t) {
+		ExpireSequences(now.Add(-SequenceIdle))
+	}
+}
+
+// ExpireSequences deletes the counters of the ip and header scopes not touched since the time,
+// and returns the number of the counters deleted.
+func ExpireSequences(since time.Time) int {
+	n := 0
+	sequenceTouched.Range(func(key, value any) bool {
+		if atomic.LoadInt64(value.(*int64)) < since.UnixNano() {
+			sequenceTouched.Delete(key)
+			SequenceCounter.Delete(key)
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+// sequenceProcess responds by the next step of the sequence, false when the endpoint has no sequence.
+func sequenceProcess(c *gin.Context, ep APIDataModel) bool {
+	if ep.sequence == nil {
+		return false
+	}
+
+	step, ok := ep.sequence.Next(c, ep)
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return true
+	}
+
+	DynamicValue{Headers: step.Headers, Response: step.Body(), Status: step.Status}.responseDynamic(ep, c)
+	return true
+}
+
+// ResetSequences resets the counters of the sequence of the endpoint, of any method when method is empty,
+// and all the sequences when endpoint is empty. The sequence of an ANY endpoint is reset by any method.
+// It returns the number of the counters reset.
+func ResetSequences(workspace, method, endpoint string) int {
+	n := 0
+	SequenceCounter.Range(func(key string, _ int64) bool {
+		name, _, _ := strings.Cut(key, "|")
+		if parts := strings.SplitN(name, " ", 3); endpoint == "" || parts[0] == workspace &&
+			(method == "" || strings.EqualFold(parts[1], method) || parts[1] == "ANY") && parts[2] == endpoint {
+			SequenceCounter.Delete(key)
+			sequenceTouched.Delete(key)
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+// SequenceCounts returns the counts of the sequences, keyed by the sequence name and the scope value.
+func SequenceCounts() map[string]int64 {
+	counts := map[string]int64{}
+	SequenceCounter.Range(func(key string, value int64) bool {
+		counts[key] = value
+		return true
+	})
+	return counts
+}
@@ -0,0 +1,153 @@
+package process
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bingoohuang/jj"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const sequenceTestSteps = `"_sequence": [
+	{"status": 202, "response": {"state": "pending"}},
+	{"status": 202, "response": {"state": "running"}, "headers": {"Retry-After": "1"}},
+	{"response": {"state": "done"}}
+]`
+
+// prepareSequence creates the endpoint of the sequence, with the steps and the extra config.
+func prepareSequence(t *testing.T, method, endpoint, extra string) *APIDataModel {
+	t.Helper()
+	t.Cleanup(func() { ResetSequences("", "", "") })
+
+	config := `{` + sequenceTestSteps + extra + `}`
+	m := &APIDataModel{Endpoint: endpoint, Method: method, Body: RawMessage(config)}
+	assert.True(t, (&Endpoint{Endpoint: endpoint}).CreateDefault(m, config, nil))
+	assert.NotNil(t, m.sequence)
+	return m
+}
+
+// serveSequence serves the request in the workspace ws from the ip, with the X-Client header when client is not empty.
+func serveSequence(m *APIDataModel, method, ip, client string) string {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	r := httptest.NewRequest(method, m.Endpoint, nil)
+	c.Request = r.WithContext(context.WithValue(r.Context(), RouterResultKey, &RouterResult{Workspace: "ws"}))
+	c.Request.RemoteAddr = ip + ":12345"
+	if client != "" {
+		c.Request.Header.Set("X-Client", client)
+	}
+	m.ServeFn(c)
+	c.Writer.WriteHeaderNow()
+
+	if state := jj.Get(w.Body.String(), "state"); state.Exists() {
+		return state.String()
+	}
+	return w.Result().Status
+}
+
+func serveSequences(m *APIDataModel, n int, ip, client string) (states []string) {
+	for i := 0; i < n; i++ {
+		states = append(states, serveSequence(m, m.Method, ip, client))
+	}
+	return states
+}
+
+func TestSequenceModes(t *testing.T) {
+	m := prepareSequence(t, "GET", "/cycle", ``)
+	assert.Equal(t, []string{"pending", "running", "done", "pending", "running"}, serveSequences(m, 5, "1.1.1.1", ""))
+
+	m = prepareSequence(t, "GET", "/stick", `, "_sequenceMode": "Stick-Last"`)
+	assert.Equal(t, []string{"pending", "running", "done", "done", "done"}, serveSequences(m, 5, "1.1.1.1", ""))
+
+	m = prepareSequence(t, "GET", "/once", `, "_sequenceMode": "once-then-404"`)
+	assert.Equal(t, []string{"pending", "running", "done", "404 Not Found", "404 Not Found"}, serveSequences(m, 5, "1.1.1.1", ""))
+
+	m = prepareSequence(t, "GET", "/headers", ``)
+	for _, expected := range []struct {
+		status     int
+		retryAfter string
+	}{{202, ""}, {202, "1"}, {200, ""}} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/headers", nil)
+		m.ServeFn(c)
+		c.Writer.WriteHeaderNow()
+		assert.Equal(t, expected.status, w.Code)
+		assert.Equal(t, expected.retryAfter, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestSequenceScopes(t *testing.T) {
+	m := prepareSequence(t, "GET", "/global", ``)
+	assert.Equal(t, []string{"pending", "running"}, []string{
+		serveSequence(m, "GET", "1.1.1.1", "a"), serveSequence(m, "GET", "2.2.2.2", "b")})
+
+	m = prepareSequence(t, "GET", "/ip", `, "_sequenceScope": "ip"`)
+	assert.Equal(t, []string{"pending", "pending", "running"}, []string{
+		serveSequence(m, "GET", "1.1.1.1", ""), serveSequence(m, "GET", "2.2.2.2", ""), serveSequence(m, "GET", "1.1.1.1", "")})
+
+	m = prepareSequence(t, "GET", "/header", `, "_sequenceScope": "header: X-Client"`)
+	assert.Equal(t, []string{"pending", "pending", "running"}, []string{
+		serveSequence(m, "GET", "1.1.1.1", "a"), serveSequence(m, "GET", "1.1.1.1", "b"), serveSequence(m, "GET", "2.2.2.2", "a")})
+
+	counts := SequenceCounts()
+	assert.Equal(t, int64(2), counts["ws GET /header|a"])
+	assert.Equal(t, int64(1), counts["ws GET /header|b"])
+	assert.Equal(t, int64(2), counts["ws GET /ip|1.1.1.1"])
+	assert.Equal(t, int64(2), counts["ws GET /global|"])
+}
+
+func TestExpireSequences(t *testing.T) {
+	m := prepareSequence(t, "GET", "/global", ``)
+	serveSequences(m, 2, "1.1.1.1", "")
+	m = prepareSequence(t, "GET", "/header", `, "_sequenceScope": "header:X-Client"`)
+	for _, client := range []string{"a", "b", "c"} {
+		serveSequence(m, "GET", "1.1.1.1", client)
+	}
+
+	assert.Equal(t, 0, ExpireSequences(time.Now().Add(-time.Minute)), "not idle yet")
+	assert.Equal(t, 3, ExpireSequences(time.Now().Add(time.Second)), "the header scoped counters only")
+	assert.Equal(t, map[string]int64{"ws GET /global|": 2}, SequenceCounts())
+	assert.Equal(t, "pending", serveSequence(m, "GET", "1.1.1.1", "a"), "starts again")
+}
+
+func TestResetSequences(t *testing.T) {
+	get := prepareSequence(t, "GET", "/poll", ``)
+	post := prepareSequence(t, "POST", "/poll", ``)
+	anyMethod := prepareSequence(t, "ANY", "/any", ``)
+	serveSequence(get, "GET", "1.1.1.1", "")
+	serveSequence(post, "POST", "1.1.1.1", "")
+	serveSequence(anyMethod, "PUT", "1.1.1.1", "")
+
+	assert.Equal(t, 0, ResetSequences("other", "", "/poll"), "of the workspace")
+	assert.Equal(t, 1, ResetSequences("ws", "get", "/poll"))
+	assert.Equal(t, "pending", serveSequence(get, "GET", "1.1.1.1", ""))
+	assert.Equal(t, "running", serveSequence(post, "POST", "1.1.1.1", ""))
+
+	assert.Equal(t, 1, ResetSequences("ws", "GET", "/any"), "ANY endpoint by a method")
+	assert.Equal(t, "pending", serveSequence(anyMethod, "DELETE", "1.1.1.1", ""))
+
+	assert.Equal(t, 2, ResetSequences("ws", "", "/poll"), "of any method")
+	assert.Equal(t, 1, ResetSequences("", "", ""), "all")
+	assert.Empty(t, SequenceCounts())
+}
+
+func TestCreateSequenceErrors(t *testing.T) {
+	for _, body := range []string{
+		`{"_sequence": {"status": 202}}`,
+		`{"_sequence": []}`,
+		`{"_sequence": [{"status": "202"}]}`,
+		`{"_sequence": [{"status": 202}], "_sequenceMode": "forever"}`,
+	} {
+		s, err := CreateSequence("/poll", body)
+		assert.Nil(t, s, body)
+		assert.NotNil(t, err, body)
+	}
+
+	s, err := CreateSequence("/poll", `{"response": {}}`)
+	assert.Nil(t, s, "no sequence")
+	assert.Nil(t, err)
+}
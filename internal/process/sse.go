@@ -0,0 +1,222 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bingoohuang/gg/pkg/thinktime"
+	"github.com/bingoohuang/httplive/pkg/eval"
+	"github.com/bingoohuang/jj"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+"_hl": "sse",
+"events": [
+  {"id": "1", "event": "tick", "data": {"n": "@random_int(1-100)", "at": "@now"}, "delay": "1s"},
+  {"event": "status", "data": "@姓名 joined", "delay": "500ms-2s"} // the id is the sequence number when absent
+],
+"loop": true,     // optional, streams the events again and again
+"rounds": 3,      // optional, the rounds of the loop, 0 for endless
+"retry": 3000,    // optional, the reconnection time in milliseconds told to the client
+"final": {"event": "end", "data": "bye"} // optional, sent before the connection ends
+
+The data of each event is generated by jj.Gen when it is sent, and rendered by the request templates.
+The reconnecting client with the Last-Event-ID header resumes from the event after it.
+*/
+
+// HlSSE is the _hl of the Server-Sent Events mock endpoints.
+const HlSSE = "sse"
+
+func init() {
+	registerHlHandlers(HlSSE, func() HlHandler { return &SSE{} })
+}
+
+// SSE streams the configured events as Server-Sent Events.
+type SSE struct {
+	Events []SSEEvent `json:"events"`
+	Loop   bool       `json:"loop"`
+	Rounds int        `json:"rounds"`
+	Retry  int        `json:"retry"`
+	Final  *SSEEvent  `json:"final"`
+}
+
+// SSEEvent is an event of the stream.
+type SSEEvent struct {
+	ID    string          `json:"id"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+	Delay string          `json:"delay"`
+
+	delay *thinktime.ThinkTime
+}
+
+// AfterUnmashal parses the delays of the events.
+func (s *SSE) AfterUnmashal() {
+	for i := range s.Events {
+		s.Events[i].parseDelay()
+	}
+	if s.Final != nil {
+		s.Final.parseDelay()
+	}
+}
+
+func (e *SSEEvent) parseDelay() {
+	if e.Delay == "" {
+		return
+	}
+
+	var err error
+	if e.delay, err = thinktime.ParseThinkTime(e.Delay); err != nil {
+		log.Printf("E! delay %s of sse event: %v", e.Delay, err)
+	}
+}
+
+// HlHandle streams the events until they are all sent or the client goes away.
+func (s *SSE) HlHandle(c *gin.Context, _ *APIDataModel, _ func(name string) string) error {
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.String(http.StatusInternalServerError, "streaming unsupported")
+		return nil
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // no buffering by nginx
+	c.Status(http.StatusOK)
+
+	if s.Retry > 0 {
+		fmt.Fprintf(c.Writer, "retry: %d\n\n", s.Retry)
+	}
+	flusher.Flush()
+
+	t := NewRequestTemplate(c)
+	ctx := c.Request.Context()
+	for seq := s.resume(c.GetHeader("Last-Event-ID")); s.hasNext(seq); seq++ {
+		e := s.Events[seq%len(s.Events)]
+		if !e.wait(ctx.Done()) {
+			return nil
+		}
+
+		id := e.ID
+		if id == "" {
+			id = strconv.Itoa(seq + 1)
+		}
+		s.write(c, t, id, e)
+		flusher.Flush()
+	}
+
+	if s.Final != nil && s.Final.wait(ctx.Done()) {
+		s.write(c, t, s.Final.ID, *s.Final)
+		flusher.Flush()
+	}
+
+	return nil
+}
+
+// resume returns the sequence of the event after the last event id, 0 to start from the beginning.
+func (s *SSE) resume(lastEventID string) int {
+	if lastEventID == "" || len(s.Events) == 0 {
+		return 0
+	}
+
+	for i, e := range s.Events {
+		if e.ID == lastEventID {
+			return i + 1
+		}
+	}
+
+	// the generated ids are the sequence numbers, which continue across the rounds.
+	if n, err := strconv.Atoi(lastEventID); err == nil && n > 0 {
+		return n
+	}
+
+	return 0
+}
+
+func (s *SSE) hasNext(seq int) bool {
+	switch {
+	case len(s.Events) == 0:
+		return false
+	case !s.Loop:
+		return seq < len(s.Events)
+	case s.Rounds > 0:
+		return seq < len(s.Events)*s.Rounds
+	default:
+		return true
+	}
+}
+
+// wait waits for the delay of the event, false when the client goes away meanwhile.
+func (e SSEEvent) wait(done <-chan struct{}) bool {
+	if e.delay == nil {
+		select {
+		case <-done:
+			return false
+		default:
+			return true
+		}
+	}
+
+	timer := time.NewTimer(e.delay.Think(false))
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (s *SSE) write(c *gin.Context, t *RequestTemplate, id string, e SSEEvent) {
+	var b strings.Builder
+	if id != "" {
+		b.WriteString("id: " + id + "\n")
+	}
+	if e.Event != "" {
+		b.WriteString("event: " + e.Event + "\n")
+	}
+	for _, line := range strings.Split(genMessage(e.Data, t), "\n") {
+		b.WriteString("data: " + strings.TrimSuffix(line, "\r") + "\n")
+	}
+	b.WriteString("\n")
+
+	_, _ = c.Writer.WriteString(b.String())
+}
+
+// genMessage generates the message of the events or the websocket mocks by jj.Gen and renders the templates,
+// the strings are sent as they are, and the others as JSON.
+func genMessage(data json.RawMessage, t *RequestTemplate) string {
+	if len(data) == 0 {
+		return ""
+	}
+
+	if r := jj.ParseBytes(data); r.Type == jj.String {
+		// jj.Gen keeps the escapes of the strings, so the lines are generated one by one.
+		lines := strings.Split(r.String(), "\n")
+		for i, line := range lines {
+			if strings.Contains(line, "@") {
+				lines[i] = jj.Parse(jjGen(jsonQuote(line))).String()
+			}
+		}
+		return t.Render(strings.Join(lines, "\n"))
+	}
+
+	return t.RenderBody(jjGen(string(data)))
+}
+
+func jjGen(data string) string {
+	gen, err := eval.JjGen(data)
+	if err != nil {
+		log.Printf("E! JjGen %s: %v", data, err)
+		return data
+	}
+	return gen
+}
@@ -0,0 +1,98 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bingoohuang/httplive/pkg/util"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func prepareSSE(t *testing.T, config string) *SSE {
+	t.Helper()
+
+	s := &SSE{}
+	assert.Nil(t, json.Unmarshal([]byte(config), s))
+	s.AfterUnmashal()
+	return s
+}
+
+// serveSSE streams the events, with the Last-Event-ID header when lastEventID is not empty,
+// and returns the blocks of the stream, like "id: 1\ndata: a".
+func serveSSE(t *testing.T, s *SSE, lastEventID string) []string {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/events?name=bingoo", nil)
+	if lastEventID != "" {
+		c.Request.Header.Set("Last-Event-ID", lastEventID)
+	}
+	assert.Nil(t, s.HlHandle(c, nil, nil))
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+
+	return strings.Split(strings.TrimSuffix(w.Body.String(), "\n\n"), "\n\n")
+}
+
+const sseTestEvents = `"events": [
+	{"id": "a", "event": "tick", "data": "hello {{request.query.name}}"},
+	{"data": {"n": 1}},
+	{"id": "c", "data": "line1\nline2"}
+]`
+
+func TestSSE(t *testing.T) {
+	s := prepareSSE(t, `{`+sseTestEvents+`}`)
+	assert.Equal(t, []string{
+		"id: a\nevent: tick\ndata: hello bingoo",
+		"id: 2\ndata: {\"n\":1}",
+		"id: c\ndata: line1\ndata: line2",
+	}, serveSSE(t, s, ""))
+}
+
+func TestSSEResume(t *testing.T) {
+	s := prepareSSE(t, `{`+sseTestEvents+`}`)
+	assert.Equal(t, []string{"id: 2\ndata: {\"n\":1}", "id: c\ndata: line1\ndata: line2"}, serveSSE(t, s, "a"))
+	assert.Equal(t, []string{"id: c\ndata: line1\ndata: line2"}, serveSSE(t, s, "2"), "by the generated id")
+	assert.Equal(t, []string{""}, serveSSE(t, s, "c"), "all sent")
+	assert.Len(t, serveSSE(t, s, "unknown"), 3, "from the beginning")
+
+	s = prepareSSE(t, `{"events": [{"data": "x"}, {"data": "y"}], "loop": true, "rounds": 2}`)
+	assert.Equal(t, []string{"id: 4\ndata: y"}, serveSSE(t, s, "3"), "the generated ids continue across the rounds")
+}
+
+func TestSSELoop(t *testing.T) {
+	s := prepareSSE(t, `{"events": [{"data": "x"}, {"id": "y", "data": "y"}], "loop": true, "rounds": 2}`)
+	assert.Equal(t, []string{"id: 1\ndata: x", "id: y\ndata: y", "id: 3\ndata: x", "id: y\ndata: y"}, serveSSE(t, s, ""))
+
+	s = prepareSSE(t, `{"events": [{"data": "x"}, {"data": "y"}], "loop": false, "rounds": 2}`)
+	assert.Len(t, serveSSE(t, s, ""), 2, "rounds without loop")
+}
+
+func TestSSERetryAndFinal(t *testing.T) {
+	s := prepareSSE(t, `{"events": [{"data": "x"}], "retry": 3000, "final": {"event": "end", "data": "bye", "delay": "1ms"}}`)
+	assert.Equal(t, []string{"retry: 3000", "id: 1\ndata: x", "event: end\ndata: bye"}, serveSSE(t, s, ""))
+}
+
+// TestSSEEndless streams the endless loop through the copy writer of the journal, until the client goes away.
+func TestSSEEndless(t *testing.T) {
+	s := prepareSSE(t, `{"events": [{"data": {"n": 1}, "delay": "1ms"}], "loop": true, "final": {"data": "bye"}}`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/events", strings.NewReader(`{}`)).WithContext(ctx)
+	c.Request.Header.Set("Content-Type", "application/json")
+	cw := util.NewGinCopyWriter(c.Writer, c)
+	c.Writer = cw
+
+	assert.Nil(t, s.HlHandle(c, nil, nil))
+	assert.Greater(t, strings.Count(w.Body.String(), "data: {\"n\":1}"), 10)
+	assert.NotContains(t, w.Body.String(), "bye", "no final event after the client goes away")
+	assert.Zero(t, cw.Buf.Len(), "the stream is not copied")
+}
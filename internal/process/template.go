@@ -0,0 +1,191 @@
+package process
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bingoohuang/httplive/pkg/eval"
+	"github.com/bingoohuang/jj"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+The response bodies and headers can reference the request by the templates:
+  {{request.path.id}}         the router param like /user/:id
+  {{request.query.page}}      the query param
+  {{request.header.X-Trace}}  the request header
+  {{request.body.user.name}}  the jj path of the JSON request body, {{request.body}} for the whole body
+  {{request.method}}, {{request.path}}, {{request.url}}
+  {{now "yyyy-MM-dd"}}        the current time in the format, default yyyy-MM-dd HH:mm:ss.SSS
+A JSON string of only a body template like "{{request.body.user}}" is replaced by the raw JSON value.
+The templates not recognized are kept as they are.
+*/
+
+var (
+	templatePattern = regexp.MustCompile(`\{\{\s*(.+?)\s*\}\}`)
+	// bodyTemplatePattern matches a JSON string of only a body template first, then any template,
+	// to render the body in one pass, without the templates in the request values rendered again.
+	bodyTemplatePattern = regexp.MustCompile(`"\{\{\s*(request\.body(?:\.[^"]+?)?)\s*\}\}"|\{\{\s*(.+?)\s*\}\}`)
+)
+
+// RequestTemplate renders the templates in the response with the data of the request.
+type RequestTemplate struct {
+	c        *gin.Context
+	body     []byte
+	bodyRead bool
+
+	// the request data copied by detach, to render without c.
+	req    *http.Request
+	params gin.Params
+	query  url.Values
+}
+
+// NewRequestTemplate creates a RequestTemplate of the request.
+func NewRequestTemplate(c *gin.Context) *RequestTemplate {
+	return &RequestTemplate{c: c}
+}
+
+// detach copies the request data to render without the gin.Context, which caches the query lazily,
+// for the templates rendered concurrently, like the websocket messages. The request body is not read.
+func (t *RequestTemplate) detach() *RequestTemplate {
+	r := t.c.Request.Clone(t.c.Request.Context())
+	return &RequestTemplate{
+		req:      r,
+		params:   append(gin.Params(nil), t.c.Params...),
+		query:    r.URL.Query(),
+		bodyRead: true,
+	}
+}
+
+// withBody returns the copy of the detached template with the body, like the websocket message received.
+func (t *RequestTemplate) withBody(body []byte) *RequestTemplate {
+	c := *t
+	c.body = body
+	return &c
+}
+
+// Render renders the templates in the plain text, like a header value.
+func (t *RequestTemplate) Render(s string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+
+	return templatePattern.ReplaceAllStringFunc(s, func(m string) string {
+		if v, ok := t.value(templatePattern.FindStringSubmatch(m)[1]); ok {
+			return v.String()
+		}
+		return m
+	})
+}
+
+// RenderBody renders the templates in the response body, with the values escaped when the body is JSON.
+func (t *RequestTemplate) RenderBody(s string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	if !jj.Valid(s) {
+		return t.Render(s)
+	}
+
+	return bodyTemplatePattern.ReplaceAllStringFunc(s, func(m string) string {
+		sub := bodyTemplatePattern.FindStringSubmatch(m)
+		if sub[1] != "" {
+			v, ok := t.value(sub[1])
+			switch {
+			case !ok:
+				return m
+			case v.Type == jj.String || !v.Exists():
+				return jsonQuote(v.String())
+			default:
+				return v.Raw
+			}
+		}
+
+		// the expression is in a JSON string, like {{now \"yyyy-MM-dd\"}}.
+		expr := sub[2]
+		if uq, err := strconv.Unquote(`"` + expr + `"`); err == nil {
+			expr = uq
+		}
+		v, ok := t.value(expr)
+		if !ok {
+			return m
+		}
+		quoted := jsonQuote(v.String())
+		return quoted[1 : len(quoted)-1]
+	})
+}
+
+// value evaluates the expression of the template, false when it is not recognized.
+func (t *RequestTemplate) value(expr string) (jj.Result, bool) {
+	if fn, arg, _ := strings.Cut(expr, " "); fn == "now" {
+		dateFmt := "yyyy-MM-dd HH:mm:ss.SSS"
+		if arg = strings.TrimSpace(arg); arg != "" {
+			if uq, err := strconv.Unquote(arg); err == nil {
+				arg = uq
+			}
+			dateFmt = arg
+		}
+		return stringResult(time.Now().Format(eval.DateLayout(dateFmt))), true
+	}
+
+	part, key, _ := strings.Cut(expr, ".")
+	if part != "request" {
+		return jj.Result{}, false
+	}
+
+	r, params := t.req, t.params
+	if t.c != nil {
+		r, params = t.c.Request, t.c.Params
+	}
+	part, key, hasKey := strings.Cut(key, ".")
+	switch {
+	case part == "method" && !hasKey:
+		return stringResult(r.Method), true
+	case part == "url" && !hasKey:
+		return stringResult(r.URL.RequestURI()), true
+	case part == "path" && !hasKey:
+		return stringResult(r.URL.Path), true
+	case part == "path":
+		return stringResult(params.ByName(key)), true
+	case part == "query" && hasKey && t.c == nil:
+		return stringResult(t.query.Get(key)), true
+	case part == "query" && hasKey:
+		return stringResult(t.c.Query(key)), true
+	case part == "header" && hasKey:
+		return stringResult(r.Header.Get(key)), true
+	case part == "body" && !hasKey:
+		if body := t.readBody(); jj.ValidBytes(body) {
+			return jj.ParseBytes(body), true
+		}
+		return stringResult(string(t.body)), true
+	case part == "body":
+		return jj.GetBytes(t.readBody(), key), true
+	default:
+		return jj.Result{}, false
+	}
+}
+
+func (t *RequestTemplate) readBody() []byte {
+	if !t.bodyRead && t.c != nil && t.c.Request.Body != nil {
+		t.body, _ = io.ReadAll(t.c.Request.Body)
+		t.c.Request.Body = io.NopCloser(bytes.NewBuffer(t.body))
+	}
+	t.bodyRead = true
+	return t.body
+}
+
+func stringResult(s string) jj.Result {
+	return jj.Result{Type: jj.String, Str: s, Raw: jsonQuote(s)}
+}
+
+func jsonQuote(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
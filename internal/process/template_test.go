@@ -0,0 +1,70 @@
+package process
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func templateOf(method, target, body string) *RequestTemplate {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(method, target, strings.NewReader(body))
+	c.Request.Header.Set("X-Trace", "t-1")
+	c.Params = gin.Params{{Key: "id", Value: "42"}}
+	return NewRequestTemplate(c)
+}
+
+func TestRender(t *testing.T) {
+	tpl := templateOf("POST", "/users/42?page=3", `{"user": {"name": "bingoo"}}`)
+
+	for s, expected := range map[string]string{
+		"{{request.path.id}}":                                "42",
+		"{{ request.query.page }}":                           "3",
+		"{{request.query.size}}":                             "",
+		"{{request.header.X-Trace}}":                         "t-1",
+		"{{request.body.user.name}}":                         "bingoo",
+		"{{request.method}} {{request.url}}":                 "POST /users/42?page=3",
+		"{{request.path}}":                                   "/users/42",
+		"{{unknown}} and {{request.unknown}}":                "{{unknown}} and {{request.unknown}}",
+		"no templates":                                       "no templates",
+		`{{now "yyyy"}}`:                                     time.Now().Format("2006"),
+		"id={{request.path.id}}&page={{request.query.page}}": "id=42&page=3",
+	} {
+		assert.Equal(t, expected, tpl.Render(s), s)
+	}
+}
+
+func TestRenderBody(t *testing.T) {
+	tpl := templateOf("POST", "/users/42?page=3", `{"user": {"name": "bin\"goo", "age": 18}, "tags": ["a"]}`)
+
+	for s, expected := range map[string]string{
+		`{"id": "{{request.path.id}}", "page": "{{request.query.page}}"}`:       `{"id": "42", "page": "3"}`,
+		`{"trace": "{{request.header.X-Trace}}"}`:                               `{"trace": "t-1"}`,
+		`{"name": "hi {{request.body.user.name}}"}`:                             `{"name": "hi bin\"goo"}`,
+		`{"user": "{{request.body.user}}", "tags": "{{request.body.tags}}"}`:    `{"user": {"name": "bin\"goo", "age": 18}, "tags": ["a"]}`,
+		`{"age": "{{request.body.user.age}}", "none": "{{request.body.none}}"}`: `{"age": 18, "none": ""}`,
+		`{"name": "{{request.body.user.name}}"}`:                                `{"name": "bin\"goo"}`,
+		`{"echo": "{{request.body}}"}`:                                          `{"echo": {"user": {"name": "bin\"goo", "age": 18}, "tags": ["a"]}}`,
+		`{"unknown": "{{unknown}}"}`:                                            `{"unknown": "{{unknown}}"}`,
+		`{"year": "{{now \"yyyy\"}}"}`:                                          `{"year": "` + time.Now().Format("2006") + `"}`,
+	} {
+		assert.JSONEq(t, expected, tpl.RenderBody(s), s)
+	}
+
+	assert.Equal(t, "plain 42", tpl.RenderBody("plain {{request.path.id}}"), "not JSON")
+}
+
+func TestRenderBodyOnce(t *testing.T) {
+	tpl := templateOf("POST", "/users/42?q={{request.header.X-Trace}}",
+		`{"name": "{{request.path.id}}", "text": "{{now}}"}`)
+
+	assert.JSONEq(t, `{"echo": {"name": "{{request.path.id}}", "text": "{{now}}"}}`,
+		tpl.RenderBody(`{"echo": "{{request.body}}"}`), "the templates in the request are not rendered")
+	assert.JSONEq(t, `{"name": "{{request.path.id}}", "q": "{{request.header.X-Trace}}"}`,
+		tpl.RenderBody(`{"name": "{{request.body.name}}", "q": "{{request.query.q}}"}`))
+	assert.Equal(t, "{{request.path.id}}", tpl.Render("{{request.body.name}}"))
+}
@@ -0,0 +1,191 @@
+package process
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/bingoohuang/jj"
+	"github.com/gin-gonic/gin"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+/*
+"_validate": { // the endpoint with a schema failed to compile is not saved, and rejects all the requests with 500
+  "status": 422, // status code for the rejected request, default 400
+  "body": {"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}},
+  "query": {"required": ["page"], "properties": {"page": {"pattern": "^[0-9]+$"}}},
+  "headers": {"required": ["x-trace-id"]} // header names are in lower case
+}
+*/
+
+// Violation is a violation of the request against the `_validate` JSON schemas.
+type Violation struct {
+	// In is the part of the request, body, query or headers.
+	In string `json:"in"`
+	// Field is the JSON pointer of the violated value in the part.
+	Field string `json:"field"`
+	// Keyword is the JSON pointer of the violated keyword in the schema.
+	Keyword string `json:"keyword"`
+	Message string `json:"message"`
+}
+
+// RequestValidator validates the request by the JSON schemas of body, query and headers.
+type RequestValidator struct {
+	body    *jsonschema.Schema
+	query   *jsonschema.Schema
+	headers *jsonschema.Schema
+	status  int
+	// err is the error of the schemas failed to compile, the requests are all rejected.
+	err error
+}
+
+// CreateRequestValidator creates a RequestValidator from the `_validate` block of the endpoint body,
+// nil when the block is absent.
+func CreateRequestValidator(endpoint, body string) *RequestValidator {
+	validate := jj.Get(body, "_validate")
+	if validate.Type != jj.JSON {
+		return nil
+	}
+
+	v := &RequestValidator{status: int(validate.Get("status").Int())}
+	if v.status == 0 {
+		v.status = http.StatusBadRequest
+	}
+
+	compile := func(part string) *jsonschema.Schema {
+		schema := validate.Get(part)
+		if !schema.Exists() {
+			return nil
+		}
+
+		s, err := jsonschema.CompileString(part+".json", schema.Raw)
+		if err != nil {
+			log.Printf("E! compile _validate.%s schema of %s: %v", part, endpoint, err)
+			v.err = errors.Join(v.err, fmt.Errorf("compile _validate.%s schema: %w", part, err))
+		}
+		return s
+	}
+
+	v.body = compile("body")
+	v.query = compile("query")
+	v.headers = compile("headers")
+
+	return v
+}
+
+// Err returns the error of the schemas failed to compile.
+func (v *RequestValidator) Err() error {
+	if v == nil {
+		return nil
+	}
+	return v.err
+}
+
+// Validate validates the request, returns the violations.
+func (v *RequestValidator) Validate(c *gin.Context) []Violation {
+	if v == nil {
+		return nil
+	}
+
+	if v.err != nil {
+		return []Violation{{Message: v.err.Error()}}
+	}
+
+	var violations []Violation
+	if v.body != nil {
+		violations = append(violations, v.validateBody(c)...)
+	}
+	if v.query != nil {
+		query := make(map[string]interface{})
+		for k, values := range c.Request.URL.Query() {
+			query[k] = stringsValue(values)
+		}
+		violations = append(violations, validateValue("query", v.query, query)...)
+	}
+	if v.headers != nil {
+		headers := make(map[string]interface{})
+		for k, values := range c.Request.Header {
+			headers[strings.ToLower(k)] = stringsValue(values)
+		}
+		violations = append(violations, validateValue("headers", v.headers, headers)...)
+	}
+
+	return violations
+}
+
+// Reject responds the violations with the configured status, or 500 when the schemas failed to compile.
+func (v *RequestValidator) Reject(c *gin.Context, violations []Violation) {
+	if v.err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid _validate schema", "violations": violations})
+		return
+	}
+	c.JSON(v.status, gin.H{"error": "request validation failed", "violations": violations})
+}
+
+func (v *RequestValidator) validateBody(c *gin.Context) []Violation {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return []Violation{{In: "body", Message: err.Error()}}
+	}
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(data))
+
+	var body interface{}
+	if len(bytes.TrimSpace(data)) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&body); err != nil {
+			return []Violation{{In: "body", Message: "invalid JSON: " + err.Error()}}
+		}
+	}
+
+	return validateValue("body", v.body, body)
+}
+
+func validateValue(in string, schema *jsonschema.Schema, value interface{}) []Violation {
+	err := schema.Validate(value)
+	if err == nil {
+		return nil
+	}
+
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return []Violation{{In: in, Message: err.Error()}}
+	}
+
+	var violations []Violation
+	var flatten func(*jsonschema.ValidationError)
+	flatten = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			violations = append(violations, Violation{
+				In:      in,
+				Field:   e.InstanceLocation,
+				Keyword: e.KeywordLocation,
+				Message: e.Message,
+			})
+		}
+		for _, cause := range e.Causes {
+			flatten(cause)
+		}
+	}
+	flatten(ve)
+
+	return violations
+}
+
+func stringsValue(values []string) interface{} {
+	if len(values) == 1 {
+		return values[0]
+	}
+
+	items := make([]interface{}, len(values))
+	for i, s := range values {
+		items[i] = s
+	}
+	return items
+}
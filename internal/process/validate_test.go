@@ -0,0 +1,48 @@
+package process
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func validateRequest(v *RequestValidator, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	if violations := v.Validate(c); len(violations) > 0 {
+		v.Reject(c, violations)
+	}
+	c.Writer.WriteHeaderNow()
+	return w
+}
+
+func TestRequestValidator(t *testing.T) {
+	v := CreateRequestValidator("/v", `{"_validate": {"status": 422,
+		"body": {"type": "object", "required": ["name"]},
+		"query": {"required": ["page"]}}}`)
+	assert.Nil(t, v.Err())
+
+	assert.Equal(t, http.StatusOK, validateRequest(v, "/v?page=1", `{"name":"bingoo"}`).Code)
+
+	w := validateRequest(v, "/v", `{}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), `"in":"body"`)
+	assert.Contains(t, w.Body.String(), `"in":"query"`)
+}
+
+func TestRequestValidatorBrokenSchema(t *testing.T) {
+	v := CreateRequestValidator("/v", `{"_validate": {"body": {"type": "object"}, "query": {"type": 1}}}`)
+	assert.NotNil(t, v.Err())
+
+	// the requests are all rejected rather than validated by the part of the schemas.
+	w := validateRequest(v, "/v", `{"name":"bingoo"}`)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "_validate.query")
+
+	assert.Nil(t, CreateRequestValidator("/v", `{}`).Err())
+}
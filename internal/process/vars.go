@@ -9,13 +9,17 @@ import (
 
 // EnvVars ...
 type EnvVars struct {
-	DBFile      string
-	DBFullPath  string
-	Ports       string // Hosting ports, eg. 5003,5004.
-	ContextPath string
-	CaRoot      string
-	BasicAuth   string
-	HTTPretty   bool
+	DBFile       string
+	DBFullPath   string
+	EndpointDir  string // Directory of the endpoint files, instead of the endpoints in the bolt file.
+	ProvisionDir string // Directory of the *.req.json files to provision the endpoints.
+	Ports        string // Hosting ports, eg. 5003,5004.
+	Peers        string // Peers of the cluster to replicate the endpoint changes, eg. http://127.0.0.1:5004.
+	ContextPath  string
+	CaRoot       string
+	BasicAuth    string
+	JournalSize  int // Max entries of the request journal, 0 to disable.
+	HTTPretty    bool
 }
 
 // Init initializes the environments.
@@ -0,0 +1,291 @@
+package process
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bingoohuang/jj"
+)
+
+/*
+POST /httplive/webcli/api/verify
+{
+  "path": "/api/users/*",     // glob of the request path, or the endpoint like /api/users/:id
+  "method": "POST",
+  "headers": {"Content-Type": {"contains": "json"}},
+  "query": {"page": "1"},
+  "body": {"user.name": "bingoo", "user.age": {"equals": 18}, "items.#": {"absent": true}}, // jj paths
+  "count": 2                  // or "atLeast": 1, "atMost": 3, default at least once
+}
+*/
+
+// Verification verifies how many times the journal entries matched the criteria.
+type Verification struct {
+	Headers map[string]*ValueMatcher `json:"headers"`
+	Query   map[string]*ValueMatcher `json:"query"`
+	Body    map[string]*ValueMatcher `json:"body"`
+	Count   *int                     `json:"count"`
+	AtLeast *int                     `json:"atLeast"`
+	AtMost  *int                     `json:"atMost"`
+	Path    string                   `json:"path"`
+	Method  string                   `json:"method"`
+	// Closest is the max number of the closest mismatched requests in the result, default 5.
+	Closest int `json:"closest"`
+}
+
+// ValueMatcher matches a value, a plain JSON value other than object in the verification means equals.
+type ValueMatcher struct {
+	Equals   interface{} `json:"equals"`
+	Min      *float64    `json:"min"`
+	Max      *float64    `json:"max"`
+	Contains string      `json:"contains"`
+	Matches  string      `json:"matches"`
+	Absent   bool        `json:"absent"`
+	// Exists true matches any value which exists, the same as the empty matcher, and false is the same as absent.
+	Exists *bool `json:"exists"`
+
+	re *regexp.Regexp
+}
+
+// UnmarshalJSON unmarshals the matcher object, or the plain value as equals.
+func (m *ValueMatcher) UnmarshalJSON(data []byte) error {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return json.Unmarshal(data, &m.Equals)
+	}
+
+	type matcher ValueMatcher
+	if err := json.Unmarshal(data, (*matcher)(m)); err != nil {
+		return err
+	}
+	if m.Exists != nil && !*m.Exists {
+		m.Absent = true
+	}
+	return nil
+}
+
+// VerifyResult is the result of the verification.
+type VerifyResult struct {
+	Expected string `json:"expected"`
+	// Requests are the matched requests, the latest first.
+	Requests []JournalEntry `json:"requests"`
+	// Closest are the mismatched requests which matched the most criteria.
+	Closest []ClosestMatch `json:"closest,omitempty"`
+	Count   int            `json:"count"`
+	Pass    bool           `json:"pass"`
+}
+
+// ClosestMatch is a mismatched request with the reasons.
+type ClosestMatch struct {
+	Mismatches []string     `json:"mismatches"`
+	Request    JournalEntry `json:"request"`
+}
+
+// Compile compiles the regular expressions of the matchers.
+func (v *Verification) Compile() error {
+	return compileMatchers(map[string]map[string]*ValueMatcher{"headers": v.Headers, "query": v.Query, "body": v.Body})
+}
+
+func (m *ValueMatcher) compile() (err error) {
+	if m != nil && m.Matches != "" {
+		m.re, err = regexp.Compile(m.Matches)
+	}
+	return err
+}
+
+// Verify verifies the entries, Compile should be called before.
+func (v *Verification) Verify(entries []JournalEntry) VerifyResult {
+	result := VerifyResult{Expected: v.expected(), Requests: []JournalEntry{}}
+
+	type scored struct {
+		mismatches []string
+		entry      JournalEntry
+		matched    int
+	}
+	var mismatched []scored
+
+	for _, e := range entries {
+		matched, mismatches := v.match(e)
+		if len(mismatches) == 0 {
+			result.Requests = append(result.Requests, e)
+		} else {
+			mismatched = append(mismatched, scored{entry: e, matched: matched, mismatches: mismatches})
+		}
+	}
+
+	result.Count = len(result.Requests)
+	result.Pass = v.pass(result.Count)
+	if result.Pass {
+		return result
+	}
+
+	sort.SliceStable(mismatched, func(i, j int) bool { return mismatched[i].matched > mismatched[j].matched })
+	closest := v.Closest
+	if closest <= 0 {
+		closest = 5
+	}
+	for i := 0; i < len(mismatched) && i < closest; i++ {
+		result.Closest = append(result.Closest, ClosestMatch{Request: mismatched[i].entry, Mismatches: mismatched[i].mismatches})
+	}
+
+	return result
+}
+
+func (v *Verification) pass(count int) bool {
+	switch {
+	case v.Count != nil:
+		return count == *v.Count
+	case v.AtLeast == nil && v.AtMost == nil:
+		return count >= 1
+	default:
+		return (v.AtLeast == nil || count >= *v.AtLeast) && (v.AtMost == nil || count <= *v.AtMost)
+	}
+}
+
+func (v *Verification) expected() string {
+	switch {
+	case v.Count != nil:
+		return fmt.Sprintf("exactly %d", *v.Count)
+	case v.AtLeast != nil && v.AtMost != nil:
+		return fmt.Sprintf("between %d and %d", *v.AtLeast, *v.AtMost)
+	case v.AtMost != nil:
+		return fmt.Sprintf("at most %d", *v.AtMost)
+	case v.AtLeast != nil:
+		return fmt.Sprintf("at least %d", *v.AtLeast)
+	default:
+		return "at least 1"
+	}
+}
+
+// match returns the number of the matched criteria and the mismatches.
+func (v *Verification) match(e JournalEntry) (matched int, mismatches []string) {
+	check := func(ok bool, mismatch string) {
+		if ok {
+			matched++
+		} else {
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+
+	if v.Path != "" {
+		ok, _ := path.Match(v.Path, e.Path)
+		check(ok || v.Path == e.Endpoint, fmt.Sprintf("path %s does not match %s", e.Path, v.Path))
+	}
+	if v.Method != "" {
+		check(strings.EqualFold(v.Method, e.Method), fmt.Sprintf("method %s is not %s", e.Method, v.Method))
+	}
+
+	for _, k := range matcherKeys(v.Headers) {
+		m := v.Headers[k]
+		value, exists := lookupFold(e.Header, k)
+		check(m.matchString(value, exists), "header "+k+" "+m.describe(value, exists))
+	}
+	for _, k := range matcherKeys(v.Query) {
+		m := v.Query[k]
+		value, exists := e.Query[k]
+		check(m.matchString(value, exists), "query "+k+" "+m.describe(value, exists))
+	}
+
+	body := bodyString(e.Body)
+	for _, k := range matcherKeys(v.Body) {
+		m := v.Body[k]
+		r := jj.Get(body, k)
+		check(m.matchJSON(r), "body "+k+" "+m.describe(r.String(), r.Exists()))
+	}
+
+	return matched, mismatches
+}
+
+func matcherKeys(m map[string]*ValueMatcher) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func lookupFold(m map[string]string, key string) (string, bool) {
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+func (m *ValueMatcher) matchString(value string, exists bool) bool {
+	if m == nil {
+		return exists
+	}
+	if m.Absent || !exists {
+		return m.Absent == !exists
+	}
+
+	return (m.Equals == nil || fmt.Sprint(m.Equals) == value) &&
+		strings.Contains(value, m.Contains) &&
+		(m.re == nil || m.re.MatchString(value)) &&
+		m.matchRange(value)
+}
+
+// matchRange tells whether the value is a number in the range of min and max.
+func (m *ValueMatcher) matchRange(value string) bool {
+	if m.Min == nil && m.Max == nil {
+		return true
+	}
+
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return err == nil && (m.Min == nil || f >= *m.Min) && (m.Max == nil || f <= *m.Max)
+}
+
+func (m *ValueMatcher) matchJSON(r jj.Result) bool {
+	if m == nil || m.Equals == nil || !r.Exists() {
+		return m.matchString(r.String(), r.Exists())
+	}
+
+	var actual interface{}
+	if err := json.Unmarshal([]byte(r.Raw), &actual); err != nil || !reflect.DeepEqual(m.Equals, actual) {
+		return false
+	}
+
+	m2 := *m
+	m2.Equals = nil
+	return m2.matchString(r.String(), true)
+}
+
+func (m *ValueMatcher) describe(value string, exists bool) string {
+	switch {
+	case m == nil:
+		return "is absent"
+	case m.Absent:
+		return fmt.Sprintf("is %q, expected absent", value)
+	case !exists:
+		return "is absent"
+	}
+
+	var expects []string
+	if m.Equals != nil {
+		expects = append(expects, fmt.Sprintf("equal to %v", m.Equals))
+	}
+	if m.Contains != "" {
+		expects = append(expects, fmt.Sprintf("containing %q", m.Contains))
+	}
+	if m.Matches != "" {
+		expects = append(expects, fmt.Sprintf("matching %q", m.Matches))
+	}
+	if m.Min != nil {
+		expects = append(expects, fmt.Sprintf("at least %v", *m.Min))
+	}
+	if m.Max != nil {
+		expects = append(expects, fmt.Sprintf("at most %v", *m.Max))
+	}
+
+	return fmt.Sprintf("is %q, expected %s", value, strings.Join(expects, " and "))
+}
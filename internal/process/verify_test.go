@@ -0,0 +1,26 @@
+package process
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueMatcherExists(t *testing.T) {
+	for _, c := range []struct {
+		matcher             string
+		present, notPresent bool
+	}{
+		{`{}`, true, false},
+		{`{"exists": true}`, true, false},
+		{`{"exists": false}`, false, true},
+		{`{"absent": true}`, false, true},
+		{`"bingoo"`, true, false},
+	} {
+		var m ValueMatcher
+		assert.Nil(t, json.Unmarshal([]byte(c.matcher), &m))
+		assert.Equal(t, c.present, m.matchString("bingoo", true), c.matcher)
+		assert.Equal(t, c.notPresent, m.matchString("", false), c.matcher)
+	}
+}
@@ -1,28 +1,126 @@
 package process
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bingoohuang/gg/pkg/emb"
+	"github.com/bingoohuang/gg/pkg/thinktime"
+	"github.com/bingoohuang/jj"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{}
+/*
+"_hl": "websocket",
+"subprotocols": ["v2.mock", "v1.mock"], // optional, the first one in the order also requested by the client is selected
+"onConnect": [{"message": "welcome @姓名"}],
+"rules": [
+  {"match": "^ping$", "reply": "pong"},                                  // the regex on the text message
+  {"path": "type", "value": "subscribe", "reply": {"id": "@objectId"}},  // the jj path of the JSON message, equal to the value
+  {"path": "message", "delay": "3s", "reply": {"message": "{{request.body.message}} at {{now}}"}} // the path exists
+],
+"push": [{"every": "1s-3s", "message": {"price": "@random_int(1-100)"}, "times": 10}], // times 0 for endless
+"close": {"after": 5, "code": 1000, "reason": "bye"} // closes after the client sent the 5th message
+
+The messages are generated by jj.Gen when they are sent, and {{request.body...}} of the replies is the received message.
+Without any script, the demo one greets and replies the message of the demo page, which the plain GET requests get.
+The demo page connects with ?websocket=y.
+*/
 
 func init() {
 	registerHlHandlers("websocket", func() HlHandler { return &websocketHandler{} })
 }
 
-type websocketHandler struct{}
+// websocketHandler is the script of the websocket mock.
+type websocketHandler struct {
+	Subprotocols []string          `json:"subprotocols"`
+	OnConnect    []json.RawMessage `json:"onConnect"`
+	Rules        []websocketRule   `json:"rules"`
+	Push         []websocketPush   `json:"push"`
+	Close        *websocketClose   `json:"close"`
+
+	err error
+}
+
+type websocketRule struct {
+	Match string          `json:"match"`
+	Path  string          `json:"path"`
+	Value *string         `json:"value"`
+	Delay string          `json:"delay"`
+	Reply json.RawMessage `json:"reply"`
+
+	match *regexp.Regexp
+	delay *thinktime.ThinkTime
+}
+
+type websocketPush struct {
+	Every   string          `json:"every"`
+	Message json.RawMessage `json:"message"`
+	Times   int             `json:"times"`
 
-func (w websocketHandler) HlHandle(c *gin.Context, _ *APIDataModel, _ func(name string) string) error {
-	if c.Query("websocket") != "" {
-		handleConnections(c.Writer, c.Request)
+	every *thinktime.ThinkTime
+}
+
+type websocketClose struct {
+	After  int    `json:"after"`
+	Code   int    `json:"code"`
+	Reason string `json:"reason"`
+}
+
+// demoWebsocketScript greets and replies the messages of the demo page every 3 seconds.
+const demoWebsocketScript = `{
+"onConnect": [{"message": "First blood at {{now \"yyyy-MM-dd HH:mm:ss\"}}"}],
+"rules": [{"path": "message", "delay": "3s", "reply": {"message": "{{request.body.message}} at {{now \"yyyy-MM-dd HH:mm:ss\"}}"}}]
+}`
+
+// AfterUnmashal compiles the script, the errors are responded to the connecting clients.
+func (w *websocketHandler) AfterUnmashal() {
+	if len(w.OnConnect) == 0 && len(w.Rules) == 0 && len(w.Push) == 0 && w.Close == nil {
+		_ = json.Unmarshal([]byte(demoWebsocketScript), w)
+	}
+
+	for i, r := range w.Rules {
+		if r.Match != "" {
+			if w.Rules[i].match, w.err = regexp.Compile(r.Match); w.err != nil {
+				w.err = fmt.Errorf("websocket rule #%d: %w", i+1, w.err)
+				return
+			}
+		}
+		if r.Delay != "" {
+			if w.Rules[i].delay, w.err = thinktime.ParseThinkTime(r.Delay); w.err != nil {
+				w.err = fmt.Errorf("websocket rule #%d: %w", i+1, w.err)
+				return
+			}
+		}
+	}
+
+	for i, p := range w.Push {
+		if w.Push[i].every, w.err = thinktime.ParseThinkTime(p.Every); w.err != nil {
+			w.err = fmt.Errorf("websocket push #%d: %w", i+1, w.err)
+			return
+		}
+	}
+
+	if w.Close != nil && w.Close.Code == 0 {
+		w.Close.Code = websocket.CloseNormalClosure
+	}
+}
+
+func (w *websocketHandler) HlHandle(c *gin.Context, apiModel *APIDataModel, _ func(name string) string) error {
+	if c.Query("websocket") != "" || websocket.IsWebSocketUpgrade(c.Request) {
+		if w.err != nil {
+			return w.err
+		}
+
+		w.serve(c, apiModel.Endpoint)
 		return nil
 	}
 
@@ -39,38 +137,142 @@ func (w websocketHandler) HlHandle(c *gin.Context, _ *APIDataModel, _ func(name
 	return nil
 }
 
-type Message struct {
-	Message string `json:"message"`
+// websocketConn serializes the writes of the replies and the pushes to the connection.
+type websocketConn struct {
+	*websocket.Conn
+	c        *gin.Context
+	t        *RequestTemplate // detached from c, shared by the pushes and the replies
+	endpoint string
+	mu       sync.Mutex
 }
 
-// 注册成为 websocket
-func handleConnections(w http.ResponseWriter, r *http.Request) {
-	ws, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Fatal(err)
+// send generates the message and sends it as a text one, false when the connection is broken.
+func (conn *websocketConn) send(message json.RawMessage, t *RequestTemplate) bool {
+	data := genMessage(message, t)
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(data)); err != nil {
+		conn.logf("write: %v", err)
+		return false
 	}
-	defer ws.Close()
+	return true
+}
+
+func (conn *websocketConn) close(code int, reason string) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
 
-	msg := Message{Message: "First blood at " + time.Now().Format("2006-01-02 15:04:05")}
-	if err := ws.WriteJSON(msg); err != nil {
-		log.Printf("error: %v", err)
+	msg := websocket.FormatCloseMessage(code, reason)
+	_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
+
+func (conn *websocketConn) logf(format string, args ...interface{}) {
+	log.Printf("E! websocket %s of %s: %s", conn.endpoint, conn.c.Request.RemoteAddr, fmt.Sprintf(format, args...))
+}
+
+// serve runs the script on the connection, until the client goes away or the script closes it.
+func (w *websocketHandler) serve(c *gin.Context, endpoint string) {
+	upgrader := websocket.Upgrader{
+		Subprotocols: w.Subprotocols,
+		CheckOrigin:  func(*http.Request) bool { return true },
+	}
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader has responded the error already.
+		log.Printf("E! websocket %s upgrade: %v", endpoint, err)
 		return
 	}
 
-	for {
-		time.Sleep(3 * time.Second)
-		// 接收数据
-		var msgRecv Message
-		if err := ws.ReadJSON(&msgRecv); err != nil {
-			log.Printf("error: %v", err)
-			break
+	conn := &websocketConn{Conn: ws, c: c, t: NewRequestTemplate(c).detach(), endpoint: endpoint}
+	done := make(chan struct{})
+	var pushing sync.WaitGroup
+	defer func() {
+		close(done)
+		_ = conn.Close()
+		pushing.Wait() // the gin context is reused after the handler returns
+	}()
+
+	for _, m := range w.OnConnect {
+		if !conn.send(m, conn.t) {
+			return
+		}
+	}
+
+	for _, p := range w.Push {
+		pushing.Add(1)
+		go func(p websocketPush) {
+			defer pushing.Done()
+			p.run(conn, conn.t, done)
+		}(p)
+	}
+
+	for received := 1; ; received++ {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway,
+				websocket.CloseNoStatusReceived, websocket.CloseAbnormalClosure) {
+				conn.logf("read: %v", err)
+			}
+			return
+		}
+
+		if !w.reply(conn, data) {
+			return
+		}
+
+		if w.Close != nil && w.Close.After > 0 && received >= w.Close.After {
+			conn.close(w.Close.Code, w.Close.Reason)
+			return
+		}
+	}
+}
+
+// reply replies the message by the first matched rule, false when the connection is broken.
+func (w *websocketHandler) reply(conn *websocketConn, data []byte) bool {
+	for _, r := range w.Rules {
+		if r.matches(data) {
+			if r.delay != nil {
+				r.delay.Think(true)
+			}
+			if len(r.Reply) == 0 {
+				return true
+			}
+			return conn.send(r.Reply, conn.t.withBody(data))
+		}
+	}
+
+	return true
+}
+
+func (r websocketRule) matches(data []byte) bool {
+	if r.match != nil && !r.match.Match(data) {
+		return false
+	}
+
+	if r.Path != "" {
+		v := jj.GetBytes(data, r.Path)
+		if !v.Exists() || r.Value != nil && v.String() != *r.Value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p websocketPush) run(conn *websocketConn, t *RequestTemplate, done <-chan struct{}) {
+	for i := 0; p.Times <= 0 || i < p.Times; i++ {
+		timer := time.NewTimer(p.every.Think(false))
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case <-timer.C:
 		}
-		log.Println(msgRecv)
 
-		msg := Message{Message: msgRecv.Message + " at " + time.Now().Format("2006-01-02 15:04:05")}
-		if err := ws.WriteJSON(msg); err != nil {
-			log.Printf("error: %v", err)
-			break
+		if !conn.send(p.Message, t) {
+			return
 		}
 	}
 }
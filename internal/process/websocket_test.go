@@ -0,0 +1,56 @@
+package process
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebsocketTemplates(t *testing.T) {
+	w := &websocketHandler{}
+	assert.Nil(t, json.Unmarshal([]byte(`{
+		"onConnect": [{"hello": "{{request.path}}"}],
+		"rules": [{"path": "message", "reply": {"echo": "{{request.body.message}}", "to": "{{request.query.name}}"}}],
+		"push": [
+			{"every": "1ms", "message": {"push": "{{request.query.name}}"}, "times": 20},
+			{"every": "1ms", "message": {"push": "{{request.query.name}}"}, "times": 20}
+		]
+	}`), w))
+	w.AfterUnmashal()
+	assert.Nil(t, w.err)
+
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.GET("/ws", func(c *gin.Context) { _ = w.HlHandle(c, &APIDataModel{Endpoint: "/ws"}, nil) })
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws?name=bingoo", nil)
+	assert.Nil(t, err)
+	defer ws.Close()
+
+	_, data, err := ws.ReadMessage()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"hello":"/ws"}`, string(data))
+
+	assert.Nil(t, ws.WriteMessage(websocket.TextMessage, []byte(`{"message":"hi"}`)))
+	pushes, replied := 0, false
+	for pushes < 40 || !replied {
+		_, data, err := ws.ReadMessage()
+		if !assert.Nil(t, err) {
+			return
+		}
+		if strings.Contains(string(data), "push") {
+			assert.JSONEq(t, `{"push":"bingoo"}`, string(data))
+			pushes++
+		} else {
+			assert.JSONEq(t, `{"echo":"hi","to":"bingoo"}`, string(data))
+			replied = true
+		}
+	}
+}
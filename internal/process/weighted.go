@@ -0,0 +1,125 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/bingoohuang/gg/pkg/thinktime"
+	"github.com/bingoohuang/jj"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+"_weighted": [
+  {"weight": 90, "response": {"ok": true}},
+  {"weight": 8, "status": 429, "headers": {"Retry-After": "1"}, "response": {"error": "too many requests"}},
+  {"weight": 2, "status": 503, "sleep": "100ms-500ms", "response": {"error": "unavailable"}}
+],
+"_weightedSeed": 42 // optional, the variants are picked in the same order for the same seed since the endpoint is loaded
+*/
+
+// WeightedVariant is a response variant picked by its weight, mockbin endpoints can use payload instead of response.
+type WeightedVariant struct {
+	SequenceStep
+	Sleep  string  `json:"sleep"`
+	Weight float64 `json:"weight"`
+
+	think *thinktime.ThinkTime // parsed from Sleep, nil when no sleep
+}
+
+// Weighted picks the response variants randomly by their weights.
+type Weighted struct {
+	Variants []WeightedVariant
+	total    float64
+
+	mu  sync.Mutex
+	rnd *rand.Rand // nil to use the global random source when no seed is given
+}
+
+// CreateWeighted creates the weighted variants from the `_weighted` block of the endpoint body, nil when it is absent.
+func CreateWeighted(endpoint, body string) (*Weighted, error) {
+	weighted := jj.Get(body, "_weighted")
+	if !weighted.Exists() {
+		return nil, nil
+	}
+	if weighted.Type != jj.JSON || !weighted.IsArray() {
+		return nil, fmt.Errorf("_weighted of %s: array expected", endpoint)
+	}
+
+	w := &Weighted{}
+	if err := json.Unmarshal([]byte(weighted.Raw), &w.Variants); err != nil {
+		return nil, fmt.Errorf("_weighted of %s: %w", endpoint, err)
+	}
+
+	for i := range w.Variants {
+		v := &w.Variants[i]
+		if v.Weight < 0 {
+			return nil, fmt.Errorf("_weighted #%d of %s: negative weight %v", i+1, endpoint, v.Weight)
+		}
+		var err error
+		if v.think, err = thinktime.ParseThinkTime(v.Sleep); err != nil {
+			return nil, fmt.Errorf("_weighted #%d of %s: sleep %s: %w", i+1, endpoint, v.Sleep, err)
+		}
+		w.total += v.Weight
+	}
+	if w.total <= 0 {
+		return nil, fmt.Errorf("_weighted of %s: no variant with positive weight", endpoint)
+	}
+
+	if seed := jj.Get(body, "_weightedSeed"); seed.Exists() {
+		w.rnd = rand.New(rand.NewSource(seed.Int()))
+	}
+
+	return w, nil
+}
+
+// Pick picks a variant randomly by the weights.
+func (w *Weighted) Pick() WeightedVariant {
+	r := w.float64() * w.total
+	for _, v := range w.Variants {
+		if r < v.Weight {
+			return v
+		}
+		r -= v.Weight
+	}
+
+	// the rounding of the floats may leave r a little over, the last positive one takes it.
+	for i := len(w.Variants) - 1; ; i-- {
+		if w.Variants[i].Weight > 0 {
+			return w.Variants[i]
+		}
+	}
+}
+
+func (w *Weighted) float64() float64 {
+	if w.rnd == nil {
+		return rand.Float64()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rnd.Float64()
+}
+
+// Think sleeps for the sleep of the variant, like 100ms or 100ms-500ms, and returns the time slept.
+func (v WeightedVariant) Think() time.Duration {
+	if v.think == nil {
+		return 0
+	}
+	return v.think.Think(true)
+}
+
+// weightedProcess responds by a randomly picked variant, false when the endpoint has no weighted variants.
+func weightedProcess(c *gin.Context, ep APIDataModel) bool {
+	if ep.weighted == nil {
+		return false
+	}
+
+	v := ep.weighted.Pick()
+	v.Think()
+	DynamicValue{Headers: v.Headers, Response: v.Body(), Status: v.Status}.responseDynamic(ep, c)
+	return true
+}
@@ -0,0 +1,110 @@
+package process
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const weightedTestVariants = `"_weighted": [
+	{"weight": 90, "response": {"v": "ok"}},
+	{"weight": 8, "status": 429, "headers": {"Retry-After": "1"}, "response": {"v": "busy"}},
+	{"weight": 0, "response": {"v": "never"}},
+	{"weight": 2, "status": 503, "response": {"v": "down"}}
+]`
+
+func picks(w *Weighted, n int) map[string]int {
+	counts := map[string]int{}
+	for i := 0; i < n; i++ {
+		counts[string(w.Pick().Response)]++
+	}
+	return counts
+}
+
+func TestWeightedSeed(t *testing.T) {
+	body := `{` + weightedTestVariants + `, "_weightedSeed": 42}`
+	w1, err := CreateWeighted("/w", body)
+	assert.Nil(t, err)
+	w2, _ := CreateWeighted("/w", body)
+
+	var order1, order2 []string
+	for i := 0; i < 100; i++ {
+		order1 = append(order1, string(w1.Pick().Response))
+		order2 = append(order2, string(w2.Pick().Response))
+	}
+	assert.Equal(t, order1, order2, "the same order for the same seed")
+
+	w3, _ := CreateWeighted("/w", `{`+weightedTestVariants+`, "_weightedSeed": 7}`)
+	assert.NotEqual(t, picks(w1, 100), picks(w3, 100))
+}
+
+func TestWeightedDistribution(t *testing.T) {
+	w, err := CreateWeighted("/w", `{`+weightedTestVariants+`, "_weightedSeed": 1}`)
+	assert.Nil(t, err)
+
+	const n = 100000
+	counts := picks(w, n)
+	assert.InDelta(t, 0.90, float64(counts[`{"v": "ok"}`])/n, 0.01)
+	assert.InDelta(t, 0.08, float64(counts[`{"v": "busy"}`])/n, 0.01)
+	assert.InDelta(t, 0.02, float64(counts[`{"v": "down"}`])/n, 0.01)
+	assert.Zero(t, counts[`{"v": "never"}`], "zero weight")
+
+	w, _ = CreateWeighted("/w", `{"_weighted": [{"weight": 1, "response": 1}, {"weight": 0, "response": 2}]}`)
+	assert.Equal(t, map[string]int{"1": 1000}, picks(w, 1000))
+}
+
+func TestWeightedSleep(t *testing.T) {
+	w, err := CreateWeighted("/w", `{"_weighted": [{"weight": 1, "sleep": "20ms", "response": {}}]}`)
+	assert.Nil(t, err)
+	start := time.Now()
+	assert.Equal(t, 20*time.Millisecond, w.Pick().Think())
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	w, _ = CreateWeighted("/w", `{"_weighted": [{"weight": 1, "sleep": "1ms-5ms", "response": {}}]}`)
+	for i := 0; i < 10; i++ {
+		d := w.Pick().Think()
+		assert.GreaterOrEqual(t, d, time.Millisecond)
+		assert.Less(t, d, 5*time.Millisecond)
+	}
+
+	w, _ = CreateWeighted("/w", `{"_weighted": [{"weight": 1, "response": {}}]}`)
+	assert.Zero(t, w.Pick().Think(), "no sleep")
+}
+
+func TestWeightedProcess(t *testing.T) {
+	config := `{"_weighted": [{"weight": 1, "status": 429, "headers": {"Retry-After": "1"}, "sleep": "1ms", "response": {"v": "busy"}}]}`
+	m := &APIDataModel{Endpoint: "/w", Method: "GET", Body: RawMessage(config)}
+	assert.True(t, (&Endpoint{Endpoint: "/w"}).CreateDefault(m, config, nil))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/w", nil)
+	m.ServeFn(c)
+	c.Writer.WriteHeaderNow()
+	assert.Equal(t, 429, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"v": "busy"}`, w.Body.String())
+}
+
+func TestCreateWeightedErrors(t *testing.T) {
+	for _, body := range []string{
+		`{"_weighted": {"weight": 1}}`,
+		`{"_weighted": []}`,
+		`{"_weighted": [{"weight": 0, "response": 1}, {"weight": 0, "response": 2}]}`,
+		`{"_weighted": [{"weight": -1, "response": 1}, {"weight": 2, "response": 2}]}`,
+		`{"_weighted": [{"weight": "1", "response": 1}]}`,
+		`{"_weighted": [{"weight": 1, "sleep": "soon", "response": 1}]}`,
+		`{"_weighted": [{"weight": 1, "sleep": "5ms-1ms", "response": 1}]}`,
+	} {
+		w, err := CreateWeighted("/w", body)
+		assert.Nil(t, w, body)
+		assert.NotNil(t, err, body)
+	}
+
+	w, err := CreateWeighted("/w", `{"response": {}}`)
+	assert.Nil(t, w, "no weighted")
+	assert.Nil(t, err)
+}
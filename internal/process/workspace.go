@@ -0,0 +1,106 @@
+package process
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Workspace is a named set of endpoints, selected by the listening port,
+// the host header or the path prefix of the request.
+// The endpoints out of any workspace belong to the default workspace, whose name is empty.
+type Workspace struct {
+	Name       string   `json:"name" storm:"id"`
+	Ports      []string `json:"ports,omitempty"`
+	Hosts      []string `json:"hosts,omitempty"`
+	Prefix     string   `json:"prefix,omitempty"`
+	CreateTime string   `json:"createTime"`
+}
+
+var workspaceNameRegexp = regexp.MustCompile(`^[\w.-]+$`)
+
+// Normalize validates the workspace and cleans its selectors.
+func (w *Workspace) Normalize() error {
+	if !workspaceNameRegexp.MatchString(w.Name) {
+		return fmt.Errorf("bad workspace name %q, only letters, digits, '_', '-' and '.' are allowed", w.Name)
+	}
+
+	if w.Prefix != "" {
+		w.Prefix = path.Clean("/" + w.Prefix)
+		if w.Prefix == "/" || strings.HasPrefix(w.Prefix, "/httplive") {
+			return fmt.Errorf("bad workspace prefix %q", w.Prefix)
+		}
+	}
+
+	// the same forms as the --port flag, like 5004:http or unix:/tmp/a.sock.
+	for i, port := range w.Ports {
+		port = strings.TrimSuffix(strings.TrimSuffix(port, ":http"), ":https")
+		w.Ports[i] = strings.TrimPrefix(port, "unix:")
+	}
+
+	for i, host := range w.Hosts {
+		w.Hosts[i] = strings.ToLower(host)
+	}
+
+	return nil
+}
+
+// Conflicts returns the selector of w claimed by the other workspace already, or empty.
+func (w Workspace) Conflicts(o Workspace) string {
+	switch {
+	case w.Prefix != "" && w.Prefix == o.Prefix:
+		return "prefix " + w.Prefix
+	case anyIn(w.Ports, o.Ports) != "":
+		return "port " + anyIn(w.Ports, o.Ports)
+	case anyIn(w.Hosts, o.Hosts) != "":
+		return "host " + anyIn(w.Hosts, o.Hosts)
+	default:
+		return ""
+	}
+}
+
+func anyIn(a, b []string) string {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return x
+			}
+		}
+	}
+	return ""
+}
+
+// SelectWorkspace selects the workspace of the request by its path (with the context path trimmed) first,
+// then by the host header, then by the local address the request is received on.
+// The matched prefix is returned to be trimmed from the path.
+func SelectWorkspace(workspaces []Workspace, p, host, localAddr string) (name, prefix string) {
+	for _, w := range workspaces {
+		if w.Prefix != "" && (p == w.Prefix || strings.HasPrefix(p, w.Prefix+"/")) {
+			return w.Name, w.Prefix
+		}
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	for _, w := range workspaces {
+		if anyIn([]string{host}, w.Hosts) != "" {
+			return w.Name, ""
+		}
+	}
+
+	port := localAddr
+	if _, p, err := net.SplitHostPort(localAddr); err == nil {
+		port = p
+	}
+	for _, w := range workspaces {
+		if anyIn([]string{port, localAddr}, w.Ports) != "" {
+			return w.Name, ""
+		}
+	}
+
+	return "", ""
+}
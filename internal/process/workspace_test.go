@@ -0,0 +1,48 @@
+package process
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectWorkspace(t *testing.T) {
+	workspaces := []Workspace{
+		{Name: "byPort", Ports: []string{"5004", "/tmp/a.sock"}},
+		{Name: "byHost", Hosts: []string{"b.local"}},
+		{Name: "byPrefix", Prefix: "/teamA"},
+	}
+
+	for _, c := range []struct {
+		p, host, localAddr string
+		name, prefix       string
+	}{
+		{"/teamA/users", "b.local", "127.0.0.1:5004", "byPrefix", "/teamA"},
+		{"/teamA", "other", "127.0.0.1:5003", "byPrefix", "/teamA"},
+		{"/teamAB/users", "b.local:5003", "127.0.0.1:5004", "byHost", ""},
+		{"/users", "B.Local", "127.0.0.1:5004", "byHost", ""},
+		{"/users", "other:5004", "127.0.0.1:5004", "byPort", ""},
+		{"/users", "other", "/tmp/a.sock", "byPort", ""},
+		{"/users", "other", "127.0.0.1:5003", "", ""},
+		{"/users", "", "", "", ""},
+	} {
+		name, prefix := SelectWorkspace(workspaces, c.p, c.host, c.localAddr)
+		assert.Equal(t, c.name, name, "%+v", c)
+		assert.Equal(t, c.prefix, prefix, "%+v", c)
+	}
+}
+
+func TestWorkspaceNormalizeConflicts(t *testing.T) {
+	w := Workspace{Name: "a", Prefix: "teamA/", Ports: []string{"5004:http", "unix:/tmp/a.sock"}, Hosts: []string{"A.Local"}}
+	assert.Nil(t, w.Normalize())
+	assert.Equal(t, Workspace{Name: "a", Prefix: "/teamA", Ports: []string{"5004", "/tmp/a.sock"}, Hosts: []string{"a.local"}}, w)
+
+	for _, bad := range []Workspace{{Name: "a b"}, {Name: "a", Prefix: "/"}, {Name: "a", Prefix: "/httplive/x"}} {
+		assert.NotNil(t, bad.Normalize(), "%+v", bad)
+	}
+
+	assert.Equal(t, "prefix /teamA", w.Conflicts(Workspace{Prefix: "/teamA"}))
+	assert.Equal(t, "port 5004", w.Conflicts(Workspace{Ports: []string{"5004"}}))
+	assert.Equal(t, "host a.local", w.Conflicts(Workspace{Hosts: []string{"a.local"}}))
+	assert.Empty(t, w.Conflicts(Workspace{Prefix: "/teamB", Ports: []string{"5005"}}))
+}
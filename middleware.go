@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bingoohuang/httplive/internal/process"
@@ -68,8 +70,10 @@ func APIMiddleware(enableHTTPretty bool) func(c *gin.Context) {
 
 		f := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if result := serveAPI(w, r); result.RouterServed {
+				msg := createWsMessage(c, &bufferRead, result)
+				journal(msg, result)
 				if broadcastThrottler.Allow() {
-					broadcast(c, &bufferRead, result)
+					broadcast(msg)
 				}
 
 				c.Abort()
@@ -112,8 +116,8 @@ func CreateTeeReader(rc io.ReadCloser, w io.Writer) io.ReadCloser {
 	return &ReadCloser{Reader: tee, Closer: rc}
 }
 
-func broadcast(c *gin.Context, requestBody *bytes.Buffer, rr process.RouterResult) {
-	msg := process.WsMessage{
+func createWsMessage(c *gin.Context, requestBody *bytes.Buffer, rr process.RouterResult) process.WsMessage {
+	return process.WsMessage{
 		Time:   util.TimeFmt(time.Now()),
 		Host:   c.Request.Host,
 		Body:   util.GetRequestBody(requestBody),
@@ -127,8 +131,86 @@ func broadcast(c *gin.Context, requestBody *bytes.Buffer, rr process.RouterResul
 		ResponseStatus: rr.ResponseStatus,
 		ResponseHeader: rr.ResponseHeader,
 		RemoteAddr:     rr.RemoteAddr,
+		Violations:     rr.Violations,
+		Faults:         rr.Faults,
 	}
+}
+
+// journalQueueSize is the max entries waiting for the journal writer, the more ones are dropped.
+const journalQueueSize = 4096
+
+var (
+	journalQueue      = make(chan process.JournalEntry, journalQueueSize)
+	journalFlushes    = make(chan chan struct{})
+	journalWriterOnce sync.Once
+	journalDropped    atomic.Int64
+)
+
+// journal queues the served request/response to the request journal, without waiting for the DB.
+func journal(msg process.WsMessage, rr process.RouterResult) {
+	if Envs.JournalSize <= 0 {
+		return
+	}
+
+	journalWriterOnce.Do(func() { go writeJournal() })
+
+	entry := process.JournalEntry{WsMessage: msg, Timestamp: time.Now(), Endpoint: rr.Endpoint, Workspace: rr.Workspace}
+	select {
+	case journalQueue <- entry:
+	default:
+		journalDropped.Add(1)
+	}
+}
+
+// flushJournal waits for the queued entries to be written, before reading the journal.
+func flushJournal() {
+	if Envs.JournalSize <= 0 {
+		return
+	}
+
+	journalWriterOnce.Do(func() { go writeJournal() })
+
+	flushed := make(chan struct{})
+	journalFlushes <- flushed
+	<-flushed
+}
+
+// writeJournal writes the queued entries, all the ones queued meanwhile in one transaction.
+func writeJournal() {
+	for {
+		var batch []process.JournalEntry
+		var flushed chan struct{}
+		select {
+		case entry := <-journalQueue:
+			batch = append(batch, entry)
+		case flushed = <-journalFlushes:
+		}
+
+	drain:
+		for {
+			select {
+			case entry := <-journalQueue:
+				batch = append(batch, entry)
+			default:
+				break drain
+			}
+		}
+
+		if n := journalDropped.Swap(0); n > 0 {
+			logrus.Warnf("%d journal entries dropped for the full queue", n)
+		}
+		if len(batch) > 0 {
+			if err := DBDo(func(dao *Dao) error { return dao.AppendJournal(batch, Envs.JournalSize) }); err != nil {
+				logrus.Warnf("append journal error: %v", err)
+			}
+		}
+		if flushed != nil {
+			close(flushed)
+		}
+	}
+}
 
+func broadcast(msg process.WsMessage) {
 	for id, conn := range Clients {
 		if err := conn.WriteJSON(msg); err != nil {
 			logrus.Warnf("conn WriteJSON error: %v", err)
@@ -0,0 +1,151 @@
+package httplive
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/bingoohuang/gg/pkg/v"
+	"github.com/bingoohuang/httplive/internal/process"
+	"github.com/bingoohuang/httplive/pkg/openapi"
+	"github.com/bingoohuang/jj"
+	"github.com/hjson/hjson-go/v4"
+)
+
+// OpenAPIImportResult is the result of importing an OpenAPI document.
+type OpenAPIImportResult struct {
+	Imported []string `json:"imported"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// ImportOpenAPI creates one endpoint per path and operation of the OpenAPI 3 / Swagger 2 document in the workspace.
+func ImportOpenAPI(workspace string, data []byte) (*OpenAPIImportResult, error) {
+	operations, err := openapi.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &OpenAPIImportResult{Imported: []string{}}
+	for _, op := range operations {
+		name := op.Method + " " + op.Path
+		model := process.APIDataModel{
+			Endpoint:  op.Path,
+			Method:    op.Method,
+			Body:      createOperationBody(op),
+			Workspace: workspace,
+		}
+		if _, err := SaveEndpoint(model); err != nil {
+			result.Errors = append(result.Errors, name+": "+err.Error())
+			continue
+		}
+
+		result.Imported = append(result.Imported, name)
+	}
+
+	return result, nil
+}
+
+// createOperationBody creates the endpoint body from the operation,
+// a mockbin body is used when the status or the content type is not the default one.
+func createOperationBody(op openapi.Operation) process.RawMessage {
+	if op.Status == 200 && strings.Contains(op.ContentType, "json") && len(op.Body) > 0 {
+		return process.RawMessage(op.Body)
+	}
+
+	body, _ := json.Marshal(struct {
+		Hl          string          `json:"_hl"`
+		Status      int             `json:"status"`
+		ContentType string          `json:"contentType,omitempty"`
+		Payload     json.RawMessage `json:"payload,omitempty"`
+	}{Hl: process.HLMockbin, Status: op.Status, ContentType: op.ContentType, Payload: op.Body})
+
+	return body
+}
+
+// ExportOpenAPI generates the OpenAPI 3 document of the endpoints in the workspace.
+func ExportOpenAPI(workspace string) map[string]interface{} {
+	var endpoints []openapi.Endpoint
+	for _, ep := range EndpointList(workspace, true) {
+		if strings.HasPrefix(ep.Endpoint, "/_internal") {
+			continue
+		}
+
+		endpoints = append(endpoints, createOpenAPIEndpoint(ep))
+	}
+
+	return openapi.Generate("httplive", v.AppVersion, endpoints)
+}
+
+func createOpenAPIEndpoint(ep process.APIDataModel) openapi.Endpoint {
+	e := openapi.Endpoint{Method: ep.Method, Path: JoinContextPath(ep.Endpoint, &ep)}
+	if ep.MimeType != "" {
+		e.ContentType = ep.MimeType
+		return e
+	}
+
+	body := process.ParseJSON(string(ep.Body))
+
+	var auth process.AuthorizationWrap
+	_ = json.Unmarshal([]byte(body), &auth)
+	e.Security = createOpenAPISecurity(auth.Auth)
+
+	switch hl := jj.Get(body, "_hl").String(); {
+	case hl == process.HLMockbin:
+		var m process.Mockbin
+		_ = json.Unmarshal([]byte(body), &m)
+		e.Status = m.Status
+		e.ContentType = m.ContentType
+		e.Body = decodeBody(m.Payload)
+	case hl != "", jj.Get(body, "_proxy").Exists(), jj.Get(body, "_echo").Exists():
+		// the response is not described by the body.
+	case jj.Get(body, "_direct").Exists():
+		e.Body = decodeBody([]byte(jj.Get(body, "_direct").Raw))
+	default:
+		e.Body = decodeBody([]byte(body))
+		if m, ok := e.Body.(map[string]interface{}); ok {
+			for k := range m {
+				if strings.HasPrefix(k, "_") {
+					delete(m, k)
+				}
+			}
+		}
+	}
+
+	return e
+}
+
+func createOpenAPISecurity(auth *process.Authorization) *openapi.Security {
+	switch {
+	case auth == nil:
+		return nil
+	case auth.BasicAuth != "":
+		return openapi.BasicAuth()
+	case auth.BearerToken != "":
+		return openapi.BearerToken()
+	case auth.ApiKey != nil && auth.ApiKey.Key != "":
+		in := "header"
+		if auth.ApiKey.QueryParams && !auth.ApiKey.Header {
+			in = "query"
+		}
+		return openapi.APIKey(auth.ApiKey.Key, in)
+	default:
+		return nil
+	}
+}
+
+// decodeBody decodes the JSON or HJSON body, nil for empty or invalid body.
+func decodeBody(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(data, &body); err == nil {
+		return body
+	}
+
+	if err := hjson.Unmarshal(data, &body); err == nil {
+		return body
+	}
+
+	return nil
+}
@@ -14,6 +14,11 @@ func init() { registerEvaluator("@now", &NowEvaluator{}) }
 
 var dateReplacer, _ = util.ParseReplacer("yyyy,i=>2006 MM=>01 dd,i=>02 HH=>15 hh=>03 mm=>04 sss,i=>000 ss,i=>05")
 
+// DateLayout converts the date format like yyyy-MM-dd HH:mm:ss.SSS to the Go layout.
+func DateLayout(dateFmt string) string {
+	return dateReplacer.Replace(dateFmt)
+}
+
 func (d NowEvaluator) Eval(ctx *Context, key, param string) EvaluatorResult {
 	jp := jj.Parse(param)
 	dateFmt := "yyyy-MM-dd hh:mm:ss.SSS"
@@ -27,7 +32,7 @@ func (d NowEvaluator) Eval(ctx *Context, key, param string) EvaluatorResult {
 	} else if len(param) > 0 {
 		dateFmt = param[1:]
 	}
-	dateFmt = dateReplacer.Replace(dateFmt)
+	dateFmt = DateLayout(dateFmt)
 
 	return EvaluatorResult{
 		Mode: EvaluatorSet,
@@ -0,0 +1,193 @@
+package openapi
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Endpoint is a mock endpoint to generate the OpenAPI document.
+type Endpoint struct {
+	// Security is the security scheme which the endpoint requires, nil for none.
+	Security *Security
+	// Body is the decoded response body, nil for no content.
+	Body interface{}
+	// Method is the HTTP method, ANY for all the common methods.
+	Method string
+	// Path is the gin style path, like /users/:id or /static/*file.
+	Path string
+	// ContentType is the content type of the response.
+	ContentType string
+	// Status is the HTTP status code of the response, 0 for 200.
+	Status int
+}
+
+// Security is a named security scheme object.
+type Security struct {
+	Scheme map[string]interface{}
+	Name   string
+}
+
+// BasicAuth creates the security scheme of HTTP basic authentication.
+func BasicAuth() *Security {
+	return &Security{Name: "basicAuth", Scheme: map[string]interface{}{"type": "http", "scheme": "basic"}}
+}
+
+// BearerToken creates the security scheme of HTTP bearer token authentication.
+func BearerToken() *Security {
+	return &Security{Name: "bearerAuth", Scheme: map[string]interface{}{"type": "http", "scheme": "bearer"}}
+}
+
+// APIKey creates the security scheme of API key in the header or the query.
+func APIKey(name, in string) *Security {
+	return &Security{
+		Name:   "apiKey_" + in + "_" + name,
+		Scheme: map[string]interface{}{"type": "apiKey", "name": name, "in": in},
+	}
+}
+
+// AnyMethods are the methods of the operations generated for the ANY method endpoint.
+var AnyMethods = []string{"get", "post", "put", "patch", "delete"}
+
+// Generate generates the OpenAPI 3 document of the endpoints.
+func Generate(title, version string, endpoints []Endpoint) map[string]interface{} {
+	paths := map[string]interface{}{}
+	schemes := map[string]interface{}{}
+
+	for _, ep := range endpoints {
+		p, params := OpenAPIPath(ep.Path)
+		item, _ := paths[p].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[p] = item
+		}
+
+		methods := []string{strings.ToLower(ep.Method)}
+		if strings.EqualFold(ep.Method, "ANY") {
+			methods = AnyMethods
+		}
+
+		for _, method := range methods {
+			item[method] = operation(ep, params)
+		}
+
+		if ep.Security != nil {
+			schemes[ep.Security.Name] = ep.Security.Scheme
+		}
+	}
+
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]interface{}{"title": title, "version": version},
+		"paths":   paths,
+	}
+
+	if len(schemes) > 0 {
+		doc["components"] = map[string]interface{}{"securitySchemes": schemes}
+	}
+
+	return doc
+}
+
+func operation(ep Endpoint, params []string) map[string]interface{} {
+	status := ep.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	response := map[string]interface{}{"description": http.StatusText(status)}
+	switch {
+	case ep.Body != nil:
+		response["content"] = map[string]interface{}{
+			contentTypeOr(ep.ContentType): map[string]interface{}{
+				"schema":  InferSchema(ep.Body),
+				"example": ep.Body,
+			},
+		}
+	case ep.ContentType != "":
+		response["content"] = map[string]interface{}{
+			ep.ContentType: map[string]interface{}{
+				"schema": map[string]interface{}{"type": "string", "format": "binary"},
+			},
+		}
+	}
+
+	op := map[string]interface{}{
+		"responses": map[string]interface{}{strconv.Itoa(status): response},
+	}
+
+	if len(params) > 0 {
+		parameters := make([]interface{}, len(params))
+		for i, name := range params {
+			parameters[i] = map[string]interface{}{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			}
+		}
+		op["parameters"] = parameters
+	}
+
+	if ep.Security != nil {
+		op["security"] = []interface{}{map[string]interface{}{ep.Security.Name: []interface{}{}}}
+	}
+
+	return op
+}
+
+func contentTypeOr(contentType string) string {
+	if contentType == "" {
+		return "application/json"
+	}
+
+	return contentType
+}
+
+// OpenAPIPath converts the gin style path like /users/:id or /static/*file to
+// the OpenAPI path template /users/{id} or /static/{file}, and returns the parameter names.
+func OpenAPIPath(p string) (string, []string) {
+	var params []string
+
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			name := seg[1:]
+			params = append(params, name)
+			segments[i] = "{" + name + "}"
+		}
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+// InferSchema infers the JSON schema from the decoded JSON value.
+func InferSchema(v interface{}) map[string]interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		properties := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			properties[k] = InferSchema(val)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	case []interface{}:
+		if len(t) == 0 {
+			return map[string]interface{}{"type": "array", "items": map[string]interface{}{}}
+		}
+		return map[string]interface{}{"type": "array", "items": InferSchema(t[0])}
+	case string:
+		return map[string]interface{}{"type": "string"}
+	case bool:
+		return map[string]interface{}{"type": "boolean"}
+	case float64:
+		if t == math.Trunc(t) {
+			return map[string]interface{}{"type": "integer"}
+		}
+		return map[string]interface{}{"type": "number"}
+	case nil:
+		return map[string]interface{}{"nullable": true}
+	default:
+		return map[string]interface{}{}
+	}
+}
@@ -0,0 +1,71 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPIPath(t *testing.T) {
+	p, params := OpenAPIPath("/users/:id/books/:bookId")
+	assert.Equal(t, "/users/{id}/books/{bookId}", p)
+	assert.Equal(t, []string{"id", "bookId"}, params)
+
+	p, params = OpenAPIPath("/static/*file")
+	assert.Equal(t, "/static/{file}", p)
+	assert.Equal(t, []string{"file"}, params)
+}
+
+func TestInferSchema(t *testing.T) {
+	var body interface{}
+	_ = json.Unmarshal([]byte(`{"name":"bingoo","age":18,"score":9.5,"ok":true,"tags":["a"]}`), &body)
+	assert.Equal(t, map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name":  map[string]interface{}{"type": "string"},
+			"age":   map[string]interface{}{"type": "integer"},
+			"score": map[string]interface{}{"type": "number"},
+			"ok":    map[string]interface{}{"type": "boolean"},
+			"tags":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+	}, InferSchema(body))
+}
+
+func TestGenerate(t *testing.T) {
+	doc := Generate("httplive", "1.0", []Endpoint{
+		{Method: "GET", Path: "/users/:id", Body: map[string]interface{}{"id": "1"}, Security: BearerToken()},
+		{Method: "ANY", Path: "/files/*file", ContentType: "application/octet-stream"},
+	})
+
+	data, _ := json.Marshal(doc)
+	assert.JSONEq(t, `{
+  "openapi": "3.0.3",
+  "info": {"title": "httplive", "version": "1.0"},
+  "components": {"securitySchemes": {"bearerAuth": {"type": "http", "scheme": "bearer"}}},
+  "paths": {
+    "/users/{id}": {
+      "get": {
+        "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+        "security": [{"bearerAuth": []}],
+        "responses": {"200": {"description": "OK", "content": {"application/json": {
+          "schema": {"type": "object", "properties": {"id": {"type": "string"}}},
+          "example": {"id": "1"}
+        }}}}
+      }
+    },
+    "/files/{file}": {
+      "get": {"parameters": [{"name": "file", "in": "path", "required": true, "schema": {"type": "string"}}],
+        "responses": {"200": {"description": "OK", "content": {"application/octet-stream": {"schema": {"type": "string", "format": "binary"}}}}}},
+      "post": {"parameters": [{"name": "file", "in": "path", "required": true, "schema": {"type": "string"}}],
+        "responses": {"200": {"description": "OK", "content": {"application/octet-stream": {"schema": {"type": "string", "format": "binary"}}}}}},
+      "put": {"parameters": [{"name": "file", "in": "path", "required": true, "schema": {"type": "string"}}],
+        "responses": {"200": {"description": "OK", "content": {"application/octet-stream": {"schema": {"type": "string", "format": "binary"}}}}}},
+      "patch": {"parameters": [{"name": "file", "in": "path", "required": true, "schema": {"type": "string"}}],
+        "responses": {"200": {"description": "OK", "content": {"application/octet-stream": {"schema": {"type": "string", "format": "binary"}}}}}},
+      "delete": {"parameters": [{"name": "file", "in": "path", "required": true, "schema": {"type": "string"}}],
+        "responses": {"200": {"description": "OK", "content": {"application/octet-stream": {"schema": {"type": "string", "format": "binary"}}}}}}
+    }
+  }
+}`, string(data))
+}
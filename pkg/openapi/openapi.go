@@ -0,0 +1,365 @@
+// Package openapi converts between the OpenAPI 3 / Swagger 2 documents and the mock endpoints.
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Operation is a mock operation parsed from the document.
+type Operation struct {
+	// Method is the upper case HTTP method, like GET.
+	Method string
+	// Path is the gin style path, like /users/:id.
+	Path string
+	// ContentType is the content type of the response.
+	ContentType string
+	// Body is the JSON example of the response.
+	Body json.RawMessage
+	// Status is the HTTP status code of the response.
+	Status int
+}
+
+// Methods are the operations methods in the path item object, in the order of parsing.
+var Methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// maxDepth limits the recursion of sampling the schema, because the schemas may refer to themselves.
+const maxDepth = 8
+
+// Parse parses the OpenAPI 3 or Swagger 2 document in JSON or YAML format, returns the operations
+// with the response bodies built from the example or schema of their 2xx responses.
+func Parse(data []byte) ([]Operation, error) {
+	var root interface{}
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("parse document: %w", err)
+	}
+
+	doc, _ := normalize(root).(map[string]interface{})
+	d := &document{root: doc}
+	if _, d.swagger = doc["swagger"]; !d.swagger {
+		if _, ok := doc["openapi"]; !ok {
+			return nil, errors.New("neither an OpenAPI 3 nor a Swagger 2 document")
+		}
+	}
+
+	paths, _ := doc["paths"].(map[string]interface{})
+	basePath := d.basePath()
+
+	var operations []Operation
+	for _, p := range sortedKeys(paths) {
+		item, _ := d.resolve(paths[p]).(map[string]interface{})
+		for _, method := range Methods {
+			op, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			operation := d.operation(op)
+			operation.Method = strings.ToUpper(method)
+			operation.Path = GinPath(path.Join("/", basePath, p))
+			operations = append(operations, operation)
+		}
+	}
+
+	return operations, nil
+}
+
+var templateParam = regexp.MustCompile(`{([^}/]+)}`)
+
+// GinPath converts the path template like /users/{id} to gin style path /users/:id.
+func GinPath(p string) string {
+	return templateParam.ReplaceAllString(p, ":$1")
+}
+
+type document struct {
+	root    map[string]interface{}
+	swagger bool
+}
+
+func (d *document) basePath() string {
+	if d.swagger {
+		s, _ := d.root["basePath"].(string)
+		return s
+	}
+
+	servers, _ := d.root["servers"].([]interface{})
+	if len(servers) == 0 {
+		return ""
+	}
+
+	server, _ := servers[0].(map[string]interface{})
+	s, _ := server["url"].(string)
+	if u, err := url.Parse(s); err == nil && !strings.Contains(u.Path, "{") {
+		return u.Path
+	}
+
+	return ""
+}
+
+func (d *document) operation(op map[string]interface{}) Operation {
+	operation := Operation{Status: 200, ContentType: "application/json"}
+	responses, _ := op["responses"].(map[string]interface{})
+
+	code := ""
+	for _, k := range sortedKeys(responses) {
+		if strings.HasPrefix(k, "2") {
+			code = k
+			break
+		}
+	}
+	if code == "" {
+		if _, ok := responses["default"]; !ok {
+			return operation
+		}
+		code = "default"
+	}
+
+	if status, err := strconv.Atoi(code); err == nil {
+		operation.Status = status
+	}
+
+	response, _ := d.resolve(responses[code]).(map[string]interface{})
+	var value interface{}
+	if d.swagger {
+		operation.ContentType, value = d.swaggerExample(op, response)
+	} else {
+		operation.ContentType, value = d.openapiExample(response)
+	}
+
+	if value != nil {
+		operation.Body, _ = json.Marshal(value)
+	}
+
+	return operation
+}
+
+func (d *document) swaggerExample(op, response map[string]interface{}) (string, interface{}) {
+	produces, _ := op["produces"].([]interface{})
+	if len(produces) == 0 {
+		produces, _ = d.root["produces"].([]interface{})
+	}
+	contentType := "application/json"
+	if len(produces) > 0 {
+		contentType, _ = produces[0].(string)
+	}
+
+	if examples, ok := response["examples"].(map[string]interface{}); ok && len(examples) > 0 {
+		k := pickContentType(examples)
+		return k, examples[k]
+	}
+
+	if schema, ok := response["schema"]; ok {
+		return contentType, d.sample(schema, 0)
+	}
+
+	return contentType, nil
+}
+
+func (d *document) openapiExample(response map[string]interface{}) (string, interface{}) {
+	content, _ := response["content"].(map[string]interface{})
+	if len(content) == 0 {
+		return "application/json", nil
+	}
+
+	contentType := pickContentType(content)
+	media, _ := content[contentType].(map[string]interface{})
+	if example, ok := media["example"]; ok {
+		return contentType, example
+	}
+
+	if examples, ok := media["examples"].(map[string]interface{}); ok && len(examples) > 0 {
+		example, _ := d.resolve(examples[sortedKeys(examples)[0]]).(map[string]interface{})
+		if value, ok := example["value"]; ok {
+			return contentType, value
+		}
+	}
+
+	if schema, ok := media["schema"]; ok {
+		return contentType, d.sample(schema, 0)
+	}
+
+	return contentType, nil
+}
+
+func pickContentType(m map[string]interface{}) string {
+	if _, ok := m["application/json"]; ok {
+		return "application/json"
+	}
+
+	keys := sortedKeys(m)
+	for _, k := range keys {
+		if strings.Contains(k, "json") {
+			return k
+		}
+	}
+
+	return keys[0]
+}
+
+// resolve follows the local reference like {"$ref": "#/components/schemas/User"}.
+func (d *document) resolve(v interface{}) interface{} {
+	for i := 0; i < maxDepth; i++ {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+
+		ref, ok := m["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return v
+		}
+
+		var target interface{} = d.root
+		for _, token := range strings.Split(ref[2:], "/") {
+			token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+			parent, _ := target.(map[string]interface{})
+			target = parent[token]
+		}
+		v = target
+	}
+
+	return v
+}
+
+func (d *document) sample(schema interface{}, depth int) interface{} {
+	s, ok := d.resolve(schema).(map[string]interface{})
+	if !ok || depth > maxDepth {
+		return nil
+	}
+
+	for _, k := range []string{"example", "default"} {
+		if v, ok := s[k]; ok {
+			return v
+		}
+	}
+
+	if enum, ok := s["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0]
+	}
+
+	if allOf, ok := s["allOf"].([]interface{}); ok {
+		merged := map[string]interface{}{}
+		for _, sub := range allOf {
+			if m, ok := d.sample(sub, depth+1).(map[string]interface{}); ok {
+				for k, v := range m {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+
+	for _, k := range []string{"oneOf", "anyOf"} {
+		if subs, ok := s[k].([]interface{}); ok && len(subs) > 0 {
+			return d.sample(subs[0], depth+1)
+		}
+	}
+
+	switch schemaType(s) {
+	case "object":
+		properties, _ := s["properties"].(map[string]interface{})
+		m := make(map[string]interface{}, len(properties))
+		for k, p := range properties {
+			m[k] = d.sample(p, depth+1)
+		}
+		return m
+	case "array":
+		return []interface{}{d.sample(s["items"], depth+1)}
+	case "integer", "number":
+		if v, ok := s["minimum"]; ok {
+			return v
+		}
+		return 0
+	case "boolean":
+		return true
+	case "string":
+		format, _ := s["format"].(string)
+		return sampleString(format)
+	}
+
+	return nil
+}
+
+func schemaType(s map[string]interface{}) string {
+	switch t := s["type"].(type) {
+	case string:
+		return t
+	case []interface{}: // OpenAPI 3.1 allows type array like [string, "null"]
+		for _, v := range t {
+			if vs, ok := v.(string); ok && vs != "null" {
+				return vs
+			}
+		}
+	}
+
+	if _, ok := s["properties"]; ok {
+		return "object"
+	}
+	if _, ok := s["items"]; ok {
+		return "array"
+	}
+
+	return ""
+}
+
+func sampleString(format string) string {
+	switch format {
+	case "uuid":
+		return "@uuid"
+	case "date":
+		return "2006-01-02"
+	case "date-time":
+		return "2006-01-02T15:04:05Z"
+	case "email":
+		return "user@example.com"
+	case "uri", "url":
+		return "https://example.com"
+	case "ipv4":
+		return "127.0.0.1"
+	default:
+		return "string"
+	}
+}
+
+// normalize converts the map[interface{}]interface{} decoded from YAML to map[string]interface{},
+// e.g. the status code keys of the responses object are decoded as integers.
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprintf("%v", k)] = normalize(val)
+		}
+		return m
+	case map[string]interface{}:
+		for k, val := range t {
+			t[k] = normalize(val)
+		}
+		return t
+	case []interface{}:
+		for i, val := range t {
+			t[i] = normalize(val)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
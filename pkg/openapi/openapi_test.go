@@ -0,0 +1,98 @@
+package openapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGinPath(t *testing.T) {
+	assert.Equal(t, "/users/:id", GinPath("/users/{id}"))
+	assert.Equal(t, "/users/:id/books/:bookId", GinPath("/users/{id}/books/{bookId}"))
+	assert.Equal(t, "/users", GinPath("/users"))
+}
+
+const openapi3 = `
+openapi: 3.0.0
+servers:
+  - url: https://example.com/v1
+paths:
+  /users/{id}:
+    get:
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/User'
+    delete:
+      responses:
+        '204':
+          description: deleted
+  /users:
+    post:
+      responses:
+        '201':
+          content:
+            application/json:
+              examples:
+                created:
+                  value: {"id": 1}
+components:
+  schemas:
+    User:
+      type: object
+      properties:
+        id:
+          type: string
+          format: uuid
+        age:
+          type: integer
+          minimum: 18
+        tags:
+          type: array
+          items:
+            type: string
+            enum: [a, b]
+`
+
+func TestParseOpenAPI3(t *testing.T) {
+	ops, err := Parse([]byte(openapi3))
+	assert.Nil(t, err)
+	assert.Equal(t, []Operation{
+		{Method: "POST", Path: "/v1/users", ContentType: "application/json", Body: []byte(`{"id":1}`), Status: 201},
+		{Method: "GET", Path: "/v1/users/:id", ContentType: "application/json", Body: []byte(`{"age":18,"id":"@uuid","tags":["a"]}`), Status: 200},
+		{Method: "DELETE", Path: "/v1/users/:id", ContentType: "application/json", Status: 204},
+	}, ops)
+}
+
+const swagger2 = `{
+  "swagger": "2.0",
+  "basePath": "/api",
+  "produces": ["application/json"],
+  "paths": {
+    "/pets/{petId}": {
+      "get": {
+        "responses": {
+          "200": {"schema": {"$ref": "#/definitions/Pet"}}
+        }
+      }
+    }
+  },
+  "definitions": {
+    "Pet": {"properties": {"name": {"type": "string", "example": "kitty"}}}
+  }
+}`
+
+func TestParseSwagger2(t *testing.T) {
+	ops, err := Parse([]byte(swagger2))
+	assert.Nil(t, err)
+	assert.Equal(t, []Operation{
+		{Method: "GET", Path: "/api/pets/:petId", ContentType: "application/json", Body: []byte(`{"name":"kitty"}`), Status: 200},
+	}, ops)
+}
+
+func TestParseUnknown(t *testing.T) {
+	_, err := Parse([]byte(`{"name": "bingoo"}`))
+	assert.NotNil(t, err)
+}
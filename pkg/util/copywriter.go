@@ -20,7 +20,7 @@ func NewGinCopyWriter(w gin.ResponseWriter, c *gin.Context) *GinCopyWriter {
 }
 
 func (w *GinCopyWriter) Write(data []byte) (n int, err error) {
-	if ct := w.c.GetHeader("Content-Type"); strings.Contains(ct, "json") {
+	if ct := w.c.GetHeader("Content-Type"); strings.Contains(ct, "json") && !w.streaming() {
 		w.Buf.Write(data)
 	}
 
@@ -28,10 +28,17 @@ func (w *GinCopyWriter) Write(data []byte) (n int, err error) {
 }
 
 func (w *GinCopyWriter) WriteString(s string) (n int, err error) {
-	w.Buf.WriteString(s)
+	if !w.streaming() {
+		w.Buf.WriteString(s)
+	}
 	return w.ResponseWriter.WriteString(s)
 }
 
+// streaming tells whether the response is an event stream, which may be endless and is not copied.
+func (w *GinCopyWriter) streaming() bool {
+	return strings.HasPrefix(w.ResponseWriter.Header().Get("Content-Type"), "text/event-stream")
+}
+
 func (w *GinCopyWriter) Body(maxSize int) string {
 	if w.ResponseWriter.Size() <= maxSize {
 		return w.Buf.String()
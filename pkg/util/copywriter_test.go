@@ -0,0 +1,33 @@
+package util
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGinCopyWriter(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+
+	for responseType, copied := range map[string]string{
+		"application/json":                 `{"a": 1}{"b": 2}`,
+		"text/event-stream":                "",
+		"text/event-stream; charset=utf-8": "",
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/", strings.NewReader(`{}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		cw := NewGinCopyWriter(c.Writer, c)
+		cw.Header().Set("Content-Type", responseType)
+		_, _ = cw.Write([]byte(`{"a": 1}`))
+		_, _ = cw.WriteString(`{"b": 2}`)
+
+		assert.Equal(t, copied, cw.Body(100), responseType)
+		assert.Equal(t, `{"a": 1}{"b": 2}`, w.Body.String(), "written anyway")
+	}
+}
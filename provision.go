@@ -0,0 +1,292 @@
+package httplive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bingoohuang/httplive/internal/process"
+	"github.com/bingoohuang/httplive/pkg/util"
+)
+
+// provisionSuffix is the suffix of the files in the provisioning directory.
+const provisionSuffix = ".req.json"
+
+// ReqFile is an endpoint in a req file, which holds one endpoint, or an array of them.
+type ReqFile struct {
+	ID        string `json:"id"`
+	Endpoint  string `json:"endpoint"`
+	Path      string `json:"path"`
+	Method    string `json:"method"`
+	Workspace string `json:"workspace"`
+	// Body @body.json 或者 直接内嵌 JSON, 相对路径在 provisioning 目录中相对于 req 文件所在目录, 否则相对于工作目录
+	Body json.RawMessage `json:"body"`
+}
+
+// readReqFile reads the endpoints in the req file, the relative @ body files are in the bodyDir,
+// or the working directory when bodyDir is empty.
+func readReqFile(reqFile, bodyDir string) ([]ReqFile, error) {
+	data, err := os.ReadFile(reqFile)
+	if err != nil {
+		return nil, fmt.Errorf("read file %s: %w", reqFile, err)
+	}
+
+	var reqs []ReqFile
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		err = json.Unmarshal(data, &reqs)
+	} else {
+		reqs = make([]ReqFile, 1)
+		err = json.Unmarshal(data, &reqs[0])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal json from file %s: %w", reqFile, err)
+	}
+
+	for i := range reqs {
+		req := &reqs[i]
+		if req.Endpoint == "" {
+			req.Endpoint = req.Path
+		}
+		if req.Method == "" {
+			req.Method = "ANY"
+		}
+		req.Method = strings.ToUpper(req.Method)
+
+		strBody := string(req.Body)
+		if strings.HasPrefix(strBody, `"@`) {
+			bodyFile := strBody[2 : len(strBody)-1]
+			if bodyDir != "" && !filepath.IsAbs(bodyFile) {
+				bodyFile = filepath.Join(bodyDir, bodyFile)
+			}
+			bodyData, err := os.ReadFile(bodyFile)
+			if err != nil {
+				return nil, fmt.Errorf("read file %s: %w", bodyFile, err)
+			}
+			req.Body = bodyData
+		}
+	}
+
+	return reqs, nil
+}
+
+func (req ReqFile) model() process.APIDataModel {
+	return process.APIDataModel{
+		Endpoint:  req.Endpoint,
+		Method:    req.Method,
+		ID:        process.ID(req.ID),
+		Body:      process.RawMessage(req.Body),
+		Workspace: req.Workspace,
+	}
+}
+
+func (req ReqFile) identity() process.ProvisionedEndpoint {
+	return process.ProvisionedEndpoint{Workspace: req.Workspace, Method: req.Method, Endpoint: req.Endpoint}
+}
+
+// ProcessReqFile processes the req file touched by a .httplive file,
+// the error is written to the .result file, or an empty .ok file is written.
+func ProcessReqFile(reqFile string) {
+	if err := ProcessReq(reqFile); err != nil {
+		os.WriteFile(reqFile+".result", []byte(err.Error()), os.ModePerm)
+	} else {
+		os.WriteFile(reqFile+".ok", nil, os.ModePerm)
+	}
+}
+
+// ProcessReq saves the endpoints in the req file, the relative @ body files are in the working directory.
+func ProcessReq(reqFile string) error {
+	reqs, err := readReqFile(reqFile, "")
+	if err != nil {
+		return err
+	}
+
+	for _, req := range reqs {
+		if _, err := SaveEndpoint(req.model()); err != nil {
+			return fmt.Errorf("save endpoint %s: %v", req.identity(), err)
+		}
+	}
+
+	return nil
+}
+
+// ProvisionReport is the report of the reconciliation of the provisioning directory.
+type ProvisionReport struct {
+	Dir      string              `json:"dir"`
+	Time     string              `json:"time"`
+	Files    map[string][]string `json:"files"`
+	Upserted []string            `json:"upserted"`
+	Deleted  []string            `json:"deleted"`
+	Errors   []string            `json:"errors,omitempty"`
+}
+
+var (
+	provisionLock sync.Mutex
+	lastProvision *ProvisionReport
+)
+
+// LastProvisionReport returns the report of the last reconciliation, nil when there is no provisioning directory.
+func LastProvisionReport() *ProvisionReport {
+	provisionLock.Lock()
+	defer provisionLock.Unlock()
+
+	return lastProvision
+}
+
+// ReconcileProvision makes the provisioned endpoints match the *.req.json files in the directory.
+// The endpoints in the files are added or updated, the ones removed from the files, or of the deleted files, are deleted.
+// The endpoints not provisioned from the directory are kept, and so are the ones of the files failed to read.
+func ReconcileProvision(dir string) *ProvisionReport {
+	provisionLock.Lock()
+	defer provisionLock.Unlock()
+
+	report := &ProvisionReport{Dir: dir, Time: util.TimeFmt(time.Now()),
+		Files: map[string][]string{}, Upserted: []string{}, Deleted: []string{}}
+	lastProvision = report
+
+	var records []process.ProvisionRecord
+	if err := DBDo(func(dao *Dao) (err error) {
+		records, err = dao.ListProvisionRecords()
+		return err
+	}); err != nil {
+		report.Errors = append(report.Errors, "list provision records: "+err.Error())
+		return report
+	}
+
+	files, err := provisionFiles(dir)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report
+	}
+
+	previous := make(map[string]process.ProvisionRecord, len(records))
+	for _, r := range records {
+		previous[r.File] = r
+	}
+
+	// the endpoints of all the files are collected first, so an endpoint moved to another file is not deleted.
+	desired := map[process.ProvisionedEndpoint]string{}
+	var current []process.ProvisionRecord
+	var upserts []ReqFile
+	for _, file := range files {
+		name := filepath.Base(file)
+		reqs, err := readReqFile(file, filepath.Dir(file))
+		if err != nil {
+			report.Errors = append(report.Errors, name+": "+err.Error())
+			for _, pe := range previous[name].Endpoints {
+				desired[pe] = name
+			}
+			delete(previous, name)
+			continue
+		}
+
+		record := process.ProvisionRecord{File: name}
+		for _, req := range reqs {
+			pe := req.identity()
+			if other, ok := desired[pe]; ok {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %s is provisioned by %s already", name, pe, other))
+				continue
+			}
+
+			desired[pe] = name
+			record.Endpoints = append(record.Endpoints, pe)
+			report.Files[name] = append(report.Files[name], pe.String())
+			upserts = append(upserts, req)
+		}
+		current = append(current, record)
+	}
+
+	for _, req := range upserts {
+		changed, err := upsertProvisioned(req)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", req.identity(), err))
+		} else if changed {
+			report.Upserted = append(report.Upserted, req.identity().String())
+		}
+	}
+
+	for _, r := range records {
+		for _, pe := range r.Endpoints {
+			if _, ok := desired[pe]; ok {
+				continue
+			}
+			if err := deleteProvisioned(pe); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", pe, err))
+			} else {
+				report.Deleted = append(report.Deleted, pe.String())
+			}
+		}
+	}
+
+	if err := DBDo(func(dao *Dao) error {
+		for _, r := range previous {
+			if err := dao.DeleteProvisionRecord(r); err != nil {
+				return err
+			}
+		}
+		for _, r := range current {
+			if err := dao.SaveProvisionRecord(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		report.Errors = append(report.Errors, "save provision records: "+err.Error())
+	}
+
+	return report
+}
+
+// provisionFiles lists the *.req.json files in the directory, following the symbolic links like the ones of
+// a mounted Kubernetes ConfigMap, and skipping the hidden ones.
+func provisionFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") || !strings.HasSuffix(e.Name(), provisionSuffix) {
+			continue
+		}
+
+		file := filepath.Join(dir, e.Name())
+		if s, err := os.Stat(file); err == nil && s.Mode().IsRegular() {
+			files = append(files, file)
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// upsertProvisioned saves the provisioned endpoint when it is new or changed.
+func upsertProvisioned(req ReqFile) (changed bool, err error) {
+	model := req.model()
+	existing, err := GetByEndpoint(model.Workspace, model.Endpoint, model.Method)
+	if err != nil {
+		return false, err
+	}
+	if existing != nil && string(existing.Body) == string(model.Body) {
+		return false, nil
+	}
+
+	_, err = SaveEndpoint(model)
+	return err == nil, err
+}
+
+// deleteProvisioned deletes the provisioned endpoint if it exists.
+func deleteProvisioned(pe process.ProvisionedEndpoint) error {
+	existing, err := GetByEndpoint(pe.Workspace, pe.Endpoint, pe.Method)
+	if err != nil || existing == nil {
+		return err
+	}
+
+	return DeleteEndpoint(pe.Workspace, string(existing.ID))
+}
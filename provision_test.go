@@ -0,0 +1,32 @@
+package httplive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadReqFileBody(t *testing.T) {
+	dir := t.TempDir()
+	reqFile := filepath.Join(dir, "a.req.json")
+	assert.Nil(t, os.WriteFile(reqFile, []byte(`{"path":"/a", "body":"@a.json"}`), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"in":"dir"}`), 0o644))
+
+	reqs, err := readReqFile(reqFile, dir)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"in":"dir"}`, string(reqs[0].Body))
+	assert.Equal(t, "ANY", reqs[0].Method)
+
+	// the req files touched by .httplive read the body files in the working directory.
+	cwd := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(cwd, "a.json"), []byte(`{"in":"cwd"}`), 0o644))
+	wd, _ := os.Getwd()
+	assert.Nil(t, os.Chdir(cwd))
+	defer os.Chdir(wd)
+
+	reqs, err = readReqFile(reqFile, "")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"in":"cwd"}`, string(reqs[0].Body))
+}
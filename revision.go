@@ -0,0 +1,109 @@
+package httplive
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bingoohuang/httplive/internal/process"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// ListRevisions lists the revisions of the endpoint in the workspace, the latest first, without the file contents.
+func ListRevisions(workspace string, endpointID uint64) (revisions []process.EndpointRevision, err error) {
+	err = WorkspaceDo(workspace, func(dao *Dao) error {
+		revisions, err = dao.ListRevisions(endpointID)
+		return err
+	})
+
+	for i := range revisions {
+		revisions[i].FileContent = nil
+	}
+	return revisions, err
+}
+
+// DiffRevisions returns the unified diff from the revision to the other revision,
+// or to the current endpoint when to is 0.
+func DiffRevisions(workspace string, from, to uint64) (string, error) {
+	var a, b *process.EndpointRevision
+	err := WorkspaceDo(workspace, func(dao *Dao) error {
+		if a = dao.FindRevision(from); a == nil {
+			return fmt.Errorf("revision %d not found", from)
+		}
+
+		if to > 0 {
+			if b = dao.FindRevision(to); b == nil {
+				return fmt.Errorf("revision %d not found", to)
+			}
+			return nil
+		}
+
+		ep := dao.FindEndpoint(a.EndpointID)
+		if ep == nil {
+			return fmt.Errorf("endpoint %d of revision %d not found", a.EndpointID, from)
+		}
+		current := process.CreateEndpointRevision(*ep, ep.UpdateTime)
+		b = &current
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	toName := "current"
+	if to > 0 {
+		toName = fmt.Sprintf("revision %d", to)
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(revisionText(a)),
+		B:        difflib.SplitLines(revisionText(b)),
+		FromFile: fmt.Sprintf("revision %d", from),
+		FromDate: a.UpdateTime,
+		ToFile:   toName,
+		ToDate:   b.UpdateTime,
+		Context:  3,
+	})
+}
+
+func revisionText(rev *process.EndpointRevision) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Endpoint: %s\nMethod: %s\n", rev.Endpoint, rev.Methods)
+	if rev.Filename != "" {
+		fmt.Fprintf(&sb, "Filename: %s\nMimeType: %s\nFileSize: %d\n", rev.Filename, rev.MimeType, len(rev.FileContent))
+	}
+	if rev.Author != "" {
+		fmt.Fprintf(&sb, "Author: %s\n", rev.Author)
+	}
+	sb.WriteString("\n")
+	sb.WriteString(rev.Body)
+	if !strings.HasSuffix(rev.Body, "\n") {
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// RestoreRevision restores the endpoint to the revision by the author,
+// the endpoint is recreated with its original ID if it has been deleted.
+func RestoreRevision(workspace string, id uint64, author string) (*process.Endpoint, error) {
+	var rev *process.EndpointRevision
+	_ = WorkspaceDo(workspace, func(dao *Dao) error {
+		rev = dao.FindRevision(id)
+		return nil
+	})
+	if rev == nil {
+		return nil, errors.New("revision not found")
+	}
+
+	return SaveEndpointBy(process.APIDataModel{
+		ID:          process.ID(fmt.Sprintf("%d", rev.EndpointID)),
+		Endpoint:    rev.Endpoint,
+		Method:      rev.Methods,
+		MimeType:    rev.MimeType,
+		Filename:    rev.Filename,
+		FileContent: rev.FileContent,
+		Body:        process.RawMessage(rev.Body),
+		Workspace:   workspace,
+	}, author)
+}
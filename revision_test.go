@@ -0,0 +1,119 @@
+package httplive
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bingoohuang/httplive/internal/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func saveRevised(t *testing.T, model process.APIDataModel, author string) *process.Endpoint {
+	t.Helper()
+	ep, err := SaveEndpointBy(model, author)
+	assert.Nil(t, err)
+	return ep
+}
+
+func TestListDiffRevisions(t *testing.T) {
+	prepareDB(t)
+
+	ep := saveRevised(t, process.APIDataModel{Endpoint: "/rev", Method: "GET", Body: process.RawMessage("{\n  \"v\": 1\n}")}, "alice")
+	model := process.APIDataModel{ID: process.ID("0"), Endpoint: "/rev", Method: "GET", Body: process.RawMessage("{\n  \"v\": 2\n}")}
+	saveRevised(t, model, "bob")
+	model.Body = process.RawMessage("{\n  \"v\": 3\n}")
+	model.FileContent, model.Filename = []byte("data"), "v.txt"
+	saveRevised(t, model, "carol")
+
+	revisions, err := ListRevisions("", ep.ID)
+	assert.Nil(t, err)
+	assert.Len(t, revisions, 2)
+	assert.Equal(t, "bob", revisions[0].Author, "the latest first")
+	assert.Equal(t, "alice", revisions[1].Author)
+	assert.Nil(t, revisions[0].FileContent, "without the file contents")
+	assert.Contains(t, revisions[0].Body, `"v": 2`)
+
+	diff, err := DiffRevisions("", revisions[1].ID, revisions[0].ID)
+	assert.Nil(t, err)
+	assert.Contains(t, diff, fmt.Sprintf("--- revision %d", revisions[1].ID))
+	assert.Contains(t, diff, fmt.Sprintf("+++ revision %d", revisions[0].ID))
+	assert.Contains(t, diff, "-Author: alice\n+Author: bob\n")
+	assert.Contains(t, diff, "-  \"v\": 1\n+  \"v\": 2\n")
+
+	diff, err = DiffRevisions("", revisions[0].ID, 0)
+	assert.Nil(t, err)
+	assert.Contains(t, diff, "+++ current")
+	assert.Contains(t, diff, "+Filename: v.txt\n+MimeType: \n+FileSize: 4\n")
+	assert.Contains(t, diff, "-  \"v\": 2\n+  \"v\": 3\n")
+
+	_, err = DiffRevisions("", 99, 0)
+	assert.ErrorContains(t, err, "revision 99 not found")
+	_, err = DiffRevisions("", revisions[0].ID, 99)
+	assert.ErrorContains(t, err, "revision 99 not found")
+
+	revisions, err = ListRevisions("", 99)
+	assert.Nil(t, err)
+	assert.Empty(t, revisions)
+}
+
+func TestRestoreRevision(t *testing.T) {
+	prepareDB(t)
+
+	ep := saveRevised(t, process.APIDataModel{Endpoint: "/rev", Method: "GET", Body: process.RawMessage(`{"v": 1}`)}, "alice")
+	saveRevised(t, process.APIDataModel{ID: process.ID("0"), Endpoint: "/rev", Method: "GET", Body: process.RawMessage(`{"v": 2}`)}, "bob")
+	first := revisionsOf(t, ep.ID)[0]
+
+	restored, err := RestoreRevision("", first.ID, "carol")
+	assert.Nil(t, err)
+	assert.Equal(t, ep.ID, restored.ID)
+	assert.Equal(t, "carol", restored.Author)
+	assert.JSONEq(t, `{"v": 1}`, bodyOf(t, "/rev"))
+	assert.Len(t, revisionsOf(t, ep.ID), 2, "the restore is revised too")
+
+	_, err = RestoreRevision("", 99, "")
+	assert.EqualError(t, err, "revision not found")
+}
+
+func TestRestoreDeletedRevision(t *testing.T) {
+	prepareDB(t)
+
+	ep := saveRevised(t, process.APIDataModel{Endpoint: "/gone/:id", Method: "GET", Body: process.RawMessage(`{"gone": false}`)}, "")
+	assert.Nil(t, DeleteEndpoint("", fmt.Sprint(ep.ID)))
+	assert.Nil(t, getByEndpointOf(t, "/gone/:id"))
+
+	deleted := revisionsOf(t, ep.ID)[0]
+	restored, err := RestoreRevision("", deleted.ID, "")
+	assert.Nil(t, err)
+	assert.Equal(t, ep.ID, restored.ID, "recreated with its original ID")
+
+	w := httptest.NewRecorder()
+	serveAPI(w, httptest.NewRequest("GET", "/gone/1", nil))
+	assert.JSONEq(t, `{"gone": false}`, w.Body.String())
+
+	// the route is taken by another endpoint meanwhile.
+	assert.Nil(t, DeleteEndpoint("", fmt.Sprint(ep.ID)))
+	saveRevised(t, process.APIDataModel{Endpoint: "/gone/1", Method: "GET", Body: process.RawMessage(`{"taken": true}`)}, "")
+	deleted = revisionsOf(t, ep.ID)[0]
+	_, err = RestoreRevision("", deleted.ID, "")
+	assert.NotNil(t, err, "the restored route conflicts")
+	assert.Nil(t, getByEndpointOf(t, "/gone/:id"), "not restored")
+
+	w = httptest.NewRecorder()
+	serveAPI(w, httptest.NewRequest("GET", "/gone/1", nil))
+	assert.JSONEq(t, `{"taken": true}`, w.Body.String())
+}
+
+func revisionsOf(t *testing.T, id uint64) []process.EndpointRevision {
+	t.Helper()
+	revisions, err := ListRevisions("", id)
+	assert.Nil(t, err)
+	return revisions
+}
+
+func getByEndpointOf(t *testing.T, endpoint string) *process.APIDataModel {
+	t.Helper()
+	m, err := GetByEndpoint("", endpoint, "GET")
+	assert.Nil(t, err)
+	return m
+}
@@ -0,0 +1,493 @@
+package httplive
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bingoohuang/httplive/internal/process"
+	"github.com/gin-gonic/gin"
+	"github.com/julienschmidt/httprouter"
+)
+
+// anyMethods are the methods of the ANY endpoints, the same as gin RouterGroup.Any.
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodHead, http.MethodOptions, http.MethodDelete, http.MethodConnect, http.MethodTrace,
+}
+
+// routeOwner is the endpoint owning the routes.
+type routeOwner struct {
+	identity string
+	keys     []string
+}
+
+// routeTable holds the handlers of the routes keyed by "METHOD path" and the gin engine dispatching to them.
+// The handlers are replaced or removed in place, and the new routes are added to the engine in place,
+// which is rebuilt only when the new route has more params or sections than all the routes of the engine.
+type routeTable struct {
+	handlers   map[string]gin.HandlerFunc
+	owners     map[uint64]routeOwner
+	ids        map[string]uint64 // endpoint ID by endpoint identity
+	registered map[string]bool
+	// prefixes are the owned route keys by "METHOD static prefix", to find the routes which may conflict.
+	prefixes map[string]map[string]bool
+	engine   *gin.Engine
+	lock     sync.RWMutex
+	// routing locks the routing of the engine against the routes added in place.
+	routing sync.RWMutex
+	// saving serializes the saves of the endpoints, from testing their routes to putting them.
+	saving sync.Mutex
+	// maxParams and maxSections of the routes of the engine, which sizes the contexts pooled by the engine.
+	maxParams, maxSections int
+
+	grpcMocks map[uint64]*process.GRPCMock
+	grpc      *process.GRPCServices
+}
+
+var (
+	routesLock sync.Mutex
+	// workspaceRoutes are the route tables by the workspace name.
+	workspaceRoutes = map[string]*routeTable{}
+)
+
+// routesOf returns the route table of the workspace, created on its first use.
+func routesOf(workspace string) *routeTable {
+	routesLock.Lock()
+	defer routesLock.Unlock()
+
+	t, ok := workspaceRoutes[workspace]
+	if !ok {
+		t = newRouteTable()
+		workspaceRoutes[workspace] = t
+	}
+	return t
+}
+
+// dropRoutes drops the route table of the deleted workspace.
+func dropRoutes(workspace string) {
+	routesLock.Lock()
+	defer routesLock.Unlock()
+
+	delete(workspaceRoutes, workspace)
+}
+
+func newRouteTable() *routeTable {
+	t := &routeTable{
+		handlers: map[string]gin.HandlerFunc{},
+		owners:   map[uint64]routeOwner{},
+		ids:      map[string]uint64{},
+		prefixes: map[string]map[string]bool{},
+	}
+	t.rebuild()
+	t.grpcMocks = map[uint64]*process.GRPCMock{}
+	t.grpc = process.NewGRPCServices(nil)
+	return t
+}
+
+func init() {
+	OnEndpointChanged(func(e EndpointEvent) {
+		if !e.routed {
+			routesOf(e.Workspace).apply(e)
+		}
+	})
+}
+
+// apply puts or removes the routes of the endpoint changed.
+func (t *routeTable) apply(e EndpointEvent) {
+	if e.Deleted {
+		t.Remove(e.Endpoint.ID)
+	} else {
+		t.Put(*CreateAPIDataModel(e.Workspace, &e.Endpoint, false))
+	}
+}
+
+// routeLookup releases the routing lock of the request once, see routed.
+type routeLookup struct {
+	once sync.Once
+	lock *sync.RWMutex
+}
+
+type routeLookupKey struct{}
+
+func (l *routeLookup) done() { l.once.Do(l.lock.RUnlock) }
+
+// ServeHTTP serves the request by the current gin engine, the routing lock is held until the first handler runs.
+func (t *routeTable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.lock.RLock()
+	engine := t.engine
+	t.lock.RUnlock()
+
+	l := &routeLookup{lock: &t.routing}
+	l.lock.RLock()
+	defer l.done() // the redirects by the engine run no handlers
+
+	engine.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), routeLookupKey{}, l)))
+}
+
+// routed is the first handler of the engine, which releases the routing lock when the route is found.
+func routed(c *gin.Context) {
+	if l, ok := c.Request.Context().Value(routeLookupKey{}).(*routeLookup); ok {
+		l.done()
+	}
+}
+
+// GRPC returns the current gRPC services.
+func (t *routeTable) GRPC() *process.GRPCServices {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.grpc
+}
+
+// Reset replaces all the routes by the endpoints.
+func (t *routeTable) Reset(endpoints []process.APIDataModel) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.handlers = map[string]gin.HandlerFunc{}
+	t.owners = map[uint64]routeOwner{}
+	t.ids = map[string]uint64{}
+	t.prefixes = map[string]map[string]bool{}
+	t.grpcMocks = map[uint64]*process.GRPCMock{}
+	for _, ep := range endpoints {
+		t.put(ep)
+	}
+	t.rebuild()
+	t.rebuildGRPC()
+}
+
+// Put adds or replaces the routes of the endpoint.
+func (t *routeTable) Put(ep process.APIDataModel) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	_, hadGRPC := t.grpcMocks[ep.ID.Int()]
+	t.remove(ep.ID.Int())
+	keys := t.put(ep)
+	if _, hasGRPC := t.grpcMocks[ep.ID.Int()]; hadGRPC || hasGRPC {
+		t.rebuildGRPC()
+	}
+
+	for _, key := range keys {
+		if !t.registered[key] && !t.register(key) {
+			t.rebuild()
+			return
+		}
+	}
+}
+
+// register adds the new route to the engine in place, false when the engine has to be rebuilt.
+func (t *routeTable) register(key string) bool {
+	params, sections := countRoute(key)
+	if params > t.maxParams || sections > t.maxSections {
+		return false
+	}
+
+	t.routing.Lock()
+	defer t.routing.Unlock()
+
+	// the tree of the engine may be left broken by the panic of the conflicting route.
+	if err := handleRoute(t.engine, key, t.dispatch(key)); err != nil {
+		return false
+	}
+	t.registered[key] = true
+	return true
+}
+
+// Remove removes the routes of the endpoint, the requests to them are served as no route.
+func (t *routeTable) Remove(id uint64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	_, hadGRPC := t.grpcMocks[id]
+	t.remove(id)
+	if hadGRPC {
+		t.rebuildGRPC()
+	}
+}
+
+// Test tests if the routes of the endpoint conflict with the routes of the other endpoints with the same methods.
+func (t *routeTable) Test(p process.APIDataModel) (err error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	id := p.ID.Int()
+	if id == 0 {
+		id = t.ids[routeIdentity(p)]
+	}
+
+	keys := routeKeys(p)
+	if owner, ok := t.owners[id]; ok && strings.Join(owner.keys, ",") == strings.Join(keys, ",") {
+		return nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	own := map[string]bool{}
+	for _, key := range t.owners[id].keys {
+		own[key] = true
+	}
+
+	// httprouter panics on the duplicate or the conflicting routes of the same method.
+	router := httprouter.New()
+	noop := func(http.ResponseWriter, *http.Request, httprouter.Params) {}
+	for key := range t.candidates(keys) {
+		if t.registered[key] && !own[key] {
+			method, p, _ := strings.Cut(key, " ")
+			router.Handle(method, p, noop)
+		}
+	}
+
+	for _, key := range keys {
+		method, p, _ := strings.Cut(key, " ")
+		router.Handle(method, p, noop)
+	}
+	return nil
+}
+
+// Snapshot copies the routes without the handlers and the engine, to test a batch of changes by Claim and Release.
+func (t *routeTable) Snapshot() *routeTable {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	s := &routeTable{
+		owners:     make(map[uint64]routeOwner, len(t.owners)),
+		ids:        make(map[string]uint64, len(t.ids)),
+		registered: make(map[string]bool, len(t.registered)),
+		prefixes:   make(map[string]map[string]bool, len(t.prefixes)),
+	}
+	for k, v := range t.owners {
+		s.owners[k] = v
+		s.index(v.keys)
+	}
+	for k, v := range t.ids {
+		s.ids[k] = v
+	}
+	for k, v := range t.registered {
+		s.registered[k] = v
+	}
+	return s
+}
+
+// Claim claims the routes of the endpoint with the id in the snapshot.
+func (t *routeTable) Claim(id uint64, ep process.APIDataModel) {
+	t.remove(id)
+	keys := routeKeys(ep)
+	for _, key := range keys {
+		t.registered[key] = true
+	}
+	t.owners[id] = routeOwner{identity: routeIdentity(ep), keys: keys}
+	t.ids[routeIdentity(ep)] = id
+	t.index(keys)
+}
+
+// Release releases the routes of the endpoint in the snapshot.
+func (t *routeTable) Release(id uint64) {
+	t.remove(id)
+}
+
+func (t *routeTable) put(ep process.APIDataModel) []string {
+	if strings.HasPrefix(ep.Endpoint, "/_internal") {
+		ep.InternalProcess(ep.Endpoint[10:])
+		return nil
+	}
+
+	h := ep.HandleJSON
+	if ep.MimeType != "" {
+		h = ep.HandleFileDownload
+	}
+
+	if m, err := process.CreateGRPCMock(&ep); err != nil {
+		log.Printf("E! %v", err)
+	} else if m != nil {
+		t.grpcMocks[ep.ID.Int()] = m
+		h = m.Describe
+	}
+
+	keys := routeKeys(ep)
+	for _, key := range keys {
+		t.handlers[key] = h
+	}
+	t.owners[ep.ID.Int()] = routeOwner{identity: routeIdentity(ep), keys: keys}
+	t.ids[routeIdentity(ep)] = ep.ID.Int()
+	t.index(keys)
+	return keys
+}
+
+func (t *routeTable) remove(id uint64) {
+	owner := t.owners[id]
+	for _, key := range owner.keys {
+		delete(t.handlers, key)
+		if routes := t.prefixes[prefixKey(key)]; routes != nil {
+			delete(routes, key)
+			if len(routes) == 0 {
+				delete(t.prefixes, prefixKey(key))
+			}
+		}
+	}
+	delete(t.ids, owner.identity)
+	delete(t.owners, id)
+	delete(t.grpcMocks, id)
+}
+
+// rebuildGRPC rebuilds the gRPC services with the current mocks, the earlier created ones take precedence.
+func (t *routeTable) rebuildGRPC() {
+	ids := make([]uint64, 0, len(t.grpcMocks))
+	for id := range t.grpcMocks {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	mocks := make([]*process.GRPCMock, 0, len(ids))
+	for _, id := range ids {
+		mocks = append(mocks, t.grpcMocks[id])
+	}
+	t.grpc = process.NewGRPCServices(mocks)
+}
+
+// rebuild builds the gin engine with the current routes.
+func (t *routeTable) rebuild() {
+	r := gin.New()
+	r.Use(routed, echoXHeaders)
+
+	keys := make([]string, 0, len(t.handlers))
+	for key := range t.handlers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	t.registered = make(map[string]bool, len(keys))
+	t.maxParams, t.maxSections = 0, 0
+	for _, key := range keys {
+		if err := handleRoute(r, key, t.dispatch(key)); err != nil {
+			log.Printf("E! route %s: %v", key, err)
+			continue
+		}
+		t.registered[key] = true
+		params, sections := countRoute(key)
+		t.maxParams, t.maxSections = max(t.maxParams, params), max(t.maxSections, sections)
+	}
+
+	r.NoRoute(noRouteHandlerWrap)
+	t.engine = r
+}
+
+func handleRoute(r *gin.Engine, key string, h gin.HandlerFunc) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("%v", rec)
+		}
+	}()
+
+	method, p, _ := strings.Cut(key, " ")
+	r.Handle(method, p, h)
+	return nil
+}
+
+// dispatch dispatches the request to the current handler of the route.
+func (t *routeTable) dispatch(key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t.lock.RLock()
+		h := t.handlers[key]
+		t.lock.RUnlock()
+
+		if h == nil {
+			noRouteHandlerWrap(c)
+			return
+		}
+
+		h(c)
+	}
+}
+
+// countRoute counts the params and the sections of the route like gin.
+func countRoute(key string) (params, sections int) {
+	_, p, _ := strings.Cut(key, " ")
+	return strings.Count(p, ":") + strings.Count(p, "*"), strings.Count(p, "/")
+}
+
+// index indexes the route keys by their static prefixes.
+func (t *routeTable) index(keys []string) {
+	for _, key := range keys {
+		prefix := prefixKey(key)
+		if t.prefixes[prefix] == nil {
+			t.prefixes[prefix] = map[string]bool{}
+		}
+		t.prefixes[prefix][key] = true
+	}
+}
+
+// candidates returns the routes which may conflict with the routes of the keys.
+// The routes of the same method conflict only when they share the static prefix up to the first wildcard of either one,
+// so only the routes with a static prefix of the path, and, for the wildcard path, the ones under its static prefix are found.
+func (t *routeTable) candidates(keys []string) map[string]bool {
+	found := map[string]bool{}
+	add := func(routes map[string]bool) {
+		for key := range routes {
+			found[key] = true
+		}
+	}
+
+	for _, key := range keys {
+		method, p, _ := strings.Cut(key, " ")
+		for i := 1; i <= len(p); i++ {
+			add(t.prefixes[method+" "+p[:i]])
+		}
+
+		if sp := staticPrefix(p); sp != p {
+			for prefix, routes := range t.prefixes {
+				if strings.HasPrefix(prefix, method+" "+sp) {
+					add(routes)
+				}
+			}
+		}
+	}
+	return found
+}
+
+func prefixKey(key string) string {
+	method, p, _ := strings.Cut(key, " ")
+	return method + " " + staticPrefix(p)
+}
+
+// staticPrefix returns the path before its first wildcard.
+func staticPrefix(p string) string {
+	if i := strings.IndexAny(p, ":*"); i >= 0 {
+		return p[:i]
+	}
+	return p
+}
+
+// routeKeys returns the "METHOD path" keys of the routes of the endpoint.
+func routeKeys(ep process.APIDataModel) []string {
+	paths := []string{JoinContextPath(ep.Endpoint, &ep)}
+	if itemPath, ok := process.ResourceItemPath(&ep); ok {
+		paths = append(paths, JoinContextPath(itemPath, nil))
+	}
+	methods := []string{ep.Method}
+	if strings.EqualFold(ep.Method, "ANY") {
+		methods = anyMethods
+	}
+
+	keys := make([]string, 0, len(methods)*len(paths))
+	for _, p := range paths {
+		for _, method := range methods {
+			keys = append(keys, method+" "+p)
+		}
+	}
+	return keys
+}
+
+// routeIdentity returns the identity of the endpoint, its method and path.
+func routeIdentity(ep process.APIDataModel) string {
+	return strings.ToUpper(ep.Method) + " " + ep.Endpoint
+}
@@ -0,0 +1,74 @@
+package httplive
+
+import (
+	"errors"
+
+	"github.com/asdine/storm/v3"
+	"github.com/bingoohuang/httplive/internal/process"
+)
+
+// EndpointStore stores the endpoints of a workspace, in the bolt file by default,
+// or in a directory of endpoint files with the --dir flag.
+type EndpointStore interface {
+	// ListEndpoints lists all the endpoints.
+	ListEndpoints() ([]process.Endpoint, error)
+	// FindEndpoint finds the endpoint by its ID, nil when not found.
+	FindEndpoint(id uint64) (*process.Endpoint, error)
+	// FindByEndpoint finds the endpoints of the path.
+	FindByEndpoint(endpoint string) ([]process.Endpoint, error)
+	// AddEndpoint adds the endpoint, its ID is assigned when it is zero.
+	AddEndpoint(ep *process.Endpoint) error
+	// UpdateEndpoint updates the non-zero fields and the author of the endpoint.
+	UpdateEndpoint(ep process.Endpoint) error
+	// DeleteEndpoint deletes the endpoint.
+	DeleteEndpoint(ep process.Endpoint) error
+}
+
+// boltStore stores the endpoints in a storm node of the bolt file.
+type boltStore struct {
+	node storm.Node
+}
+
+func (s boltStore) ListEndpoints() (result []process.Endpoint, err error) {
+	err = s.node.All(&result)
+	return result, err
+}
+
+func (s boltStore) FindEndpoint(id uint64) (*process.Endpoint, error) {
+	result := &process.Endpoint{}
+	if err := s.node.One("ID", id, result); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			err = nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s boltStore) FindByEndpoint(endpoint string) (result []process.Endpoint, err error) {
+	if err = s.node.Find("Endpoint", endpoint, &result); errors.Is(err, storm.ErrNotFound) {
+		err = nil
+	}
+	return result, err
+}
+
+func (s boltStore) AddEndpoint(ep *process.Endpoint) error {
+	return s.node.Save(ep)
+}
+
+func (s boltStore) UpdateEndpoint(ep process.Endpoint) error {
+	if err := s.node.Update(&ep); err != nil {
+		return err
+	}
+
+	// the empty author is skipped by Update as a zero value.
+	if ep.Author == "" {
+		return s.node.UpdateField(&ep, "Author", "")
+	}
+	return nil
+}
+
+func (s boltStore) DeleteEndpoint(ep process.Endpoint) error {
+	return s.node.DeleteStruct(&ep)
+}
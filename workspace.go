@@ -0,0 +1,166 @@
+package httplive
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/bingoohuang/httplive/internal/process"
+	"github.com/bingoohuang/httplive/pkg/util"
+)
+
+var (
+	workspacesLock sync.RWMutex
+	// workspaces caches the named workspaces to select the workspace of the requests.
+	workspaces []process.Workspace
+)
+
+// loadWorkspaces loads the named workspaces from the DB into the cache.
+func loadWorkspaces() []process.Workspace {
+	var list []process.Workspace
+	_ = DBDo(func(dao *Dao) error {
+		list = dao.ListWorkspaces()
+		return nil
+	})
+
+	workspacesLock.Lock()
+	workspaces = list
+	workspacesLock.Unlock()
+
+	return list
+}
+
+// ListWorkspaces lists the named workspaces.
+func ListWorkspaces() []process.Workspace {
+	workspacesLock.RLock()
+	defer workspacesLock.RUnlock()
+
+	return append([]process.Workspace{}, workspaces...)
+}
+
+// SaveWorkspace adds or updates the workspace, its selectors should not be claimed by the other workspaces.
+func SaveWorkspace(w process.Workspace) (*process.Workspace, error) {
+	if err := w.Normalize(); err != nil {
+		return nil, err
+	}
+
+	err := DBDo(func(dao *Dao) error {
+		for _, o := range dao.ListWorkspaces() {
+			if o.Name == w.Name {
+				w.CreateTime = o.CreateTime
+			} else if conflict := w.Conflicts(o); conflict != "" {
+				return fmt.Errorf("%s is used by workspace %s already", conflict, o.Name)
+			}
+		}
+
+		if w.CreateTime == "" {
+			w.CreateTime = util.TimeFmt(time.Now())
+		}
+		return dao.SaveWorkspace(w)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	loadWorkspaces()
+	return &w, nil
+}
+
+// DeleteWorkspace deletes the workspace with all its endpoints.
+func DeleteWorkspace(name string) error {
+	err := DBDo(func(dao *Dao) error {
+		w := dao.FindWorkspace(name)
+		if w == nil {
+			return fmt.Errorf("workspace %q not found", name)
+		}
+
+		return dao.DeleteWorkspace(*w)
+	})
+	if err != nil {
+		return err
+	}
+
+	loadWorkspaces()
+	dropRoutes(name)
+	process.Scenarios.Drop(name)
+	return nil
+}
+
+// selectWorkspace selects the workspace of the request,
+// the request with the path prefix of the workspace is returned with the prefix trimmed.
+func selectWorkspace(r *http.Request) (string, *http.Request) {
+	workspacesLock.RLock()
+	list := workspaces
+	workspacesLock.RUnlock()
+
+	if len(list) == 0 {
+		return "", r
+	}
+
+	p, underContext := r.URL.Path, true
+	if Envs.ContextPath != "/" {
+		p, underContext = strings.CutPrefix(p, Envs.ContextPath)
+	}
+	if !underContext {
+		p = "" // the path out of the context path selects no workspace by the prefix
+	}
+
+	localAddr := ""
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		localAddr = addr.String()
+	}
+
+	name, prefix := process.SelectWorkspace(list, p, r.Host, localAddr)
+	if prefix != "" {
+		r = r.Clone(r.Context())
+		r.URL.Path = JoinContextPath(util.Or(strings.TrimPrefix(p, prefix), "/"), nil)
+		r.URL.RawPath = ""
+	}
+
+	return name, r
+}
+
+// BackupWorkspace writes a bolt db file holding the endpoints of the workspace as its default workspace,
+// which can be used as the --dbpath of another httplive.
+func BackupWorkspace(w http.ResponseWriter, workspace string) error {
+	var endpoints []process.Endpoint
+	var revisions []process.EndpointRevision
+	if err := WorkspaceDo(workspace, func(dao *Dao) error {
+		endpoints = dao.ListEndpoints()
+		revisions = dao.AllRevisions()
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "httplive")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := storm.Open(filepath.Join(dir, "httplive.bolt"))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	dao, _ := CreateDao(db)
+	for _, ep := range endpoints {
+		dao.AddEndpoint(ep)
+	}
+	for _, rev := range revisions {
+		if err := dao.AddRevision(rev); err != nil {
+			return fmt.Errorf("backup revision %d: %w", rev.ID, err)
+		}
+	}
+
+	dao.Backup(w, workspace+".bolt")
+	return nil
+}
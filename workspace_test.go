@@ -0,0 +1,138 @@
+package httplive
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bingoohuang/httplive/internal/process"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// prepareWorkspaces saves the workspaces selected by the prefix, the host and the port,
+// with the endpoint /users/:id in each workspace and the default one.
+func prepareWorkspaces(t *testing.T) {
+	t.Helper()
+	prepareDB(t)
+
+	for _, w := range []process.Workspace{
+		{Name: "byPrefix", Prefix: "/teamA"},
+		{Name: "byHost", Hosts: []string{"b.local"}},
+		{Name: "byPort", Ports: []string{"5004"}},
+	} {
+		_, err := SaveWorkspace(w)
+		assert.Nil(t, err)
+	}
+	t.Cleanup(func() {
+		for _, w := range ListWorkspaces() {
+			assert.Nil(t, DeleteWorkspace(w.Name))
+		}
+	})
+
+	for _, workspace := range []string{"", "byPrefix", "byHost", "byPort"} {
+		_, err := SaveEndpoint(process.APIDataModel{Workspace: workspace, Endpoint: "/users/:id", Method: "GET",
+			Body: process.RawMessage(`{"workspace": "` + workspace + `", "path": "{{request.path}}", "id": "{{request.path.id}}"}`)})
+		assert.Nil(t, err)
+	}
+}
+
+func serveWorkspace(t *testing.T, target, host string, port int) (process.RouterResult, *httptest.ResponseRecorder) {
+	t.Helper()
+
+	r := httptest.NewRequest("GET", target, nil)
+	r.Host = host
+	r = r.WithContext(context.WithValue(r.Context(), http.LocalAddrContextKey, &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}))
+	w := httptest.NewRecorder()
+	return serveAPI(w, r), w
+}
+
+func TestServeWorkspaces(t *testing.T) {
+	prepareWorkspaces(t)
+
+	for _, c := range []struct {
+		target, host string
+		port         int
+		workspace    string
+		body         string
+	}{
+		{"/teamA/users/1?x=1", "b.local", 5004, "byPrefix", `{"workspace": "byPrefix", "path": "/users/1", "id": "1"}`},
+		{"/users/2", "B.local:5003", 5004, "byHost", `{"workspace": "byHost", "path": "/users/2", "id": "2"}`},
+		{"/users/3", "other", 5004, "byPort", `{"workspace": "byPort", "path": "/users/3", "id": "3"}`},
+		{"/users/4", "other", 5003, "", `{"workspace": "", "path": "/users/4", "id": "4"}`},
+		{"/teamAB/users/5", "other", 5003, "", ""},
+		{"/users/teamA/users/6", "other", 5003, "", ""},
+	} {
+		v, w := serveWorkspace(t, c.target, c.host, c.port)
+		assert.Equal(t, c.workspace, v.Workspace, c.target)
+		if c.body == "" {
+			assert.False(t, v.RouterServed, c.target)
+		} else {
+			assert.JSONEq(t, c.body, w.Body.String(), c.target)
+		}
+	}
+}
+
+func TestServeWorkspacesContextPath(t *testing.T) {
+	Envs.ContextPath = "/ctx"
+	t.Cleanup(func() { Envs.ContextPath = "/" })
+	prepareWorkspaces(t)
+
+	v, w := serveWorkspace(t, "/ctx/teamA/users/1", "b.local", 5004)
+	assert.Equal(t, "byPrefix", v.Workspace)
+	assert.JSONEq(t, `{"workspace": "byPrefix", "path": "/ctx/users/1", "id": "1"}`, w.Body.String(),
+		"the prefix is trimmed after the context path")
+
+	v, _ = serveWorkspace(t, "/teamA/users/1", "other", 5003)
+	assert.Equal(t, "", v.Workspace, "the prefix is after the context path")
+}
+
+func TestServeWorkspaceScenarios(t *testing.T) {
+	prepareWorkspaces(t)
+	t.Cleanup(func() { process.Scenarios.Drop("") })
+
+	for _, workspace := range []string{"", "byPrefix"} {
+		_, err := SaveEndpoint(process.APIDataModel{Workspace: workspace, Endpoint: "/login", Method: "GET",
+			Body: process.RawMessage(`{"_dynamic": [
+				{"scenario": "login", "requiredState": "Started", "newState": "LoggedIn", "status": 401, "response": {"error": "unauthorized"}},
+				{"scenario": "login", "requiredState": "LoggedIn", "response": {"token": "` + workspace + `"}}
+			]}`)})
+		assert.Nil(t, err)
+	}
+
+	login := func(target string) (int, string) {
+		_, w := serveWorkspace(t, target, "other", 5003)
+		return w.Code, w.Body.String()
+	}
+	code, _ := login("/login")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, body := login("/login")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"token": ""}`, body)
+
+	code, _ = login("/teamA/login")
+	assert.Equal(t, http.StatusUnauthorized, code, "the same-named scenario of the other workspace is apart")
+	code, body = login("/teamA/login")
+	assert.JSONEq(t, `{"token": "byPrefix"}`, body)
+
+	scenarios := func(api, workspace string) gin.H {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/api/scenarios?workspace="+workspace, nil)
+		if api == "reset" {
+			return WebCliController{}.ResetScenarios(c, resetScenariosT{})
+		}
+		return WebCliController{}.Scenarios(c, scenariosT{})
+	}
+	assert.Equal(t, gin.H{"scenarios": map[string]string{"login": process.ScenarioStarted}}, scenarios("reset", "byPrefix"))
+	assert.Equal(t, gin.H{"scenarios": map[string]string{"login": "LoggedIn"}}, scenarios("list", ""), "reset in the workspace only")
+
+	code, _ = login("/teamA/login")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = login("/login")
+	assert.Equal(t, http.StatusOK, code)
+
+	assert.Nil(t, DeleteWorkspace("byPrefix"))
+	assert.Empty(t, process.Scenarios.All("byPrefix"), "dropped with the workspace")
+}